@@ -2,27 +2,325 @@ package actions
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
 
+	"github.com/danielkrainas/gobag/context"
 	"github.com/danielkrainas/gobag/util/uuid"
 
 	"github.com/danielkrainas/csense/api/v1"
 	"github.com/danielkrainas/csense/commands"
 	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/hooks"
 	"github.com/danielkrainas/csense/queries"
 	"github.com/danielkrainas/csense/storage"
 )
 
-func DeleteHook(ctx context.Context, c *commands.DeleteHook, hooks storage.HookStore) error {
-	return hooks.Delete(c.ID)
+var ErrReceiverInUse = errors.New("receiver is referenced by hooks")
+
+// InvalidError is returned when a hook or receiver has settings that can't
+// be used.
+type InvalidError struct {
+	Reason string
+}
+
+func (err *InvalidError) Error() string {
+	return err.Reason
+}
+
+// UnknownReceiverError is returned when a hook references a receiver that
+// doesn't exist.
+type UnknownReceiverError struct {
+	ID string
+}
+
+func (err *UnknownReceiverError) Error() string {
+	return fmt.Sprintf("receiver %q not found", err.ID)
+}
+
+func checkRetry(policy *v1.RetryPolicy) error {
+	if _, _, err := hooks.ParseRetry(policy); err != nil {
+		return &InvalidError{err.Error()}
+	}
+
+	return nil
+}
+
+func checkDebounce(policy *v1.DebouncePolicy) error {
+	if _, _, err := hooks.ParseDebounce(policy); err != nil {
+		return &InvalidError{err.Error()}
+	}
+
+	return nil
+}
+
+func DeleteHook(ctx context.Context, c *commands.DeleteHook, hookStore storage.HookStore, deliveries storage.DeliveryStore) error {
+	if err := hookStore.Delete(c.ID); err != nil {
+		return err
+	}
+
+	// a hook created later with the same ID starts over
+	return deliveries.DeleteSequence(c.ID)
+}
+
+func checkTransport(transport *v1.TransportConfig, policy *hooks.DestinationPolicy, clients *hooks.ClientCache) error {
+	if transport == nil {
+		return nil
+	}
+
+	if err := clients.CheckFiles(transport); err != nil {
+		return &InvalidError{err.Error()}
+	}
+
+	if transport.Proxy == "" {
+		return nil
+	}
+
+	return policy.CheckProxy(transport.Proxy)
 }
 
-func StoreHook(ctx context.Context, c *commands.StoreHook, hooks storage.HookStore) error {
+func StoreHook(ctx context.Context, c *commands.StoreHook, hookStore storage.HookStore, receivers storage.ReceiverStore, policy *hooks.DestinationPolicy, clients *hooks.ClientCache) error {
 	h := c.Hook
+	switch h.Mode {
+	case "", v1.DeliveryModeAll, v1.DeliveryModeFailover:
+	default:
+		return &InvalidError{fmt.Sprintf("unknown delivery mode %q", h.Mode)}
+	}
+
+	if err := checkDebounce(h.Debounce); err != nil {
+		return err
+	}
+
+	if err := checkTransport(h.Transport, policy, clients); err != nil {
+		return err
+	}
+
+	for _, d := range h.Targets() {
+		if d.Receiver != "" {
+			if _, err := receivers.Find(d.Receiver); err == storage.ErrNotFound {
+				return &UnknownReceiverError{d.Receiver}
+			} else if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := policy.CheckURL(d.Url); err != nil {
+			return err
+		}
+
+		if err := checkTransport(d.Transport, policy, clients); err != nil {
+			return err
+		}
+
+		if err := checkRetry(d.Retry); err != nil {
+			return err
+		}
+	}
+
 	if h.ID == "" {
 		h.ID = uuid.Generate()
 	}
 
-	return hooks.Store(h, c.New)
+	return hookStore.Store(h, c.New)
+}
+
+func VerifyHook(ctx context.Context, c *commands.VerifyHook, hookStore storage.HookStore, receivers storage.ReceiverStore, verifier *hooks.Verifier) error {
+	h := c.Hook
+	if !h.Verify {
+		// nothing to verify, the hook is active
+		return nil
+	}
+
+	resolved, err := resolveHook(h, receivers)
+	verified := h.Targets()
+	if err == nil {
+		verified = resolved.Targets()
+		err = verifier.Verify(ctx, resolved)
+	}
+
+	if err != nil {
+		acontext.GetLoggerWithField(ctx, "hook.id", h.ID).Warnf("hook verification failed: %v", err)
+		h.Status = v1.HookPendingVerification
+		h.VerificationError = err.Error()
+	} else {
+		h.Status = v1.HookActive
+		h.VerificationError = ""
+	}
+
+	// the hook may have been changed or deleted while it was verified, only
+	// its status is updated and only if it still has the verified targets
+	current, err := hookStore.Find(h.ID)
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !current.Verify || !reflect.DeepEqual(verifiedTargets(current, receivers), verified) {
+		return nil
+	}
+
+	current.Status = h.Status
+	current.VerificationError = h.VerificationError
+	return hookStore.Store(current, false)
+}
+
+// verifiedTargets returns the destinations a verification of the hook
+// checks, receivers that can't be resolved are left as they are.
+func verifiedTargets(h *v1.Hook, receivers storage.ReceiverStore) []*v1.Destination {
+	resolved, err := resolveHook(h, receivers)
+	if err != nil {
+		return h.Targets()
+	}
+
+	return resolved.Targets()
+}
+
+func ResolveHook(ctx context.Context, q *queries.ResolveHook, receivers storage.ReceiverStore) (*v1.Hook, error) {
+	return resolveHook(q.Hook, receivers)
+}
+
+func resolveHook(h *v1.Hook, receivers storage.ReceiverStore) (*v1.Hook, error) {
+	if len(h.Destinations) == 0 {
+		return h, nil
+	}
+
+	dupe := *h
+	dupe.Destinations = make([]*v1.Destination, len(h.Destinations))
+	for i, d := range h.Destinations {
+		if d.Receiver == "" {
+			dupe.Destinations[i] = d
+			continue
+		}
+
+		r, err := receivers.Find(d.Receiver)
+		if err == storage.ErrNotFound {
+			return nil, &UnknownReceiverError{d.Receiver}
+		} else if err != nil {
+			return nil, err
+		}
+
+		dupe.Destinations[i] = r.Destination()
+	}
+
+	return &dupe, nil
+}
+
+func DeleteReceiver(ctx context.Context, c *commands.DeleteReceiver, receivers storage.ReceiverStore, hooks storage.HookStore) error {
+	all, err := hooks.FindMany(&storage.HookFilters{})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range all {
+		for _, d := range h.Destinations {
+			if d.Receiver == c.ID {
+				return ErrReceiverInUse
+			}
+		}
+	}
+
+	return receivers.Delete(c.ID)
+}
+
+func StoreReceiver(ctx context.Context, c *commands.StoreReceiver, receivers storage.ReceiverStore, policy *hooks.DestinationPolicy, clients *hooks.ClientCache) error {
+	r := c.Receiver
+	if r.Url == "" {
+		return &InvalidError{"receiver url is required"}
+	}
+
+	if err := policy.CheckURL(r.Url); err != nil {
+		return err
+	}
+
+	if err := checkTransport(r.Transport, policy, clients); err != nil {
+		return err
+	}
+
+	if err := checkRetry(r.Retry); err != nil {
+		return err
+	}
+
+	if r.ID == "" {
+		r.ID = uuid.Generate()
+	}
+
+	return receivers.Store(r, c.New)
+}
+
+func StoreSilence(ctx context.Context, c *commands.StoreSilence, silences storage.SilenceStore) error {
+	s := c.Silence
+	if err := hooks.ValidateSilence(s); err != nil {
+		return &InvalidError{err.Error()}
+	}
+
+	if s.ID == "" {
+		s.ID = uuid.Generate()
+	}
+
+	s.Status = ""
+	return silences.Store(s, c.New)
+}
+
+func DeleteSilence(ctx context.Context, c *commands.DeleteSilence, silences storage.SilenceStore) error {
+	return silences.Delete(c.ID)
+}
+
+func PurgeSilences(ctx context.Context, c *commands.PurgeSilences, silences storage.SilenceStore) error {
+	all, err := silences.FindMany(&storage.SilenceFilters{})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range all {
+		if s.EndsAt < c.Before {
+			if err := silences.Delete(s.ID); err != nil && err != storage.ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func FindSilence(ctx context.Context, q *queries.FindSilence, silences storage.SilenceStore) (*v1.Silence, error) {
+	s, err := silences.Find(q.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return withSilenceStatus(s), nil
+}
+
+func SearchSilences(ctx context.Context, q *queries.SearchSilences, silences storage.SilenceStore) ([]*v1.Silence, error) {
+	all, err := silences.FindMany(&storage.SilenceFilters{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range all {
+		all[i] = withSilenceStatus(s)
+	}
+
+	return all, nil
+}
+
+// withSilenceStatus returns a copy of the silence with its current status,
+// the status depends on the time so it isn't stored.
+func withSilenceStatus(s *v1.Silence) *v1.Silence {
+	dupe := *s
+	dupe.Status = s.StatusAt(time.Now())
+	return &dupe
+}
+
+func FindReceiver(ctx context.Context, q *queries.FindReceiver, receivers storage.ReceiverStore) (*v1.Receiver, error) {
+	return receivers.Find(q.ID)
+}
+
+func SearchReceivers(ctx context.Context, q *queries.SearchReceivers, receivers storage.ReceiverStore) ([]*v1.Receiver, error) {
+	return receivers.FindMany(&storage.ReceiverFilters{})
 }
 
 func FindHook(ctx context.Context, q *queries.FindHook, hooks storage.HookStore) (*v1.Hook, error) {
@@ -34,6 +332,31 @@ func SearchHooks(ctx context.Context, q *queries.SearchHooks, hooks storage.Hook
 	return hooks.FindMany(&storage.HookFilters{})
 }
 
+func StoreDelivery(ctx context.Context, c *commands.StoreDelivery, deliveries storage.DeliveryStore) error {
+	d := c.Delivery
+	if d.ID == "" {
+		d.ID = uuid.Generate()
+	}
+
+	return deliveries.Store(d)
+}
+
+func ReserveSequence(ctx context.Context, c *commands.ReserveSequence, deliveries storage.DeliveryStore) error {
+	seq, err := deliveries.NextSequence(c.HookID)
+	if err != nil {
+		return err
+	}
+
+	c.Sequence = seq
+	return nil
+}
+
+func SearchDeliveries(ctx context.Context, q *queries.SearchDeliveries, deliveries storage.DeliveryStore) ([]*v1.Delivery, error) {
+	return deliveries.FindMany(&storage.DeliveryFilters{
+		HookID: q.HookID,
+	})
+}
+
 func GetContainerEvents(ctx context.Context, q *queries.GetContainerEvents, conts containers.Driver) (containers.EventsChannel, error) {
 	ch, err := conts.WatchEvents(ctx, q.Types...)
 	if err != nil {
@@ -42,15 +365,38 @@ func GetContainerEvents(ctx context.Context, q *queries.GetContainerEvents, cont
 
 	set, err := conts.GetContainers(ctx)
 	if err != nil {
+		ch.Close()
 		return nil, err
 	}
 
-	ch = &containers.EventsContainerTracker{
-		Index: containers.IndexByName(set),
-		EventsChannel: &containers.EventsContainerResolver{
+	ch = &containers.EventsContainerResolver{
+		EventsChannel: ch,
+		Driver:        conts,
+		Context:       ctx,
+	}
+
+	now := time.Now().Unix()
+	missed := make([]*v1.ContainerEvent, 0)
+	if q.Index == nil {
+		q.Index = containers.IndexByName(set)
+	} else {
+		missed = containers.Diff(q.Index, set, now)
+	}
+
+	if q.Existed {
+		missed = append(missed, containers.Existed(set, missed, now)...)
+	}
+
+	if len(missed) > 0 {
+		ch = &containers.EventsChannelPrefix{
 			EventsChannel: ch,
-			Driver:        conts,
-		},
+			Events:        missed,
+		}
+	}
+
+	ch = &containers.EventsContainerTracker{
+		Index:         q.Index,
+		EventsChannel: ch,
 	}
 
 	return ch, nil
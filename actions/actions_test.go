@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/containers/driver/replay"
+	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/queries"
+	"github.com/danielkrainas/csense/storage"
+	"github.com/danielkrainas/csense/storage/driver/factory"
+	_ "github.com/danielkrainas/csense/storage/driver/inmemory"
+)
+
+func TestGetContainerEventsKeepDeletionDetails(t *testing.T) {
+	d := replay.New()
+	q := &queries.GetContainerEvents{
+		Types: []v1.ContainerEventType{v1.EventContainerCreation, v1.EventContainerDeletion},
+	}
+
+	ch, err := GetContainerEvents(context.Background(), q, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+	code := 137
+	events := []*v1.ContainerEvent{
+		{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "web", ImageName: "nginx", Labels: map[string]string{"app": "web"}}},
+		{Type: v1.EventContainerDeletion, Container: &v1.ContainerInfo{Name: "web", ExitCode: &code, Reason: "OOMKilled", FinishedAt: 1700000100}},
+	}
+
+	// each event is read before the next is emitted, so the driver's
+	// containers are as they were when the event was sent
+	var got []*v1.ContainerEvent
+	for _, e := range events {
+		go d.Emit(context.Background(), e)
+		select {
+		case e := <-ch.GetChannel():
+			got = append(got, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of %d events", len(got), len(events))
+		}
+	}
+
+	deleted := got[1]
+	c := deleted.Container
+	if c.ExitCode == nil || *c.ExitCode != 137 || c.Reason != "OOMKilled" || c.FinishedAt != 1700000100 {
+		t.Errorf("lost the deletion's details: %+v", c)
+	}
+
+	if c.ImageName != "nginx" || c.Labels["app"] != "web" {
+		t.Errorf("didn't fill in the tracked container: %+v", c)
+	}
+
+	if c.State != v1.StateStopped || deleted.PreviousState != v1.StateRunning {
+		t.Errorf("got state %q after %q", c.State, deleted.PreviousState)
+	}
+
+	// the creation's reaction keeps the container as it was
+	if got[0].Container.State != v1.StateRunning {
+		t.Errorf("tracked container changed to %q", got[0].Container.State)
+	}
+}
+
+func newTestStore(t *testing.T) storage.Driver {
+	base, err := factory.Create("inmemory", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base.(storage.Driver)
+}
+
+func newTestVerifier() *hooks.Verifier {
+	return &hooks.Verifier{
+		Clients: &hooks.ClientCache{Policy: &hooks.DestinationPolicy{AllowPrivate: true}},
+	}
+}
+
+func TestVerifyHookSkipsUnverifiedHooks(t *testing.T) {
+	store := newTestStore(t)
+	h := &v1.Hook{ID: "h1", Url: "http://127.0.0.1:1/hook", Status: v1.HookActive}
+	if err := store.Hooks().Store(h, true); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &commands.VerifyHook{Hook: h}
+	if err := VerifyHook(context.Background(), c, store.Hooks(), store.Receivers(), newTestVerifier()); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.Hooks().Find("h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Status != v1.HookActive || stored.Status != v1.HookActive || stored.VerificationError != "" {
+		t.Errorf("got status %q, stored %q (%s)", h.Status, stored.Status, stored.VerificationError)
+	}
+}
+
+func TestVerifyHookIgnoresChangedTargets(t *testing.T) {
+	store := newTestStore(t)
+	changed := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the hook is pointed somewhere else while it's being verified
+		if <-changed {
+			h, _ := store.Hooks().Find("h1")
+			h.Url = "http://127.0.0.1:1/typo"
+			store.Hooks().Store(h, false)
+		}
+
+		io.WriteString(w, r.Header.Get(hooks.ChallengeHeader))
+	}))
+
+	defer srv.Close()
+	for _, change := range []bool{false, true} {
+		h := &v1.Hook{ID: "h1", Url: srv.URL, Verify: true, Status: v1.HookPendingVerification}
+		if err := store.Hooks().Store(h, !change); err != nil {
+			t.Fatal(err)
+		}
+
+		changed <- change
+		c := &commands.VerifyHook{Hook: h}
+		if err := VerifyHook(context.Background(), c, store.Hooks(), store.Receivers(), newTestVerifier()); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := store.Hooks().Find("h1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := v1.HookActive
+		if change {
+			want = v1.HookPendingVerification
+		}
+
+		if stored.Status != want {
+			t.Errorf("changed=%v: got status %q, want %q", change, stored.Status, want)
+		}
+	}
+}
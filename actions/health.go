@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/queries"
+)
+
+// health is the state reported by the agent, it's degraded until the agent
+// first connects to the container events.
+type health struct {
+	mu     sync.Mutex
+	events v1.EventsHealth
+	seen   bool
+}
+
+func ReportEventsStatus(ctx context.Context, c *commands.ReportEventsStatus, h *health) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c.Connected && !h.events.Connected && h.seen {
+		h.events.Reconnects++
+	}
+
+	if c.Connected != h.events.Connected || !h.seen {
+		h.events.Since = time.Now().Unix()
+	}
+
+	h.seen = true
+	h.events.Connected = c.Connected
+	h.events.Error = c.Error
+	return nil
+}
+
+func GetHealth(ctx context.Context, q *queries.GetHealth, h *health) (*v1.Health, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := &v1.Health{
+		Status: v1.HealthOK,
+		Events: h.events,
+	}
+
+	if !h.events.Connected {
+		result.Status = v1.HealthDegraded
+	}
+
+	return result, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/danielkrainas/csense/configuration"
 	"github.com/danielkrainas/csense/containers"
 	"github.com/danielkrainas/csense/containers/loader"
+	"github.com/danielkrainas/csense/hooks"
 	"github.com/danielkrainas/csense/queries"
 	"github.com/danielkrainas/csense/storage"
 	"github.com/danielkrainas/csense/storage/loader"
@@ -22,6 +23,9 @@ type Pack interface {
 type pack struct {
 	store      storage.Driver
 	containers containers.Driver
+	policy     *hooks.DestinationPolicy
+	verifier   *hooks.Verifier
+	health     *health
 }
 
 func (p *pack) Execute(ctx context.Context, q cqrs.Query) (interface{}, error) {
@@ -30,10 +34,26 @@ func (p *pack) Execute(ctx context.Context, q cqrs.Query) (interface{}, error) {
 		return FindHook(ctx, q, p.store.Hooks())
 	case *queries.SearchHooks:
 		return SearchHooks(ctx, q, p.store.Hooks())
+	case *queries.ResolveHook:
+		return ResolveHook(ctx, q, p.store.Receivers())
+	case *queries.FindReceiver:
+		return FindReceiver(ctx, q, p.store.Receivers())
+	case *queries.SearchReceivers:
+		return SearchReceivers(ctx, q, p.store.Receivers())
+	case *queries.FindSilence:
+		return FindSilence(ctx, q, p.store.Silences())
+	case *queries.SearchSilences:
+		return SearchSilences(ctx, q, p.store.Silences())
+	case *queries.SearchDeliveries:
+		return SearchDeliveries(ctx, q, p.store.Deliveries())
 	case *queries.GetContainer:
 		return GetContainer(ctx, q, p.containers)
 	case *queries.GetContainerEvents:
 		return GetContainerEvents(ctx, q, p.containers)
+	case *queries.GetHealth:
+		return GetHealth(ctx, q, p.health)
+	case *queries.GetClientCache:
+		return p.verifier.Clients, nil
 	}
 
 	return nil, cqrs.ErrNoExecutor
@@ -42,9 +62,27 @@ func (p *pack) Execute(ctx context.Context, q cqrs.Query) (interface{}, error) {
 func (p *pack) Handle(ctx context.Context, c cqrs.Command) error {
 	switch c := c.(type) {
 	case *commands.DeleteHook:
-		return DeleteHook(ctx, c, p.store.Hooks())
+		return DeleteHook(ctx, c, p.store.Hooks(), p.store.Deliveries())
 	case *commands.StoreHook:
-		return StoreHook(ctx, c, p.store.Hooks())
+		return StoreHook(ctx, c, p.store.Hooks(), p.store.Receivers(), p.policy, p.verifier.Clients)
+	case *commands.VerifyHook:
+		return VerifyHook(ctx, c, p.store.Hooks(), p.store.Receivers(), p.verifier)
+	case *commands.DeleteReceiver:
+		return DeleteReceiver(ctx, c, p.store.Receivers(), p.store.Hooks())
+	case *commands.StoreSilence:
+		return StoreSilence(ctx, c, p.store.Silences())
+	case *commands.DeleteSilence:
+		return DeleteSilence(ctx, c, p.store.Silences())
+	case *commands.PurgeSilences:
+		return PurgeSilences(ctx, c, p.store.Silences())
+	case *commands.StoreReceiver:
+		return StoreReceiver(ctx, c, p.store.Receivers(), p.policy, p.verifier.Clients)
+	case *commands.StoreDelivery:
+		return StoreDelivery(ctx, c, p.store.Deliveries())
+	case *commands.ReserveSequence:
+		return ReserveSequence(ctx, c, p.store.Deliveries())
+	case *commands.ReportEventsStatus:
+		return ReportEventsStatus(ctx, c, p.health)
 	}
 
 	return cqrs.ErrNoHandler
@@ -61,9 +99,25 @@ func FromConfig(config *configuration.Config) (Pack, error) {
 		return nil, err
 	}
 
+	return New(storageDriver, containersDriver, config)
+}
+
+// New returns the actions over the storage and containers drivers.
+func New(storageDriver storage.Driver, containersDriver containers.Driver, config *configuration.Config) (Pack, error) {
+	policy, err := hooks.PolicyFromConfig(config.Hooks.Destinations)
+	if err != nil {
+		return nil, err
+	}
+
 	p := &pack{
 		store:      storageDriver,
 		containers: containersDriver,
+		policy:     policy,
+		verifier: &hooks.Verifier{
+			Clients: hooks.NewClientCache(config.Hooks.Transport, policy),
+		},
+
+		health: &health{},
 	}
 
 	return p, nil
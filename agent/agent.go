@@ -2,31 +2,61 @@ package agent
 
 import (
 	"context"
-	"net/http"
+	"fmt"
+	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/danielkrainas/gobag/context"
 	"github.com/danielkrainas/gobag/decouple/cqrs"
+	"github.com/danielkrainas/gobag/util/uuid"
 
 	"github.com/danielkrainas/csense/actions"
 	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/configuration"
 	"github.com/danielkrainas/csense/containers"
 	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/hooks/formatting"
 	"github.com/danielkrainas/csense/queries"
 )
 
+// silenceRetention is how long expired silences are kept before they're
+// purged.
+const silenceRetention = 24 * time.Hour
+
+// Bounds of the wait before reopening the container events, doubled after
+// every attempt and reset once the events flowed for the longest wait.
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// snapshotDelay is how long changes to the tracked containers are batched
+// before the snapshot is saved.
+const snapshotDelay = 5 * time.Second
+
 type Agent struct {
 	context.Context
 	hookFilter hooks.Filter
 	shooter    hooks.Shooter
+	debouncer  *hooks.Debouncer
+	inventory  configuration.InventoryConfig
 	quitCh     chan struct{}
 	actions    actions.Pack
+
+	// queues holds the deliveries waiting by hook destination, and locks
+	// the hooks reserving a sequence number.
+	mutex  sync.Mutex
+	queues map[string]*deliveryQueue
+	locks  map[string]*hookLock
 }
 
 func (agent *Agent) Run() {
 	acontext.GetLogger(agent).Info("starting agent")
 	defer acontext.GetLogger(agent).Info("shutting down agent")
+	go agent.purgeSilences()
 	agent.ProcessEvents()
 
 	defer func() {
@@ -52,62 +82,360 @@ func (agent *Agent) runCommand(c cqrs.Command) error {
 	return agent.actions.Handle(agent, c)
 }
 
+// ProcessEvents reacts to container events until the agent quits. When the
+// events stream ends it's reopened with backoff, resyncing the tracked
+// containers, and the agent reports itself degraded in the meantime.
 func (agent *Agent) ProcessEvents() {
 	host := agent.getHostInfo()
-	containerEvents, err := agent.executeQuery(&queries.GetContainerEvents{
+	q := &queries.GetContainerEvents{
 		Types: []v1.ContainerEventType{
 			v1.EventContainerCreation,
 			v1.EventContainerDeletion,
 		},
-	})
 
+		Existed: agent.inventory.EmitExisted,
+	}
+
+	if agent.inventory.Snapshot != "" {
+		index, err := loadSnapshot(agent.inventory.Snapshot)
+		if err != nil {
+			acontext.GetLogger(agent).Errorf("error loading container snapshot: %v", err)
+		} else if index != nil {
+			acontext.GetLogger(agent).Infof("loaded snapshot of %d container(s)", len(index))
+			q.Index = index
+		}
+	}
+
+	backoff := minReconnectBackoff
+	for {
+		opened := time.Now()
+		err := agent.watch(q, host)
+		select {
+		case <-agent.quitCh:
+			return
+		default:
+		}
+
+		if err != nil {
+			acontext.GetLogger(agent).Errorf("error opening event channel: %v", err)
+			agent.reportEvents(false, err.Error())
+		} else {
+			acontext.GetLogger(agent).Warn("container event stream ended")
+			agent.reportEvents(false, "container event stream ended")
+		}
+
+		if time.Since(opened) > maxReconnectBackoff {
+			backoff = minReconnectBackoff
+		}
+
+		acontext.GetLogger(agent).Infof("reconnecting to containers driver in %v", backoff)
+		select {
+		case <-agent.quitCh:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// watch processes events until the channel is closed.
+func (agent *Agent) watch(q *queries.GetContainerEvents, host *v1.HostInfo) error {
+	containerEvents, err := agent.executeQuery(q)
 	if err != nil {
-		acontext.GetLogger(agent).Panicf("error opening event channel: %v", err)
+		return err
+	}
+
+	// existing containers are only reported the first time
+	q.Existed = false
+	var known map[string]*v1.ContainerInfo
+	if agent.inventory.Snapshot != "" {
+		// the tracker owns the index once events flow, keep a copy to save
+		known = make(map[string]*v1.ContainerInfo, len(q.Index))
+		for name, c := range q.Index {
+			known[name] = c
+		}
+
+		agent.saveSnapshot(known)
 	}
 
 	eventChan := containerEvents.(containers.EventsChannel)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-agent.quitCh:
+			eventChan.Close()
+		case <-stop:
+		}
+	}()
+
+	agent.reportEvents(true, "")
 	acontext.GetLogger(agent).Info("event monitor started")
 	defer acontext.GetLogger(agent).Info("event monitor stopped")
-	for event := range eventChan.GetChannel() {
-		var allHooks []*v1.Hook
-		if rawHooks, err := agent.executeQuery(&queries.SearchHooks{}); err != nil {
-			acontext.GetLogger(agent).Errorf("error getting hooks: %v", err)
-			continue
-		} else {
-			allHooks = rawHooks.([]*v1.Hook)
-		}
-
-		event.Container.State = v1.StateFromEvent(event.Type)
-		acontext.GetLogger(agent).Infof("processing %s event for container %s", event.Type, event.Container.Name)
-		matchedHooks := hooks.FilterAll(allHooks, event.Container, agent.hookFilter)
-		acontext.GetLogger(agent).Infof("matched %d hook(s)", len(matchedHooks))
-		for _, hook := range matchedHooks {
-			r := &v1.Reaction{
-				Container: event.Container,
-				Hook:      hook,
-				Host:      host,
-				Timestamp: time.Now().Unix(),
+	// changes are saved together once the delay passes, and when the
+	// events end
+	var flush <-chan time.Time
+	events := eventChan.GetChannel()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if flush != nil {
+					agent.saveSnapshot(known)
+				}
+
+				return nil
 			}
 
-			go func(hook *v1.Hook) {
-				acontext.GetLoggerWithField(agent, "hook.id", hook.ID).Debug("sending hook notification")
-				if err := agent.shooter.Fire(agent, r); err != nil {
-					acontext.GetLoggerWithField(agent, "hook.id", hook.ID).Errorf("error firing hook: %v", err)
-				}
-			}(hook)
+			agent.processEvent(event, host)
+			if known == nil {
+				continue
+			}
+
+			if event.Type == v1.EventContainerDeletion {
+				delete(known, event.Container.Name)
+			} else {
+				known[event.Container.Name] = event.Container
+			}
+
+			if flush == nil {
+				flush = time.After(snapshotDelay)
+			}
+
+		case <-flush:
+			flush = nil
+			agent.saveSnapshot(known)
 		}
 	}
 }
 
-func New(ctx context.Context, actionPack actions.Pack, quitCh chan struct{}) (*Agent, error) {
+func (agent *Agent) saveSnapshot(index map[string]*v1.ContainerInfo) {
+	if err := saveSnapshot(agent.inventory.Snapshot, index); err != nil {
+		acontext.GetLogger(agent).Errorf("error saving container snapshot: %v", err)
+	}
+}
+
+func (agent *Agent) reportEvents(connected bool, reason string) {
+	c := &commands.ReportEventsStatus{
+		Connected: connected,
+		Error:     reason,
+	}
+
+	if err := agent.runCommand(c); err != nil {
+		acontext.GetLogger(agent).Errorf("error reporting health: %v", err)
+	}
+}
+
+func (agent *Agent) processEvent(event *v1.ContainerEvent, host *v1.HostInfo) {
+	var allHooks []*v1.Hook
+	if rawHooks, err := agent.executeQuery(&queries.SearchHooks{}); err != nil {
+		acontext.GetLogger(agent).Errorf("error getting hooks: %v", err)
+		return
+	} else {
+		allHooks = rawHooks.([]*v1.Hook)
+	}
+
+	var silences []*v1.Silence
+	if rawSilences, err := agent.executeQuery(&queries.SearchSilences{}); err != nil {
+		acontext.GetLogger(agent).Errorf("error getting silences: %v", err)
+	} else {
+		silences = rawSilences.([]*v1.Silence)
+	}
+
+	acontext.GetLogger(agent).Infof("processing %s event for container %s", event.Type, event.Container.Name)
+	matchedHooks := hooks.FilterAll(allHooks, event.Container, agent.hookFilter)
+	acontext.GetLogger(agent).Infof("matched %d hook(s)", len(matchedHooks))
+	e := v1.EventFromContainerEvent(event.Type)
+	for _, hook := range matchedHooks {
+		if !hooks.Subscribed(hook, e) {
+			continue
+		}
+
+		r := &v1.Reaction{
+			SchemaVersion: v1.ReactionSchemaVersion,
+			ID:            uuid.Generate(),
+			Container:     event.Container,
+			Hook:          hook,
+			Host:          host,
+			Timestamp:     time.Now().Unix(),
+			Change: &v1.StateChange{
+				PreviousState: event.PreviousState,
+				State:         event.Container.State,
+				Source: &v1.ContainerEvent{
+					Type:      event.Type,
+					Timestamp: event.Timestamp,
+				},
+			},
+		}
+
+		if s := hooks.Silenced(silences, r, time.Now()); s != nil {
+			agent.suppress(r, s)
+			continue
+		}
+
+		agent.debouncer.Add(r)
+	}
+}
+
+// suppress records a delivery to each of the hook's destinations for a
+// reaction muted by a silence instead of firing it.
+func (agent *Agent) suppress(r *v1.Reaction, s *v1.Silence) {
+	log := acontext.GetLoggerWithFields(agent, map[interface{}]interface{}{
+		"hook.id":    r.Hook.ID,
+		"silence.id": s.ID,
+	})
+
+	log.Infof("reaction suppressed by silence %q", s.ID)
+	targets := r.Hook.Targets()
+	if resolved, err := agent.executeQuery(&queries.ResolveHook{Hook: r.Hook}); err != nil {
+		log.Errorf("error resolving hook receivers: %v", err)
+	} else {
+		targets = resolved.(*v1.Hook).Targets()
+	}
+
+	for _, dest := range targets {
+		d := &v1.Delivery{
+			ReactionID:  r.ID,
+			HookID:      r.Hook.ID,
+			Container:   r.Container.Name,
+			Destination: dest.Url,
+			Status:      v1.DeliverySuppressed,
+			SilenceID:   s.ID,
+			Timestamp:   r.Timestamp,
+		}
+
+		if err := agent.runCommand(&commands.StoreDelivery{Delivery: d}); err != nil {
+			log.Errorf("error storing delivery: %v", err)
+		}
+	}
+}
+
+// purgeSilences periodically removes silences that expired longer than the
+// retention period ago.
+func (agent *Agent) purgeSilences() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-agent.quitCh:
+			return
+		case t := <-ticker.C:
+			before := t.Add(-silenceRetention).Unix()
+			if err := agent.runCommand(&commands.PurgeSilences{Before: before}); err != nil {
+				acontext.GetLogger(agent).Errorf("error purging silences: %v", err)
+			}
+		}
+	}
+}
+
+func newShooter(config *configuration.Config, formatter *hooks.Formatter, policy *hooks.DestinationPolicy, clients *hooks.ClientCache) hooks.Shooter {
+	live := &hooks.LiveShooter{
+		Formatter: formatter,
+		Clients:   clients,
+	}
+
+	return &hooks.Router{
+		Shooters: map[string]hooks.Shooter{
+			"http":  live,
+			"https": live,
+			"exec": &hooks.ExecShooter{
+				Formatter: formatter,
+				Allowed:   config.Hooks.Exec.Allowed,
+				Timeout:   config.Hooks.Exec.Timeout,
+			},
+			"nats": &hooks.NATSShooter{
+				Formatter: formatter,
+				Policy:    policy,
+			},
+			"mqtt": &hooks.MQTTShooter{
+				Formatter: formatter,
+				Policy:    policy,
+			},
+			"kafka": &hooks.KafkaShooter{
+				Formatter: formatter,
+				Policy:    policy,
+			},
+			"syslog": &hooks.SyslogShooter{
+				Formatter: formatter,
+				Policy:    policy,
+				Sockets:   config.Hooks.Syslog.Sockets,
+			},
+			"file": &hooks.FileShooter{
+				Formatter: formatter,
+				Allowed:   config.Hooks.File.Allowed,
+			},
+			"mailto": &hooks.SMTPShooter{
+				Addr:     config.Hooks.SMTP.Addr,
+				From:     config.Hooks.SMTP.From,
+				Username: config.Hooks.SMTP.Username,
+				Password: config.Hooks.SMTP.Password,
+				StartTLS: config.Hooks.SMTP.StartTLS,
+			},
+		},
+
+		Policy: policy,
+	}
+}
+
+func hookURLBuilder(config configuration.HTTPConfig) (*v1.URLBuilder, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	_, port, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return v1.NewURLBuilderFromString("http://"+net.JoinHostPort(config.Host, port), false)
+}
+
+func New(ctx context.Context, config *configuration.Config, actionPack actions.Pack, quitCh chan struct{}) (*Agent, error) {
 	acontext.GetLogger(ctx).Info("initializing agent")
-	return &Agent{
+	urls, err := hookURLBuilder(config.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("error creating hook url builder: %v", err)
+	}
+
+	policy, err := hooks.PolicyFromConfig(config.Hooks.Destinations)
+	if err != nil {
+		return nil, fmt.Errorf("error creating destination policy: %v", err)
+	}
+
+	// deliveries share the clients of the actions' verifications
+	var clients *hooks.ClientCache
+	if cache, err := actionPack.Execute(ctx, &queries.GetClientCache{}); err == nil {
+		clients = cache.(*hooks.ClientCache)
+	} else {
+		clients = hooks.NewClientCache(config.Hooks.Transport, policy)
+	}
+
+	formatter := &hooks.Formatter{
+		Alertmanager: &formatting.Alertmanager{
+			URLs: urls,
+		},
+	}
+
+	agent := &Agent{
 		Context:    ctx,
 		actions:    actionPack,
 		quitCh:     quitCh,
 		hookFilter: &hooks.CriteriaFilter{},
-		shooter: &hooks.LiveShooter{
-			HttpClient: http.DefaultClient,
+		shooter:    newShooter(config, formatter, policy, clients),
+		inventory:  config.Inventory,
+		queues:     make(map[string]*deliveryQueue),
+		locks:      make(map[string]*hookLock),
+	}
+
+	agent.debouncer = &hooks.Debouncer{
+		Fire: agent.dispatch,
+		Drop: func(r *v1.Reaction) {
+			acontext.GetLoggerWithField(agent, "hook.id", r.Hook.ID).Infof("suppressed create and delete pair for container %s", r.Container.Name)
 		},
-	}, nil
+	}
+
+	return agent, nil
 }
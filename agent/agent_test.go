@@ -0,0 +1,304 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/gobag/decouple/cqrs"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/hooks/smtptest"
+	"github.com/danielkrainas/csense/queries"
+)
+
+// fakePack hands out sequence numbers, resolves receivers to their urls and
+// keeps the deliveries stored.
+type fakePack struct {
+	mutex      sync.Mutex
+	sequences  map[string]uint64
+	receivers  map[string]string
+	deliveries []v1.Delivery
+}
+
+func newFakePack() *fakePack {
+	return &fakePack{sequences: make(map[string]uint64)}
+}
+
+func (p *fakePack) stored() []v1.Delivery {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]v1.Delivery{}, p.deliveries...)
+}
+
+func (p *fakePack) Execute(ctx context.Context, q cqrs.Query) (interface{}, error) {
+	switch q := q.(type) {
+	case *queries.ResolveHook:
+		if len(q.Hook.Destinations) == 0 {
+			return q.Hook, nil
+		}
+
+		dupe := *q.Hook
+		dupe.Destinations = make([]*v1.Destination, 0)
+		for _, d := range q.Hook.Destinations {
+			if d.Receiver != "" {
+				d = &v1.Destination{Url: p.receivers[d.Receiver]}
+			}
+
+			dupe.Destinations = append(dupe.Destinations, d)
+		}
+
+		return &dupe, nil
+	}
+
+	return nil, cqrs.ErrNoExecutor
+}
+
+func (p *fakePack) Handle(ctx context.Context, c cqrs.Command) error {
+	switch c := c.(type) {
+	case *commands.ReserveSequence:
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.sequences[c.HookID]++
+		c.Sequence = p.sequences[c.HookID]
+		return nil
+	case *commands.StoreDelivery:
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.deliveries = append(p.deliveries, *c.Delivery)
+		return nil
+	}
+
+	return cqrs.ErrNoHandler
+}
+
+// slowShooter takes a random time to send and records the sequences sent to
+// every destination.
+type slowShooter struct {
+	mutex sync.Mutex
+	sent  map[string][]uint64
+	done  chan struct{}
+}
+
+func (s *slowShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent[r.Hook.Url] = append(s.sent[r.Hook.Url], r.Sequence)
+	s.done <- struct{}{}
+	return nil
+}
+
+func newTestAgent(pack *fakePack, shooter hooks.Shooter) *Agent {
+	return &Agent{
+		Context: context.Background(),
+		actions: pack,
+		shooter: shooter,
+		queues:  make(map[string]*deliveryQueue),
+		locks:   make(map[string]*hookLock),
+	}
+}
+
+func TestDispatchSendsInSequenceOrder(t *testing.T) {
+	const reactions = 30
+	shooter := &slowShooter{sent: make(map[string][]uint64), done: make(chan struct{}, 2*reactions)}
+	agent := newTestAgent(newFakePack(), shooter)
+
+	hook := &v1.Hook{
+		ID: "h1",
+		Destinations: []*v1.Destination{
+			{Url: "https://a.example.com"},
+			{Url: "https://b.example.com"},
+		},
+	}
+
+	for i := 0; i < reactions; i++ {
+		agent.dispatch(&v1.Reaction{Hook: hook, Container: &v1.ContainerInfo{Name: "web"}})
+	}
+
+	for i := 0; i < 2*reactions; i++ {
+		select {
+		case <-shooter.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of %d deliveries", i, 2*reactions)
+		}
+	}
+
+	shooter.mutex.Lock()
+	defer shooter.mutex.Unlock()
+	for url, sent := range shooter.sent {
+		for i, seq := range sent {
+			if seq != uint64(i+1) {
+				t.Fatalf("%s: got sequences %v", url, sent)
+			}
+		}
+	}
+}
+
+// failingShooter fails every delivery to the failing url and reports the
+// others.
+type failingShooter struct {
+	failing string
+	sent    chan *v1.Reaction
+}
+
+func (s *failingShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	if r.Hook.Url == s.failing {
+		return errors.New("destination down")
+	}
+
+	s.sent <- r
+	return nil
+}
+
+func TestRetriesWaitWithoutBlocking(t *testing.T) {
+	pack := newFakePack()
+	shooter := &failingShooter{failing: "https://down.example.com", sent: make(chan *v1.Reaction, 10)}
+	agent := newTestAgent(pack, shooter)
+	down := &v1.Hook{ID: "h1", Url: "https://down.example.com", Retry: &v1.RetryPolicy{Attempts: 3, Backoff: "100ms"}}
+	up := &v1.Hook{ID: "h2", Url: "https://up.example.com"}
+	for i := 0; i < 2; i++ {
+		agent.dispatch(&v1.Reaction{Hook: down, Container: &v1.ContainerInfo{Name: "web"}})
+	}
+
+	agent.dispatch(&v1.Reaction{Hook: up, Container: &v1.ContainerInfo{Name: "web"}})
+	select {
+	case r := <-shooter.sent:
+		if r.Hook.ID != "h2" {
+			t.Errorf("got reaction for hook %s", r.Hook.ID)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("a hook waiting to retry held up another hook")
+	}
+
+	// both reactions of the failing hook are retried in order
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pack.stored()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var sequences []uint64
+	for _, d := range pack.stored() {
+		if d.HookID != "h1" {
+			continue
+		}
+
+		if d.Status != v1.DeliveryFailed || d.Attempts != 3 {
+			t.Errorf("got delivery %d %s after %d attempts", d.Sequence, d.Status, d.Attempts)
+		}
+
+		sequences = append(sequences, d.Sequence)
+	}
+
+	if len(sequences) != 2 || sequences[0] != 1 || sequences[1] != 2 {
+		t.Errorf("got failed deliveries %v", sequences)
+	}
+}
+
+// blockingShooter holds every delivery until it's released.
+type blockingShooter struct {
+	release chan struct{}
+}
+
+func (s *blockingShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	<-s.release
+	return nil
+}
+
+func TestFullQueueRecordsFailures(t *testing.T) {
+	pack := newFakePack()
+	shooter := &blockingShooter{release: make(chan struct{})}
+	agent := newTestAgent(pack, shooter)
+	hook := &v1.Hook{ID: "h1", Url: "https://slow.example.com"}
+	for i := 0; i < maxQueuedReactions+2; i++ {
+		agent.dispatch(&v1.Reaction{Hook: hook, Container: &v1.ContainerInfo{Name: "web"}})
+	}
+
+	stored := pack.stored()
+	close(shooter.release)
+	if len(stored) != 2 {
+		t.Fatalf("got %d deliveries", len(stored))
+	}
+
+	for i, d := range stored {
+		if d.Status != v1.DeliveryFailed || d.Sequence != uint64(maxQueuedReactions+1+i) {
+			t.Errorf("got delivery %d %s", d.Sequence, d.Status)
+		}
+	}
+}
+
+func TestDispatchJoinsEmailDigests(t *testing.T) {
+	relay, err := smtptest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer relay.Close()
+	pack := newFakePack()
+	agent := newTestAgent(pack, &hooks.SMTPShooter{Addr: relay.Addr(), From: "csense@example.com"})
+	hook := &v1.Hook{ID: "h1", Url: "mailto:ops@example.com?digest=200ms"}
+	for _, name := range []string{"web", "db", "cache"} {
+		agent.dispatch(&v1.Reaction{
+			Hook:      hook,
+			Host:      &v1.HostInfo{Hostname: "node1"},
+			Container: &v1.ContainerInfo{Name: name, State: v1.StateRunning},
+		})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pack.stored()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, d := range pack.stored() {
+		if d.Status != v1.DeliverySucceeded || d.Attempts != 1 {
+			t.Errorf("got delivery %d %s after %d attempts: %s", d.Sequence, d.Status, d.Attempts, d.Error)
+		}
+	}
+
+	emails := relay.Emails()
+	if len(emails) != 1 {
+		t.Fatalf("got %d emails", len(emails))
+	}
+
+	if !strings.Contains(emails[0].Data, "(+2 more)") {
+		t.Errorf("got email %s", emails[0].Data)
+	}
+}
+
+func TestSuppressRecordsEveryDestination(t *testing.T) {
+	pack := newFakePack()
+	pack.receivers = map[string]string{"ops": "https://ops.example.com/hook"}
+	agent := newTestAgent(pack, nil)
+	r := &v1.Reaction{
+		ID: "r1",
+		Hook: &v1.Hook{
+			ID: "h1",
+			Destinations: []*v1.Destination{
+				{Url: "https://a.example.com/hook"},
+				{Receiver: "ops"},
+			},
+		},
+
+		Container: &v1.ContainerInfo{Name: "web"},
+	}
+
+	agent.suppress(r, &v1.Silence{ID: "s1"})
+	stored := pack.stored()
+	want := []string{"https://a.example.com/hook", "https://ops.example.com/hook"}
+	if len(stored) != len(want) {
+		t.Fatalf("got %d deliveries", len(stored))
+	}
+
+	for i, d := range stored {
+		if d.Destination != want[i] || d.Status != v1.DeliverySuppressed || d.SilenceID != "s1" || d.ReactionID != "r1" {
+			t.Errorf("got delivery %+v", d)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/gobag/context"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/queries"
+)
+
+// maxQueuedReactions is how many reactions may wait for a hook destination,
+// the reactions beyond that are recorded as failed deliveries.
+const maxQueuedReactions = 1000
+
+// delivery is a reaction on its way to a hook. It goes to a single
+// destination, or in failover mode to the next destination once one failed.
+type delivery struct {
+	reaction *v1.Reaction
+	targets  []*v1.Destination
+	target   int
+
+	// the reaction and record for the current destination, and what's left
+	// of its retry policy
+	current  *v1.Reaction
+	record   *v1.Delivery
+	attempts int
+	backoff  time.Duration
+}
+
+// deliveryQueue holds the deliveries waiting for a hook destination, they're
+// sent one at a time in sequence order.
+type deliveryQueue struct {
+	pending []*delivery
+	firing  bool
+}
+
+// hookLock orders the sequence reservations of a hook.
+type hookLock struct {
+	sync.Mutex
+	refs int
+}
+
+// dispatch reserves the next sequence number of the hook for the reaction and
+// queues it behind the hook's earlier reactions, for each destination or once
+// in failover mode.
+func (agent *Agent) dispatch(r *v1.Reaction) {
+	log := acontext.GetLoggerWithField(agent, "hook.id", r.Hook.ID)
+	resolved, err := agent.executeQuery(&queries.ResolveHook{Hook: r.Hook})
+	if err != nil {
+		log.Errorf("error resolving hook receivers: %v", err)
+		return
+	}
+
+	unlock := agent.lockHook(r.Hook.ID)
+	defer unlock()
+
+	seq := &commands.ReserveSequence{HookID: r.Hook.ID}
+	if err := agent.runCommand(seq); err != nil {
+		log.Errorf("error reserving sequence number: %v", err)
+		return
+	}
+
+	r.Sequence = seq.Sequence
+	targets := resolved.(*v1.Hook).Targets()
+	if r.Hook.Mode == v1.DeliveryModeFailover {
+		agent.enqueue(r.Hook.ID, agent.newDelivery(r, targets))
+		return
+	}
+
+	for _, dest := range targets {
+		agent.enqueue(r.Hook.ID+" "+dest.Url, agent.newDelivery(r, []*v1.Destination{dest}))
+	}
+}
+
+// lockHook locks the hook to reserve its next sequence number, and returns
+// the function unlocking it.
+func (agent *Agent) lockHook(hookID string) func() {
+	agent.mutex.Lock()
+	l, ok := agent.locks[hookID]
+	if !ok {
+		l = &hookLock{}
+		agent.locks[hookID] = l
+	}
+
+	l.refs++
+	agent.mutex.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		agent.mutex.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(agent.locks, hookID)
+		}
+
+		agent.mutex.Unlock()
+	}
+}
+
+func (agent *Agent) newDelivery(r *v1.Reaction, targets []*v1.Destination) *delivery {
+	d := &delivery{reaction: r, targets: targets}
+	agent.startTarget(d, 0)
+	return d
+}
+
+// startTarget moves the delivery on to the target destination with a fresh
+// record and retry policy.
+func (agent *Agent) startTarget(d *delivery, target int) {
+	dupe := *d.reaction
+	dupe.Hook = dupe.Hook.ForDestination(d.targets[target])
+	d.target = target
+	d.current = &dupe
+	d.record = &v1.Delivery{
+		ReactionID:  dupe.ID,
+		HookID:      dupe.Hook.ID,
+		Sequence:    dupe.Sequence,
+		Container:   dupe.Container.Name,
+		Destination: dupe.Hook.Url,
+		Timestamp:   dupe.Timestamp,
+	}
+
+	var err error
+	if d.attempts, d.backoff, err = hooks.ParseRetry(dupe.Hook.Retry); err != nil {
+		agent.deliveryLogger(d).Errorf("invalid retry policy: %v", err)
+		d.attempts = 1
+	}
+}
+
+func (agent *Agent) deliveryLogger(d *delivery) acontext.Logger {
+	return acontext.GetLoggerWithFields(agent, map[interface{}]interface{}{
+		"hook.id":          d.current.Hook.ID,
+		"hook.destination": d.current.Hook.Url,
+	})
+}
+
+// enqueue adds the delivery to the queue and starts sending when the queue
+// was idle. It's recorded as failed when the queue is full.
+func (agent *Agent) enqueue(key string, d *delivery) {
+	agent.mutex.Lock()
+	q, ok := agent.queues[key]
+	if !ok {
+		q = &deliveryQueue{}
+		agent.queues[key] = q
+	}
+
+	if len(q.pending) >= maxQueuedReactions {
+		agent.mutex.Unlock()
+		agent.deliveryLogger(d).Errorf("dropping reaction %d, %d reactions are waiting already", d.reaction.Sequence, len(q.pending))
+		d.record.Status = v1.DeliveryFailed
+		d.record.Error = "too many reactions waiting for the destination"
+		agent.storeDelivery(d)
+		return
+	}
+
+	q.pending = append(q.pending, d)
+	idle := !q.firing
+	q.firing = true
+	agent.mutex.Unlock()
+	if idle {
+		go agent.advance(key)
+	}
+}
+
+// advance sends the deliveries of the queue in order until it's empty, or
+// until the one in front has to wait to be retried.
+func (agent *Agent) advance(key string) {
+	for {
+		agent.mutex.Lock()
+		q := agent.queues[key]
+		if len(q.pending) == 0 {
+			delete(agent.queues, key)
+			agent.mutex.Unlock()
+			return
+		}
+
+		d := q.pending[0]
+		agent.mutex.Unlock()
+
+		if wait, retry := agent.attempt(d); retry {
+			if wait > 0 {
+				time.AfterFunc(wait, func() { agent.advance(key) })
+				return
+			}
+
+			continue
+		}
+
+		agent.mutex.Lock()
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		agent.mutex.Unlock()
+	}
+}
+
+// attempt sends the delivery once. Unless the delivery is done, or queued by
+// the shooter, it returns how long to wait before the next attempt.
+func (agent *Agent) attempt(d *delivery) (time.Duration, bool) {
+	err := agent.fire(d)
+	if err == hooks.ErrQueued {
+		return 0, false
+	}
+
+	return agent.result(d, err)
+}
+
+// fire sends the delivery to its current destination. Shooters queueing it
+// report the result later, when it's retried out of the queue's order if
+// needed.
+func (agent *Agent) fire(d *delivery) error {
+	agent.deliveryLogger(d).Debug("sending hook notification")
+	d.record.Attempts++
+	ctx := hooks.WithReport(hooks.WithDelivery(agent, d.record), func(err error) {
+		if wait, retry := agent.result(d, err); retry {
+			time.AfterFunc(wait, func() { agent.resend(d) })
+		}
+	})
+
+	return agent.shooter.Fire(ctx, d.current)
+}
+
+// resend retries a delivery that was queued by its shooter.
+func (agent *Agent) resend(d *delivery) {
+	if err := agent.fire(d); err != hooks.ErrQueued {
+		if wait, retry := agent.result(d, err); retry {
+			time.AfterFunc(wait, func() { agent.resend(d) })
+		}
+	}
+}
+
+// result records the outcome of an attempt. Unless the delivery is done it
+// returns how long to wait before the next attempt.
+func (agent *Agent) result(d *delivery, err error) (time.Duration, bool) {
+	if err == nil {
+		d.record.Status = v1.DeliverySucceeded
+		d.record.Error = ""
+		agent.storeDelivery(d)
+		return 0, false
+	}
+
+	log := agent.deliveryLogger(d)
+	log.Errorf("error firing hook (attempt %d of %d): %v", d.record.Attempts, d.attempts, err)
+	d.record.Status = v1.DeliveryFailed
+	d.record.Error = err.Error()
+	if d.record.Attempts < d.attempts {
+		wait := d.backoff
+		d.backoff = hooks.NextBackoff(d.backoff)
+		return wait, true
+	}
+
+	agent.storeDelivery(d)
+	if d.target+1 < len(d.targets) {
+		agent.startTarget(d, d.target+1)
+		return 0, true
+	}
+
+	if len(d.targets) > 1 {
+		log.Errorf("all %d destination(s) failed", len(d.targets))
+	}
+
+	return 0, false
+}
+
+func (agent *Agent) storeDelivery(d *delivery) {
+	if err := agent.runCommand(&commands.StoreDelivery{Delivery: d.record}); err != nil {
+		agent.deliveryLogger(d).Errorf("error storing delivery: %v", err)
+	}
+}
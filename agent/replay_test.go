@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/actions"
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/configuration"
+	"github.com/danielkrainas/csense/containers/driver/replay"
+	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/queries"
+	"github.com/danielkrainas/csense/storage"
+	"github.com/danielkrainas/csense/storage/driver/factory"
+	_ "github.com/danielkrainas/csense/storage/driver/inmemory"
+)
+
+// recordingShooter keeps the reactions fired.
+type recordingShooter struct {
+	mutex     sync.Mutex
+	reactions []*v1.Reaction
+	fired     chan struct{}
+}
+
+func (s *recordingShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	s.mutex.Lock()
+	s.reactions = append(s.reactions, r)
+	s.mutex.Unlock()
+	s.fired <- struct{}{}
+	return nil
+}
+
+// runReplayAgent starts an agent watching the replay driver, with a hook for
+// containers labelled `app: web`, once it's receiving events.
+func runReplayAgent(t *testing.T, d *replay.Driver) (*Agent, *recordingShooter, func()) {
+	config := &configuration.Config{}
+	base, err := factory.Create("inmemory", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := base.(storage.Driver)
+	hook := &v1.Hook{
+		ID:       "h1",
+		Name:     "web",
+		Url:      "https://example.com/hook",
+		Format:   v1.FormatJSON,
+		Criteria: &v1.Criteria{Labels: map[string]string{"app": "web"}},
+	}
+
+	if err := store.Hooks().Store(hook, true); err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := actions.New(store, d, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quitCh := make(chan struct{})
+	agent, err := New(context.Background(), config, pack, quitCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shooter := &recordingShooter{fired: make(chan struct{}, 10)}
+	agent.shooter = shooter
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		agent.ProcessEvents()
+	}()
+
+	waitConnected(t, agent, true)
+	return agent, shooter, func() {
+		close(quitCh)
+		<-stopped
+	}
+}
+
+// waitConnected waits for the agent's events health to be connected or not.
+func waitConnected(t *testing.T, agent *Agent, connected bool) {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		health, err := agent.executeQuery(&queries.GetHealth{})
+		if err == nil && health.(*v1.Health).Events.Connected == connected {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("agent events never connected=%v", connected)
+		}
+	}
+}
+
+// wait returns the reactions once n were fired.
+func (s *recordingShooter) wait(t *testing.T, n int) []*v1.Reaction {
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.fired:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of %d reactions", i, n)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*v1.Reaction{}, s.reactions...)
+}
+
+func checkReactions(t *testing.T, reactions []*v1.Reaction) {
+	want := []struct {
+		event v1.EventType
+		state v1.ContainerState
+	}{
+		{v1.EventCreate, v1.StateRunning},
+		{v1.EventDelete, v1.StateStopped},
+	}
+
+	if len(reactions) != len(want) {
+		t.Fatalf("got %d reactions", len(reactions))
+	}
+
+	for i, r := range reactions {
+		if r.Container.Name != "web" || r.Hook.ID != "h1" || r.Sequence != uint64(i+1) {
+			t.Errorf("reaction %d: got container %q, hook %q and sequence %d", i, r.Container.Name, r.Hook.ID, r.Sequence)
+		}
+
+		if r.Event() != want[i].event || r.Change.State != want[i].state {
+			t.Errorf("reaction %d: got event %q and state %q", i, r.Event(), r.Change.State)
+		}
+	}
+}
+
+func TestAgentReactsToEmittedEvents(t *testing.T) {
+	d := replay.New()
+	_, shooter, stop := runReplayAgent(t, d)
+	defer stop()
+
+	events := []*v1.ContainerEvent{
+		{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "db", Labels: map[string]string{"app": "db"}}},
+		{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "web", ImageName: "nginx", Labels: map[string]string{"app": "web"}}},
+		{Type: v1.EventContainerDeletion, Container: &v1.ContainerInfo{Name: "web", Labels: map[string]string{"app": "web"}}},
+	}
+
+	for _, e := range events {
+		if err := d.Emit(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkReactions(t, shooter.wait(t, 2))
+}
+
+func TestAgentReactsToScripts(t *testing.T) {
+	scripts := map[string]string{
+		replay.FormatJSONL: `{"type":"containerCreation","container":{"name":"db","labels":{"app":"db"}},"timestamp":1700000000}
+{"type":"containerCreation","container":{"name":"web","image_name":"nginx","labels":{"app":"web"}},"timestamp":1700000001}
+{"type":"containerDeletion","container":{"name":"web","labels":{"app":"web"}},"timestamp":1700000002}
+`,
+		replay.FormatYAML: `
+- type: containerCreation
+  container: {name: db, labels: {app: db}}
+  timestamp: 1700000000
+- type: containerCreation
+  container: {name: web, image_name: nginx, labels: {app: web}}
+  timestamp: 1700000001
+- type: containerDeletion
+  container: {name: web, labels: {app: web}}
+  timestamp: 1700000002
+`,
+	}
+
+	for format, script := range scripts {
+		events, err := replay.ReadEvents(strings.NewReader(script), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		d := replay.New()
+		_, shooter, stop := runReplayAgent(t, d)
+		if err := d.Play(context.Background(), events, replay.TimingInstant, 0); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		checkReactions(t, shooter.wait(t, 2))
+		stop()
+	}
+}
+
+func TestAgentResyncsAfterStreamEnds(t *testing.T) {
+	d := replay.New()
+	agent, shooter, stop := runReplayAgent(t, d)
+	defer stop()
+
+	web := &v1.ContainerInfo{ID: "1", Name: "web", Labels: map[string]string{"app": "web"}}
+	if err := d.Emit(context.Background(), &v1.ContainerEvent{Type: v1.EventContainerCreation, Container: web}); err != nil {
+		t.Fatal(err)
+	}
+
+	shooter.wait(t, 1)
+	d.Close()
+	waitConnected(t, agent, false)
+
+	// the containers change while nobody watches
+	api := &v1.ContainerInfo{ID: "2", Name: "api", Labels: map[string]string{"app": "web"}}
+	for _, e := range []*v1.ContainerEvent{
+		{Type: v1.EventContainerDeletion, Container: web},
+		{Type: v1.EventContainerCreation, Container: api},
+	} {
+		if err := d.Emit(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitConnected(t, agent, true)
+	reactions := shooter.wait(t, 2)[1:]
+	got := make(map[v1.EventType]string)
+	for _, r := range reactions {
+		got[r.Event()] = r.Container.Name
+	}
+
+	if got[v1.EventDelete] != "web" || got[v1.EventCreate] != "api" {
+		t.Errorf("got synthesized reactions %v", got)
+	}
+}
+
+func TestAgentSharesClientCache(t *testing.T) {
+	config := &configuration.Config{}
+	base, err := factory.Create("inmemory", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := actions.New(base.(storage.Driver), replay.New(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent, err := New(context.Background(), config, pack, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients, err := pack.Execute(context.Background(), &queries.GetClientCache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := agent.shooter.(*hooks.Router).Shooters["https"].(*hooks.LiveShooter)
+	if live.Clients != clients.(*hooks.ClientCache) {
+		t.Error("deliveries don't use the actions' clients")
+	}
+}
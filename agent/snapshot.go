@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// snapshot is the tracked containers saved so a restarted agent can report
+// what changed while it was down.
+type snapshot struct {
+	Timestamp  int64               `json:"timestamp"`
+	Containers []*v1.ContainerInfo `json:"containers"`
+}
+
+// loadSnapshot reads the containers saved at path by name. It returns nil
+// when nothing was saved yet.
+func loadSnapshot(path string) (map[string]*v1.ContainerInfo, error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*v1.ContainerInfo)
+	for _, c := range s.Containers {
+		index[c.Name] = c
+	}
+
+	return index, nil
+}
+
+// saveSnapshot replaces the snapshot at path, through a temporary file so a
+// crash doesn't leave it half written.
+func saveSnapshot(path string, index map[string]*v1.ContainerInfo) error {
+	s := &snapshot{
+		Timestamp:  time.Now().Unix(),
+		Containers: make([]*v1.ContainerInfo, 0, len(index)),
+	}
+
+	for _, c := range index {
+		s.Containers = append(s.Containers, c)
+	}
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// the data has to be on disk before the rename makes it the snapshot
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
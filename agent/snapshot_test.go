@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	if index, err := loadSnapshot(path); err != nil || index != nil {
+		t.Fatalf("got %v, %v before saving", index, err)
+	}
+
+	index := map[string]*v1.ContainerInfo{
+		"web": {Name: "web", ImageName: "nginx"},
+		"db":  {Name: "db", ImageName: "postgres"},
+	}
+
+	if err := saveSnapshot(path, index); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(index, "db")
+	if err := saveSnapshot(path, index); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != 1 || loaded["web"] == nil || loaded["web"].ImageName != "nginx" {
+		t.Errorf("got %v", loaded)
+	}
+
+	// the temporary files are renamed into place
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("got %d files", len(files))
+	}
+}
@@ -33,9 +33,11 @@ func main() {
 		Format: v1.FormatJSON,
 		Events: []v1.EventType{v1.EventCreate},
 		Criteria: &v1.Criteria{
-			ImageName: &v1.Condition{
-				Op:    v1.OperandEqual,
-				Value: "registry",
+			Fields: map[v1.ContainerField]*v1.Condition{
+				v1.FieldImageName: {
+					Op:    v1.OperandEqual,
+					Value: "registry",
+				},
 			},
 		},
 	})
@@ -24,8 +24,15 @@ func NewApi(actionPack actions.Pack) (*Api, error) {
 	}
 
 	api.register(v1.RouteNameBase, Base)
+	api.register(v1.RouteNameHealth, Health(actionPack))
 	api.register(v1.RouteNameHooks, Hooks(actionPack))
 	api.register(v1.RouteNameHook, HookMetadata(actionPack))
+	api.register(v1.RouteNameHookDeliveries, HookDeliveries(actionPack))
+	api.register(v1.RouteNameHookVerify, HookVerify(actionPack))
+	api.register(v1.RouteNameReceivers, Receivers(actionPack))
+	api.register(v1.RouteNameReceiver, ReceiverMetadata(actionPack))
+	api.register(v1.RouteNameSilences, Silences(actionPack))
+	api.register(v1.RouteNameSilence, SilenceMetadata(actionPack))
 
 	return api, nil
 }
@@ -34,8 +41,12 @@ func (api *Api) register(routeName string, dispatch http.HandlerFunc) {
 	api.router.GetRoute(routeName).Handler(api.dispatcher(dispatch))
 }
 
+// dispatcher refreshes the route variables once the router has matched the
+// request, they aren't known yet when the request context is created.
 func (api *Api) dispatcher(dispatch http.HandlerFunc) http.Handler {
-	return http.Handler(dispatch)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dispatch(w, r.WithContext(acontext.WithVars(r.Context(), r)))
+	})
 }
 
 func (api *Api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
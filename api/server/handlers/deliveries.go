@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/danielkrainas/gobag/context"
+
+	"github.com/danielkrainas/csense/actions"
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/queries"
+)
+
+func HookDeliveries(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		hookID := acontext.GetStringValue(ctx, "vars.hook_id")
+		if hookID == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if _, err := actionPack.Execute(ctx, &queries.FindHook{ID: hookID}); err != nil {
+			acontext.GetLogger(ctx).Warnf("hook %q not found", hookID)
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			GetHookDeliveries(hookID, actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func GetHookDeliveries(hookID string, actionPack actions.Pack, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("GetHookDeliveries begin")
+	defer log.Debug("GetHookDeliveries end")
+
+	deliveries, err := actionPack.Execute(ctx, &queries.SearchDeliveries{HookID: hookID})
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, err)
+		return
+	}
+
+	if err := v1.ServeJSON(w, deliveries); err != nil {
+		log.Errorf("error sending deliveries json: %v", err)
+	}
+}
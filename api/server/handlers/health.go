@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/danielkrainas/gobag/api/errcode"
+	"github.com/danielkrainas/gobag/context"
+
+	"github.com/danielkrainas/csense/actions"
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/queries"
+)
+
+func Health(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		log := acontext.GetLogger(ctx)
+		log.Debug("GetHealth begin")
+		defer log.Debug("GetHealth end")
+
+		health, err := actionPack.Execute(ctx, &queries.GetHealth{})
+		if err != nil {
+			log.Errorf("error getting health: %v", err)
+			acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+
+		realHealth := health.(*v1.Health)
+		w.Header().Set("Cache-Control", "no-cache")
+		if realHealth.Status == v1.HealthOK {
+			if err := v1.ServeJSON(w, realHealth); err != nil {
+				log.Errorf("error sending health json: %v", err)
+			}
+
+			return
+		}
+
+		// degraded is reported as unavailable for load balancers and probes
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(realHealth); err != nil {
+			log.Errorf("error sending health json: %v", err)
+		}
+	})
+}
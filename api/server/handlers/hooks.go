@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/danielkrainas/gobag/api/errcode"
@@ -14,6 +15,7 @@ import (
 	"github.com/danielkrainas/csense/actions"
 	"github.com/danielkrainas/csense/api/v1"
 	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/hooks"
 	"github.com/danielkrainas/csense/queries"
 )
 
@@ -34,6 +36,34 @@ func mergeHookUpdate(h *v1.Hook, r *v1.ModifyHookRequest) {
 		h.Format = r.Format
 	}
 
+	if r.Transport != nil {
+		h.Transport = r.Transport
+	}
+
+	if r.Auth != nil {
+		h.Auth = r.Auth
+	}
+
+	if r.Retry != nil {
+		h.Retry = r.Retry
+	}
+
+	if r.Destinations != nil {
+		h.Destinations = r.Destinations
+	}
+
+	if r.Mode != "" {
+		h.Mode = r.Mode
+	}
+
+	if r.Debounce != nil {
+		h.Debounce = r.Debounce
+	}
+
+	if r.Verify != nil {
+		h.Verify = *r.Verify
+	}
+
 	evlist := map[v1.EventType]bool{}
 	for _, e := range h.Events {
 		evlist[e] = true
@@ -57,10 +87,31 @@ func mergeHookUpdate(h *v1.Hook, r *v1.ModifyHookRequest) {
 	h.Events = results
 }
 
+func destinationUrls(h *v1.Hook) string {
+	urls := make([]string, 0)
+	for _, d := range h.Targets() {
+		urls = append(urls, d.Url)
+	}
+
+	return strings.Join(urls, " ")
+}
+
 func getHookLogger(ctx context.Context, hookID string) acontext.Logger {
 	return acontext.GetLoggerWithField(ctx, "hook.id", hookID)
 }
 
+// verifyLater verifies the hook in the background so that responses don't
+// wait on its destinations, the hook is pending verification until then.
+func verifyLater(ctx context.Context, c cqrs.CommandHandler, hook *v1.Hook) {
+	dupe := *hook
+	log := getHookLogger(ctx, hook.ID)
+	go func() {
+		if err := c.Handle(acontext.WithLogger(context.Background(), log), &commands.VerifyHook{Hook: &dupe}); err != nil {
+			log.Errorf("error verifying hook: %v", err)
+		}
+	}()
+}
+
 func Hooks(actionPack actions.Pack) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -115,7 +166,7 @@ func GetHook(hook *v1.Hook, w http.ResponseWriter, r *http.Request) {
 	log.Debug("GetHook begin")
 	defer log.Debug("GetHook end")
 
-	if err := v1.ServeJSON(w, hook); err != nil {
+	if err := v1.ServeJSON(w, hook.Redacted()); err != nil {
 		acontext.GetLogger(r.Context()).Errorf("error sending hook json: %v", err)
 	}
 }
@@ -136,6 +187,19 @@ func DeleteHook(hook *v1.Hook, c cqrs.CommandHandler, w http.ResponseWriter, r *
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func storeHookError(err error) error {
+	switch err := err.(type) {
+	case *hooks.DestinationError:
+		return v1.ErrorCodeHookDestinationDenied.WithDetail(err)
+	case *actions.UnknownReceiverError:
+		return v1.ErrorCodeHookReceiverUnknown.WithDetail(err)
+	case *actions.InvalidError:
+		return v1.ErrorCodeHookInvalid.WithDetail(err)
+	}
+
+	return errcode.ErrorCodeUnknown.WithDetail(err)
+}
+
 func ModifyHook(existingHook *v1.Hook, c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := acontext.GetLogger(ctx)
@@ -156,15 +220,28 @@ func ModifyHook(existingHook *v1.Hook, c cqrs.CommandHandler, w http.ResponseWri
 		return
 	}
 
+	previousUrls := destinationUrls(existingHook)
+	previousVerify := existingHook.Verify
 	mergeHookUpdate(existingHook, mr)
+	if !existingHook.Verify {
+		existingHook.Status = v1.HookActive
+		existingHook.VerificationError = ""
+	} else if destinationUrls(existingHook) != previousUrls || !previousVerify {
+		existingHook.Status = v1.HookPendingVerification
+	}
+
 	if err := c.Handle(ctx, &commands.StoreHook{Hook: existingHook}); err != nil {
 		log.Error(err)
-		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		acontext.TrackError(ctx, storeHookError(err))
 		return
 	}
 
+	if existingHook.Status == v1.HookPendingVerification {
+		verifyLater(ctx, c, existingHook)
+	}
+
 	getHookLogger(ctx, existingHook.ID).Infof("hook %q updated", existingHook.ID)
-	if err := v1.ServeJSON(w, existingHook); err != nil {
+	if err := v1.ServeJSON(w, existingHook.Redacted()); err != nil {
 		log.Errorf("error sending hook json: %v", err)
 	}
 }
@@ -190,23 +267,89 @@ func CreateHook(c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
 	}
 
 	hook := &v1.Hook{
-		Created:  time.Now().Unix(),
-		Name:     hr.Name,
-		Criteria: hr.Criteria,
-		TTL:      hr.TTL,
-		Events:   hr.Events,
-		Format:   hr.Format,
-		Url:      hr.Url,
+		Created:      time.Now().Unix(),
+		Name:         hr.Name,
+		Criteria:     hr.Criteria,
+		TTL:          hr.TTL,
+		Events:       hr.Events,
+		Format:       hr.Format,
+		Url:          hr.Url,
+		Transport:    hr.Transport,
+		Auth:         hr.Auth,
+		Retry:        hr.Retry,
+		Destinations: hr.Destinations,
+		Mode:         hr.Mode,
+		Debounce:     hr.Debounce,
+		Verify:       hr.Verify,
+		Status:       v1.HookActive,
+	}
+
+	if hook.Verify {
+		hook.Status = v1.HookPendingVerification
 	}
 
 	if err = c.Handle(ctx, &commands.StoreHook{Hook: hook, New: true}); err != nil {
 		log.Error(err)
-		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		acontext.TrackError(ctx, storeHookError(err))
 		return
 	}
 
+	if hook.Verify {
+		verifyLater(ctx, c, hook)
+	}
+
 	getHookLogger(ctx, hook.ID).Infof("hook %q created", hook.ID)
-	if err := v1.ServeJSON(w, hook); err != nil {
+	if err := v1.ServeJSON(w, hook.Redacted()); err != nil {
+		log.Errorf("error sending hook json: %v", err)
+	}
+}
+
+func HookVerify(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		hookID := acontext.GetStringValue(ctx, "vars.hook_id")
+		if hookID == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		hook, err := actionPack.Execute(ctx, &queries.FindHook{ID: hookID})
+		if err != nil {
+			acontext.GetLogger(ctx).Warnf("hook %q not found", hookID)
+			http.NotFound(w, r)
+			return
+		}
+
+		realHook, ok := hook.(*v1.Hook)
+		if !ok {
+			acontext.GetLogger(ctx).Warn("invalid hook data")
+			acontext.TrackError(ctx, errcode.ErrorCodeUnknown)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			VerifyHook(realHook, actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func VerifyHook(hook *v1.Hook, c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("VerifyHook begin")
+	defer log.Debug("VerifyHook end")
+
+	if err := c.Handle(ctx, &commands.VerifyHook{Hook: hook}); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	getHookLogger(ctx, hook.ID).Infof("hook %q verification %s", hook.ID, hook.Status)
+	if err := v1.ServeJSON(w, hook.Redacted()); err != nil {
 		log.Errorf("error sending hook json: %v", err)
 	}
 }
@@ -224,7 +367,12 @@ func GetAllHooks(q cqrs.QueryExecutor, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := v1.ServeJSON(w, hooks); err != nil {
+	redacted := make([]*v1.Hook, 0)
+	for _, hook := range hooks.([]*v1.Hook) {
+		redacted = append(redacted, hook.Redacted())
+	}
+
+	if err := v1.ServeJSON(w, redacted); err != nil {
 		log.Errorf("error sending hooks json: %v", err)
 	}
 }
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/danielkrainas/gobag/api/errcode"
+	"github.com/danielkrainas/gobag/context"
+	"github.com/danielkrainas/gobag/decouple/cqrs"
+
+	"github.com/danielkrainas/csense/actions"
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/hooks"
+	"github.com/danielkrainas/csense/queries"
+)
+
+func mergeReceiverUpdate(rc *v1.Receiver, r *v1.ModifyReceiverRequest) {
+	if r.Name != "" {
+		rc.Name = r.Name
+	}
+
+	if r.Url != "" {
+		rc.Url = r.Url
+	}
+
+	if r.Format != v1.FormatNone {
+		rc.Format = r.Format
+	}
+
+	if r.Transport != nil {
+		rc.Transport = r.Transport
+	}
+
+	if r.Auth != nil {
+		rc.Auth = r.Auth
+	}
+
+	if r.Retry != nil {
+		rc.Retry = r.Retry
+	}
+}
+
+func storeReceiverError(err error) error {
+	switch err := err.(type) {
+	case *hooks.DestinationError:
+		return v1.ErrorCodeHookDestinationDenied.WithDetail(err)
+	case *actions.InvalidError:
+		return v1.ErrorCodeReceiverInvalid.WithDetail(err)
+	}
+
+	return errcode.ErrorCodeUnknown.WithDetail(err)
+}
+
+func getReceiverLogger(ctx context.Context, receiverID string) acontext.Logger {
+	return acontext.GetLoggerWithField(ctx, "receiver.id", receiverID)
+}
+
+func Receivers(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			GetAllReceivers(actionPack, w, r)
+		case http.MethodPut:
+			CreateReceiver(actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func ReceiverMetadata(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		receiverID := acontext.GetStringValue(ctx, "vars.receiver_id")
+		if receiverID == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		receiver, err := actionPack.Execute(ctx, &queries.FindReceiver{ID: receiverID})
+		if err != nil {
+			acontext.GetLogger(ctx).Warnf("receiver %q not found", receiverID)
+			acontext.TrackError(ctx, v1.ErrorCodeReceiverUnknown)
+			return
+		}
+
+		realReceiver, ok := receiver.(*v1.Receiver)
+		if !ok {
+			acontext.GetLogger(ctx).Warn("invalid receiver data")
+			acontext.TrackError(ctx, errcode.ErrorCodeUnknown)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			GetReceiver(realReceiver, w, r)
+		case http.MethodPut:
+			ModifyReceiver(realReceiver, actionPack, w, r)
+		case http.MethodDelete:
+			DeleteReceiver(realReceiver, actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func GetReceiver(receiver *v1.Receiver, w http.ResponseWriter, r *http.Request) {
+	log := acontext.GetLogger(r.Context())
+	log.Debug("GetReceiver begin")
+	defer log.Debug("GetReceiver end")
+
+	if err := v1.ServeJSON(w, receiver.Redacted()); err != nil {
+		log.Errorf("error sending receiver json: %v", err)
+	}
+}
+
+func DeleteReceiver(receiver *v1.Receiver, c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("DeleteReceiver begin")
+	defer log.Debug("DeleteReceiver end")
+
+	if err := c.Handle(ctx, &commands.DeleteReceiver{ID: receiver.ID}); err == actions.ErrReceiverInUse {
+		log.Warnf("receiver %q is still referenced", receiver.ID)
+		acontext.TrackError(ctx, v1.ErrorCodeReceiverInUse)
+		return
+	} else if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	getReceiverLogger(ctx, receiver.ID).Infof("receiver %q deleted", receiver.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ModifyReceiver(existingReceiver *v1.Receiver, c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("ModifyReceiver begin")
+	defer log.Debug("ModifyReceiver end")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	mr := &v1.ModifyReceiverRequest{}
+	if err = json.Unmarshal(body, mr); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	mergeReceiverUpdate(existingReceiver, mr)
+	if err := c.Handle(ctx, &commands.StoreReceiver{Receiver: existingReceiver}); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, storeReceiverError(err))
+		return
+	}
+
+	getReceiverLogger(ctx, existingReceiver.ID).Infof("receiver %q updated", existingReceiver.ID)
+	if err := v1.ServeJSON(w, existingReceiver.Redacted()); err != nil {
+		log.Errorf("error sending receiver json: %v", err)
+	}
+}
+
+func CreateReceiver(c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("CreateReceiver begin")
+	defer log.Debug("CreateReceiver end")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	rr := &v1.NewReceiverRequest{}
+	if err = json.Unmarshal(body, rr); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	receiver := &v1.Receiver{
+		Created:   time.Now().Unix(),
+		Name:      rr.Name,
+		Url:       rr.Url,
+		Format:    rr.Format,
+		Transport: rr.Transport,
+		Auth:      rr.Auth,
+		Retry:     rr.Retry,
+	}
+
+	if err = c.Handle(ctx, &commands.StoreReceiver{Receiver: receiver, New: true}); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, storeReceiverError(err))
+		return
+	}
+
+	getReceiverLogger(ctx, receiver.ID).Infof("receiver %q created", receiver.ID)
+	if err := v1.ServeJSON(w, receiver.Redacted()); err != nil {
+		log.Errorf("error sending receiver json: %v", err)
+	}
+}
+
+func GetAllReceivers(q cqrs.QueryExecutor, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("GetAllReceivers begin")
+	defer log.Debug("GetAllReceivers end")
+
+	receivers, err := q.Execute(ctx, &queries.SearchReceivers{})
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, err)
+		return
+	}
+
+	redacted := make([]*v1.Receiver, 0)
+	for _, receiver := range receivers.([]*v1.Receiver) {
+		redacted = append(redacted, receiver.Redacted())
+	}
+
+	if err := v1.ServeJSON(w, redacted); err != nil {
+		log.Errorf("error sending receivers json: %v", err)
+	}
+}
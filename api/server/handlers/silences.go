@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/danielkrainas/gobag/api/errcode"
+	"github.com/danielkrainas/gobag/context"
+	"github.com/danielkrainas/gobag/decouple/cqrs"
+
+	"github.com/danielkrainas/csense/actions"
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/commands"
+	"github.com/danielkrainas/csense/queries"
+)
+
+func Silences(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			GetAllSilences(actionPack, w, r)
+		case http.MethodPut:
+			CreateSilence(actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func SilenceMetadata(actionPack actions.Pack) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		silenceID := acontext.GetStringValue(ctx, "vars.silence_id")
+		if silenceID == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		silence, err := actionPack.Execute(ctx, &queries.FindSilence{ID: silenceID})
+		if err != nil {
+			acontext.GetLogger(ctx).Warnf("silence %q not found", silenceID)
+			acontext.TrackError(ctx, v1.ErrorCodeSilenceUnknown)
+			return
+		}
+
+		realSilence, ok := silence.(*v1.Silence)
+		if !ok {
+			acontext.GetLogger(ctx).Warn("invalid silence data")
+			acontext.TrackError(ctx, errcode.ErrorCodeUnknown)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			GetSilence(realSilence, w, r)
+		case http.MethodDelete:
+			DeleteSilence(realSilence, actionPack, w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func GetSilence(silence *v1.Silence, w http.ResponseWriter, r *http.Request) {
+	log := acontext.GetLogger(r.Context())
+	log.Debug("GetSilence begin")
+	defer log.Debug("GetSilence end")
+
+	if err := v1.ServeJSON(w, silence); err != nil {
+		log.Errorf("error sending silence json: %v", err)
+	}
+}
+
+func DeleteSilence(silence *v1.Silence, c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("DeleteSilence begin")
+	defer log.Debug("DeleteSilence end")
+
+	if err := c.Handle(ctx, &commands.DeleteSilence{ID: silence.ID}); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	acontext.GetLoggerWithField(ctx, "silence.id", silence.ID).Infof("silence %q deleted", silence.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func CreateSilence(c cqrs.CommandHandler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("CreateSilence begin")
+	defer log.Debug("CreateSilence end")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	sr := &v1.NewSilenceRequest{}
+	if err = json.Unmarshal(body, sr); err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	now := time.Now()
+	silence := &v1.Silence{
+		Created:   now.Unix(),
+		Matchers:  sr.Matchers,
+		StartsAt:  sr.StartsAt,
+		EndsAt:    sr.EndsAt,
+		CreatedBy: sr.CreatedBy,
+		Comment:   sr.Comment,
+	}
+
+	if silence.StartsAt == 0 {
+		silence.StartsAt = now.Unix()
+	}
+
+	if err = c.Handle(ctx, &commands.StoreSilence{Silence: silence, New: true}); err != nil {
+		log.Error(err)
+		if ierr, ok := err.(*actions.InvalidError); ok {
+			acontext.TrackError(ctx, v1.ErrorCodeSilenceInvalid.WithDetail(ierr))
+		} else {
+			acontext.TrackError(ctx, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+
+		return
+	}
+
+	silence.Status = silence.StatusAt(now)
+	acontext.GetLoggerWithField(ctx, "silence.id", silence.ID).Infof("silence %q created", silence.ID)
+	if err := v1.ServeJSON(w, silence); err != nil {
+		log.Errorf("error sending silence json: %v", err)
+	}
+}
+
+func GetAllSilences(q cqrs.QueryExecutor, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := acontext.GetLogger(ctx)
+	log.Debug("GetAllSilences begin")
+	defer log.Debug("GetAllSilences end")
+
+	silences, err := q.Execute(ctx, &queries.SearchSilences{})
+	if err != nil {
+		log.Error(err)
+		acontext.TrackError(ctx, err)
+		return
+	}
+
+	if err := v1.ServeJSON(w, silences); err != nil {
+		log.Errorf("error sending silences json: %v", err)
+	}
+}
@@ -35,6 +35,22 @@ var (
 		Required:    true,
 	}
 
+	receiverIDParameter = describe.Parameter{
+		Name:        "receiver_id",
+		Type:        "string",
+		Description: "Identifier for the receiver",
+		Format:      IDRegex.String(),
+		Required:    true,
+	}
+
+	silenceIDParameter = describe.Parameter{
+		Name:        "silence_id",
+		Type:        "string",
+		Description: "Identifier for the silence",
+		Format:      IDRegex.String(),
+		Required:    true,
+	}
+
 	jsonContentLengthHeader = describe.Parameter{
 		Name:        "Content-Length",
 		Type:        "integer",
@@ -65,6 +81,142 @@ var (
 			ErrorCodeHookUnknown,
 		},
 	}
+
+	hookDestinationDeniedResp = describe.Response{
+		Name:        "Hook Destination Denied Error",
+		StatusCode:  http.StatusBadRequest,
+		Description: "The hook url is not permitted by the destination policy.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeHookDestinationDenied,
+		},
+	}
+
+	hookInvalidResp = describe.Response{
+		Name:        "Hook Invalid Error",
+		StatusCode:  http.StatusBadRequest,
+		Description: "The hook has an unknown delivery mode or an invalid retry policy.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeHookInvalid,
+		},
+	}
+
+	hookReceiverUnknownResp = describe.Response{
+		Name:        "Hook Receiver Unknown Error",
+		StatusCode:  http.StatusBadRequest,
+		Description: "A hook destination references an unknown receiver.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeHookReceiverUnknown,
+		},
+	}
+
+	receiverNotFoundResp = describe.Response{
+		Name:        "Receiver Unknown Error",
+		StatusCode:  http.StatusNotFound,
+		Description: "The receiver is not known to the server.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeReceiverUnknown,
+		},
+	}
+
+	receiverInUseResp = describe.Response{
+		Name:        "Receiver In Use Error",
+		StatusCode:  http.StatusConflict,
+		Description: "The receiver is still referenced by hooks.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeReceiverInUse,
+		},
+	}
+
+	receiverInvalidResp = describe.Response{
+		Name:        "Receiver Invalid Error",
+		StatusCode:  http.StatusBadRequest,
+		Description: "The receiver is missing its url or has an invalid retry policy.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeReceiverInvalid,
+		},
+	}
+
+	silenceNotFoundResp = describe.Response{
+		Name:        "Silence Unknown Error",
+		StatusCode:  http.StatusNotFound,
+		Description: "The silence is not known to the server.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeSilenceUnknown,
+		},
+	}
+
+	silenceInvalidResp = describe.Response{
+		Name:        "Silence Invalid Error",
+		StatusCode:  http.StatusBadRequest,
+		Description: "The silence has no matchers, an invalid matcher, or ends before it starts.",
+		Headers: []describe.Parameter{
+			versionHeader,
+			jsonContentLengthHeader,
+		},
+		Body: describe.Body{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []errcode.ErrorCode{
+			ErrorCodeSilenceInvalid,
+		},
+	}
 )
 
 var (
@@ -85,6 +237,79 @@ var (
 
 	hooksBody = `[
 ` + hookBody + `, ...
+]`
+
+	deliveryBody = `{
+    "id": <delivery id>,
+    "reaction_id": <reaction id>,
+    "hook_id": <hook id>,
+    "sequence": <per-hook sequence number>,
+    "container": <container name>,
+    "destination": <destination url>,
+    "status": "succeeded" | "failed" | "suppressed",
+    "silence_id": <id of the silence that suppressed it>,
+    "attempts": <number of attempts>,
+    "output": <captured output>,
+    "error": <error message>,
+    "timestamp": <unix timestamp>
+}`
+
+	receiverBody = `{
+    "id": <receiver id>,
+    "name": <receiver name>,
+    "created": <unix timestamp>,
+    "url": <destination url>,
+    "format": <body format>,
+    "transport": <transport settings>,
+    "auth": {
+        "username": <basic auth username>,
+        "password": <basic auth password, write-only>,
+        "token": <bearer token, write-only>
+    },
+    "retry": {
+        "attempts": <number of attempts, at most 10>,
+        "backoff": <initial delay between attempts, at most 5m>
+    }
+}`
+
+	receiversBody = `[
+` + receiverBody + `, ...
+]`
+
+	silenceBody = `{
+    "id": <silence id>,
+    "matchers": [
+        {
+            "field": "image" | "image_tag" | "name" | "host" | "hook_id" | "label.<key>",
+            "op": "equal" | "not_equal" | "match",
+            "value": <value>
+        },
+        ...
+    ],
+    "starts_at": <unix timestamp>,
+    "ends_at": <unix timestamp>,
+    "created_by": <author>,
+    "comment": <reason for the silence>,
+    "created": <unix timestamp>,
+    "status": "pending" | "active" | "expired"
+}`
+
+	healthBody = `{
+    "status": "ok" | "degraded",
+    "events": {
+        "connected": <receiving container events>,
+        "since": <unix timestamp of the last change>,
+        "reconnects": <times the events were reopened>,
+        "error": <why the events aren't received>
+    }
+}`
+
+	silencesBody = `[
+` + silenceBody + `, ...
+]`
+
+	deliveriesBody = `[
+` + deliveryBody + `, ...
 ]`
 )
 
@@ -135,6 +360,57 @@ var routeDescriptors = []describe.Route{
 			},
 		},
 	},
+	{
+		Name:        RouteNameHealth,
+		Path:        "/v1/health",
+		Entity:      "Health",
+		Description: "Route to check whether the agent is receiving container events.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get the agent's health",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The agent is receiving container events.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      healthBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							{
+								Description: "The agent is degraded, it's reconnecting to the containers driver.",
+								StatusCode:  http.StatusServiceUnavailable,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      healthBody,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        RouteNameHooks,
 		Path:        "/v1/hooks",
@@ -197,7 +473,11 @@ var routeDescriptors = []describe.Route{
 							},
 						},
 
-						Failures: []describe.Response{},
+						Failures: []describe.Response{
+							hookDestinationDeniedResp,
+							hookInvalidResp,
+							hookReceiverUnknownResp,
+						},
 					},
 				},
 			},
@@ -270,6 +550,9 @@ var routeDescriptors = []describe.Route{
 
 						Failures: []describe.Response{
 							hookNotFoundResp,
+							hookDestinationDeniedResp,
+							hookInvalidResp,
+							hookReceiverUnknownResp,
 						},
 					},
 				},
@@ -306,6 +589,413 @@ var routeDescriptors = []describe.Route{
 			},
 		},
 	},
+	{
+		Name:        RouteNameHookDeliveries,
+		Path:        "/v1/hooks/{hook_id:" + IDRegex.String() + "}/deliveries",
+		Entity:      "[]Delivery",
+		Description: "Route to retrieve the delivery history of an existing hook.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get the delivery history for a hook",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							hookIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The delivery history was returned successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      deliveriesBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							hookNotFoundResp,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameHookVerify,
+		Path:        "/v1/hooks/{hook_id:" + IDRegex.String() + "}/verify",
+		Entity:      "Hook",
+		Description: "Route to re-run the verification handshake of an existing hook.",
+		Methods: []describe.Method{
+			{
+				Method:      "POST",
+				Description: "Send a new challenge to the hook destination. The hook is active once the challenge is echoed back and pending verification otherwise.",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							hookIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The verification was attempted, the outcome is in the hook status.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      hookBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							hookNotFoundResp,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameReceivers,
+		Path:        "/v1/receivers",
+		Entity:      "[]Receiver",
+		Description: "Route to retrieve the list of receivers and create new ones.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get all receivers",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "All receivers were returned successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      receiversBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{},
+					},
+				},
+			},
+			{
+				Method:      "PUT",
+				Description: "Create a receiver",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "Receiver created",
+								StatusCode:  http.StatusCreated,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      receiverBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							hookDestinationDeniedResp,
+							receiverInvalidResp,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameReceiver,
+		Path:        "/v1/receivers/{receiver_id:" + IDRegex.String() + "}",
+		Entity:      "Receiver",
+		Description: "Route to remove, retrieve, and modify an existing receiver.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get a receiver",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							receiverIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The receiver was returned successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      receiverBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							receiverNotFoundResp,
+						},
+					},
+				},
+			},
+			{
+				Method:      "PUT",
+				Description: "Modify an existing receiver, hooks referencing it deliver with the new settings",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							receiverIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The receiver was modified successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      receiverBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							receiverNotFoundResp,
+							hookDestinationDeniedResp,
+							receiverInvalidResp,
+						},
+					},
+				},
+			},
+			{
+				Method:      "DELETE",
+				Description: "Remove a receiver that no hook references.",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							receiverIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The receiver was removed successfully.",
+								StatusCode:  http.StatusNoContent,
+								Headers: []describe.Parameter{
+									versionHeader,
+									zeroContentLengthHeader,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							receiverNotFoundResp,
+							receiverInUseResp,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameSilences,
+		Path:        "/v1/silences",
+		Entity:      "[]Silence",
+		Description: "Route to retrieve the list of silences and create new ones.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get all silences, including expired ones not yet purged",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "All silences were returned successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      silencesBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{},
+					},
+				},
+			},
+			{
+				Method:      "PUT",
+				Description: "Create a silence",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "Silence created",
+								StatusCode:  http.StatusCreated,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      silenceBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							silenceInvalidResp,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameSilence,
+		Path:        "/v1/silences/{silence_id:" + IDRegex.String() + "}",
+		Entity:      "Silence",
+		Description: "Route to retrieve and remove an existing silence.",
+		Methods: []describe.Method{
+			{
+				Method:      "GET",
+				Description: "Get a silence",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							silenceIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The silence was returned successfully.",
+								StatusCode:  http.StatusOK,
+								Headers: []describe.Parameter{
+									versionHeader,
+									jsonContentLengthHeader,
+								},
+
+								Body: describe.Body{
+									ContentType: "application/json; charset=utf-8",
+									Format:      silenceBody,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							silenceNotFoundResp,
+						},
+					},
+				},
+			},
+			{
+				Method:      "DELETE",
+				Description: "Remove a silence, notifications it muted are delivered again.",
+				Requests: []describe.Request{
+					{
+						Headers: []describe.Parameter{
+							hostHeader,
+						},
+
+						PathParameters: []describe.Parameter{
+							silenceIDParameter,
+						},
+
+						Successes: []describe.Response{
+							{
+								Description: "The silence was removed successfully.",
+								StatusCode:  http.StatusNoContent,
+								Headers: []describe.Parameter{
+									versionHeader,
+									zeroContentLengthHeader,
+								},
+							},
+						},
+
+						Failures: []describe.Response{
+							silenceNotFoundResp,
+						},
+					},
+				},
+			},
+		},
+	},
 }
 
 var routeDescriptorsMap map[string]describe.Route
@@ -15,4 +15,60 @@ var (
 		Description:    "This is returned if the hook ID used during an operation is unknown to the server.",
 		HTTPStatusCode: http.StatusNotFound,
 	})
+
+	ErrorCodeHookDestinationDenied = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "HOOK_DESTINATION_DENIED",
+		Message:        "hook destination denied by policy",
+		Description:    "This is returned if the hook url targets a scheme, host or address that the server's destination policy doesn't permit.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	ErrorCodeHookInvalid = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "HOOK_INVALID",
+		Message:        "hook settings are invalid",
+		Description:    "This is returned if a hook has an unknown delivery mode or an invalid retry policy.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	ErrorCodeHookReceiverUnknown = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "HOOK_RECEIVER_UNKNOWN",
+		Message:        "hook references an unknown receiver",
+		Description:    "This is returned if a hook destination references a receiver ID that is unknown to the server.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	ErrorCodeReceiverUnknown = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "RECEIVER_UNKNOWN",
+		Message:        "receiver not known to server",
+		Description:    "This is returned if the receiver ID used during an operation is unknown to the server.",
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	ErrorCodeReceiverInUse = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "RECEIVER_IN_USE",
+		Message:        "receiver is referenced by hooks",
+		Description:    "This is returned when deleting a receiver that hooks still deliver to.",
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	ErrorCodeSilenceUnknown = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "SILENCE_UNKNOWN",
+		Message:        "silence not known to server",
+		Description:    "This is returned if the silence ID used during an operation is unknown to the server.",
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	ErrorCodeSilenceInvalid = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "SILENCE_INVALID",
+		Message:        "silence settings are invalid",
+		Description:    "This is returned if a silence has no matchers, an invalid matcher or ends before it starts.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	ErrorCodeReceiverInvalid = errcode.Register(ErrorGroup, errcode.ErrorDescriptor{
+		Value:          "RECEIVER_INVALID",
+		Message:        "receiver settings are invalid",
+		Description:    "This is returned if a receiver is missing its url or has an invalid retry policy.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
 )
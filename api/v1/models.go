@@ -2,7 +2,10 @@ package v1
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Operand string
@@ -21,23 +24,42 @@ type Condition struct {
 }
 
 type Criteria struct {
-	Fields map[ContainerField]*Condition `json:"fields,omitempty"`
-	Labels map[string]string             `json:"labels,omitempty"`
+	Fields      map[ContainerField]*Condition `json:"fields,omitempty"`
+	Labels      map[string]string             `json:"labels,omitempty"`
+	Annotations map[string]string             `json:"annotations,omitempty"`
 }
 
 type ContainerField string
 
 var (
-	FieldName      ContainerField = "name"
-	FieldImageName ContainerField = "image_name"
+	FieldName            ContainerField = "name"
+	FieldImageName       ContainerField = "image_name"
+	FieldImageRegistry   ContainerField = "image_registry"
+	FieldImageRepository ContainerField = "image_repository"
+	FieldImageTag        ContainerField = "image_tag"
+	FieldImageDigest     ContainerField = "image_digest"
+	FieldID              ContainerField = "id"
+	FieldCreated         ContainerField = "created"
+	FieldStartedAt       ContainerField = "started_at"
+	FieldFinishedAt      ContainerField = "finished_at"
+	FieldExitCode        ContainerField = "exit_code"
+	FieldRestartCount    ContainerField = "restart_count"
+	FieldCommand         ContainerField = "command"
+	FieldPort            ContainerField = "port"
+	FieldNetwork         ContainerField = "network"
+	FieldIPAddress       ContainerField = "ip_address"
+	FieldCPUs            ContainerField = "cpus"
+	FieldCPUShares       ContainerField = "cpu_shares"
+	FieldMemoryLimit     ContainerField = "memory_limit"
 )
 
 type BodyFormat string
 
 var (
-	FormatNone      BodyFormat
-	FormatJSON      BodyFormat = "json"
-	FormatSlackJSON BodyFormat = "json+slack"
+	FormatNone         BodyFormat
+	FormatJSON         BodyFormat = "json"
+	FormatSlackJSON    BodyFormat = "json+slack"
+	FormatAlertmanager BodyFormat = "alertmanager"
 )
 
 type EventType string
@@ -45,66 +67,422 @@ type EventType string
 var (
 	EventCreate EventType = "create"
 	EventDelete EventType = "delete"
+	// EventExist is for containers found running when the agent started,
+	// hooks only receive it when subscribed.
+	EventExist EventType = "exist"
+)
+
+type TransportConfig struct {
+	CAFile     string `json:"ca_file,omitempty"`
+	CertFile   string `json:"cert_file,omitempty"`
+	KeyFile    string `json:"key_file,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	MinVersion string `json:"min_version,omitempty"`
+	Proxy      string `json:"proxy,omitempty"`
+}
+
+// AuthConfig holds credentials sent to http(s) destinations, basic auth
+// when a username is set and a bearer token otherwise.
+type AuthConfig struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// Redacted returns a copy without the password and token. Credentials are
+// write-only, API responses and reaction payloads leave them out.
+func (a *AuthConfig) Redacted() *AuthConfig {
+	if a == nil {
+		return nil
+	}
+
+	return &AuthConfig{Username: a.Username}
+}
+
+// RetryPolicy controls how often a failed delivery is attempted. The delay
+// between attempts starts at Backoff and doubles after every failure.
+type RetryPolicy struct {
+	Attempts int    `json:"attempts"`
+	Backoff  string `json:"backoff"`
+}
+
+// DebouncePolicy coalesces events of a hook for the same container. Window
+// is how long to wait for more events after the first one, SuppressPairs
+// drops a creation followed by a deletion within that duration.
+type DebouncePolicy struct {
+	Window        string `json:"window,omitempty"`
+	SuppressPairs string `json:"suppress_pairs,omitempty"`
+}
+
+// Destination is where a hook delivers to, either given inline or as a
+// reference to a stored Receiver.
+type Destination struct {
+	Receiver  string           `json:"receiver,omitempty"`
+	Url       string           `json:"url,omitempty"`
+	Format    BodyFormat       `json:"format,omitempty"`
+	Transport *TransportConfig `json:"transport,omitempty"`
+	Auth      *AuthConfig      `json:"auth,omitempty"`
+	Retry     *RetryPolicy     `json:"retry,omitempty"`
+}
+
+type Receiver struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Created   int64            `json:"created"`
+	Url       string           `json:"url"`
+	Format    BodyFormat       `json:"format"`
+	Transport *TransportConfig `json:"transport,omitempty"`
+	Auth      *AuthConfig      `json:"auth,omitempty"`
+	Retry     *RetryPolicy     `json:"retry,omitempty"`
+}
+
+// Redacted returns a copy of the receiver without its credentials.
+func (r *Receiver) Redacted() *Receiver {
+	dupe := *r
+	dupe.Auth = r.Auth.Redacted()
+	return &dupe
+}
+
+// Destination returns the receiver as a hook destination.
+func (r *Receiver) Destination() *Destination {
+	return &Destination{
+		Receiver:  r.ID,
+		Url:       r.Url,
+		Format:    r.Format,
+		Transport: r.Transport,
+		Auth:      r.Auth,
+		Retry:     r.Retry,
+	}
+}
+
+type NewReceiverRequest struct {
+	Name      string           `json:"name"`
+	Url       string           `json:"url"`
+	Format    BodyFormat       `json:"format"`
+	Transport *TransportConfig `json:"transport"`
+	Auth      *AuthConfig      `json:"auth"`
+	Retry     *RetryPolicy     `json:"retry"`
+}
+
+type ModifyReceiverRequest struct {
+	Name      string           `json:"name"`
+	Url       string           `json:"url"`
+	Format    BodyFormat       `json:"format"`
+	Transport *TransportConfig `json:"transport"`
+	Auth      *AuthConfig      `json:"auth"`
+	Retry     *RetryPolicy     `json:"retry"`
+}
+
+type DeliveryMode string
+
+const (
+	// DeliveryModeAll sends to every destination independently.
+	DeliveryModeAll DeliveryMode = "all"
+	// DeliveryModeFailover tries destinations in order until one succeeds.
+	DeliveryModeFailover DeliveryMode = "failover"
+)
+
+type HookStatus string
+
+const (
+	HookActive              HookStatus = "active"
+	HookPendingVerification HookStatus = "pending_verification"
 )
 
 type Hook struct {
-	ID       string      `json:"id"`
-	Name     string      `json:"name"`
-	Url      string      `json:"url"`
-	Events   []EventType `json:"events"`
-	Criteria *Criteria   `json:"criteria"`
-	TTL      int64       `json:"ttl"`
-	Created  int64       `json:"created"`
-	Format   BodyFormat  `json:"format"`
+	ID                string           `json:"id"`
+	Name              string           `json:"name"`
+	Url               string           `json:"url"`
+	Events            []EventType      `json:"events"`
+	Criteria          *Criteria        `json:"criteria"`
+	TTL               int64            `json:"ttl"`
+	Created           int64            `json:"created"`
+	Format            BodyFormat       `json:"format"`
+	Transport         *TransportConfig `json:"transport,omitempty"`
+	Auth              *AuthConfig      `json:"auth,omitempty"`
+	Retry             *RetryPolicy     `json:"retry,omitempty"`
+	Destinations      []*Destination   `json:"destinations,omitempty"`
+	Mode              DeliveryMode     `json:"mode,omitempty"`
+	Debounce          *DebouncePolicy  `json:"debounce,omitempty"`
+	Verify            bool             `json:"verify"`
+	Status            HookStatus       `json:"status,omitempty"`
+	VerificationError string           `json:"verification_error,omitempty"`
 }
 
 type ModifyHookRequest struct {
-	Name         string      `json:"name"`
-	Url          string      `json:"url"`
-	AddEvents    []EventType `json:"add_events"`
-	RemoveEvents []EventType `json:"remove_events"`
-	Criteria     *Criteria   `json:"criteria"`
-	Format       BodyFormat  `json:"format"`
+	Name         string           `json:"name"`
+	Url          string           `json:"url"`
+	AddEvents    []EventType      `json:"add_events"`
+	RemoveEvents []EventType      `json:"remove_events"`
+	Criteria     *Criteria        `json:"criteria"`
+	Format       BodyFormat       `json:"format"`
+	Transport    *TransportConfig `json:"transport"`
+	Auth         *AuthConfig      `json:"auth"`
+	Retry        *RetryPolicy     `json:"retry"`
+	Destinations []*Destination   `json:"destinations"`
+	Mode         DeliveryMode     `json:"mode"`
+	Debounce     *DebouncePolicy  `json:"debounce"`
+	Verify       *bool            `json:"verify"`
 }
 
 type NewHookRequest struct {
-	Name     string      `json:"name"`
-	Url      string      `json:"url"`
-	Events   []EventType `json:"events"`
-	Criteria *Criteria   `json:"criteria"`
-	TTL      int64       `json:"ttl"`
-	Format   BodyFormat  `json:"format"`
+	Name         string           `json:"name"`
+	Url          string           `json:"url"`
+	Events       []EventType      `json:"events"`
+	Criteria     *Criteria        `json:"criteria"`
+	TTL          int64            `json:"ttl"`
+	Format       BodyFormat       `json:"format"`
+	Transport    *TransportConfig `json:"transport"`
+	Auth         *AuthConfig      `json:"auth"`
+	Retry        *RetryPolicy     `json:"retry"`
+	Destinations []*Destination   `json:"destinations"`
+	Mode         DeliveryMode     `json:"mode"`
+	Debounce     *DebouncePolicy  `json:"debounce"`
+	Verify       bool             `json:"verify"`
+}
+
+// Redacted returns a copy of the hook without the credentials of the hook
+// and its destinations.
+func (h *Hook) Redacted() *Hook {
+	dupe := *h
+	dupe.Auth = h.Auth.Redacted()
+	if len(h.Destinations) > 0 {
+		dupe.Destinations = make([]*Destination, len(h.Destinations))
+		for i, d := range h.Destinations {
+			dd := *d
+			dd.Auth = d.Auth.Redacted()
+			dupe.Destinations[i] = &dd
+		}
+	}
+
+	return &dupe
 }
 
+// Targets returns the destinations of the hook, hooks without a destination
+// list deliver to their own url.
+func (h *Hook) Targets() []*Destination {
+	if len(h.Destinations) > 0 {
+		return h.Destinations
+	}
+
+	return []*Destination{
+		{
+			Url:       h.Url,
+			Format:    h.Format,
+			Transport: h.Transport,
+			Auth:      h.Auth,
+			Retry:     h.Retry,
+		},
+	}
+}
+
+// ForDestination returns a copy of the hook that delivers to d only. The
+// destination falls back to the hook's format and transport settings.
+func (h *Hook) ForDestination(d *Destination) *Hook {
+	dupe := *h
+	dupe.Url = d.Url
+	dupe.Destinations = nil
+	if d.Format != FormatNone {
+		dupe.Format = d.Format
+	}
+
+	if d.Transport != nil {
+		dupe.Transport = d.Transport
+	}
+
+	if d.Auth != nil {
+		dupe.Auth = d.Auth
+	}
+
+	if d.Retry != nil {
+		dupe.Retry = d.Retry
+	}
+
+	return &dupe
+}
+
+// ReactionSchemaVersion is bumped whenever the reaction payload changes in a
+// way receivers need to know about. Payloads without a version are from
+// before the version was introduced.
+const ReactionSchemaVersion = 2
+
 type Reaction struct {
-	Timestamp int64          `json:"timestamp"`
-	Hook      *Hook          `json:"hook"`
-	Host      *HostInfo      `json:"host"`
-	Container *ContainerInfo `json:"container"`
+	SchemaVersion int            `json:"schema_version"`
+	ID            string         `json:"id"`
+	Sequence      uint64         `json:"sequence"`
+	Timestamp     int64          `json:"timestamp"`
+	Hook          *Hook          `json:"hook"`
+	Host          *HostInfo      `json:"host"`
+	Container     *ContainerInfo `json:"container"`
+	Change        *StateChange   `json:"change"`
+	Coalesced     int            `json:"coalesced,omitempty"`
+}
+
+// MarshalJSON leaves the hook's credentials out of reaction payloads.
+func (r Reaction) MarshalJSON() ([]byte, error) {
+	type reaction Reaction
+	if r.Hook != nil {
+		r.Hook = r.Hook.Redacted()
+	}
+
+	return json.Marshal(reaction(r))
+}
+
+type DeliveryStatus string
+
+const (
+	DeliverySucceeded  DeliveryStatus = "succeeded"
+	DeliveryFailed     DeliveryStatus = "failed"
+	DeliverySuppressed DeliveryStatus = "suppressed"
+)
+
+type Delivery struct {
+	ID          string         `json:"id"`
+	ReactionID  string         `json:"reaction_id"`
+	HookID      string         `json:"hook_id"`
+	Sequence    uint64         `json:"sequence"`
+	Container   string         `json:"container"`
+	Destination string         `json:"destination"`
+	Status      DeliveryStatus `json:"status"`
+	SilenceID   string         `json:"silence_id,omitempty"`
+	Attempts    int            `json:"attempts"`
+	Output      string         `json:"output,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	Timestamp   int64          `json:"timestamp"`
+}
+
+// Matcher fields besides the container fields of criteria, labels and
+// annotations are matched with a `label.` or `annotation.` prefix such as
+// `label.com.example.team`.
+const (
+	MatcherFieldImageTag    = "image_tag"
+	MatcherFieldHost        = "host"
+	MatcherFieldHookID      = "hook_id"
+	MatcherLabelPrefix      = "label."
+	MatcherAnnotationPrefix = "annotation."
+)
+
+type Matcher struct {
+	Field string  `json:"field"`
+	Op    Operand `json:"op"`
+	Value string  `json:"value"`
+}
+
+type SilenceStatus string
+
+const (
+	SilencePending SilenceStatus = "pending"
+	SilenceActive  SilenceStatus = "active"
+	SilenceExpired SilenceStatus = "expired"
+)
+
+// Silence mutes reactions matching all of its matchers between StartsAt and
+// EndsAt.
+type Silence struct {
+	ID        string        `json:"id"`
+	Matchers  []*Matcher    `json:"matchers"`
+	StartsAt  int64         `json:"starts_at"`
+	EndsAt    int64         `json:"ends_at"`
+	CreatedBy string        `json:"created_by"`
+	Comment   string        `json:"comment"`
+	Created   int64         `json:"created"`
+	Status    SilenceStatus `json:"status,omitempty"`
+}
+
+func (s *Silence) StatusAt(t time.Time) SilenceStatus {
+	now := t.Unix()
+	if now < s.StartsAt {
+		return SilencePending
+	} else if now >= s.EndsAt {
+		return SilenceExpired
+	}
+
+	return SilenceActive
+}
+
+type NewSilenceRequest struct {
+	Matchers  []*Matcher `json:"matchers"`
+	StartsAt  int64      `json:"starts_at"`
+	EndsAt    int64      `json:"ends_at"`
+	CreatedBy string     `json:"created_by"`
+	Comment   string     `json:"comment"`
 }
 
 type HostInfo struct {
 	Hostname string `json:"hostname"`
 }
 
+// ContainerInfo is what a driver knows about a container, fields it can't
+// supply are left empty. Times are unix timestamps.
 type ContainerInfo struct {
-	Name      string            `json:"name"`
-	ImageName string            `json:"image_name"`
-	ImageTag  string            `json:"image_tag"`
-	Labels    map[string]string `json:"labels"`
-	State     ContainerState    `json:"state"`
+	ID              string            `json:"id,omitempty"`
+	Name            string            `json:"name"`
+	Aliases         []string          `json:"aliases,omitempty"`
+	CgroupPath      string            `json:"cgroup_path,omitempty"`
+	ImageName       string            `json:"image_name"`
+	ImageRegistry   string            `json:"image_registry,omitempty"`
+	ImageRepository string            `json:"image_repository,omitempty"`
+	ImageTag        string            `json:"image_tag"`
+	ImageDigest     string            `json:"image_digest,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	State           ContainerState    `json:"state"`
+	Created         int64             `json:"created,omitempty"`
+	StartedAt       int64             `json:"started_at,omitempty"`
+	FinishedAt      int64             `json:"finished_at,omitempty"`
+	ExitCode        *int              `json:"exit_code,omitempty"`
+	RestartCount    *int              `json:"restart_count,omitempty"`
+	Reason          string            `json:"reason,omitempty"`
+	Command         []string          `json:"command,omitempty"`
+	Ports           []*PortMapping    `json:"ports,omitempty"`
+	Networks        []*NetworkInfo    `json:"networks,omitempty"`
+	Resources       *ResourceLimits   `json:"resources,omitempty"`
+	Runtime         string            `json:"runtime,omitempty"`
+}
+
+// PortMapping is a container port, published when HostPort is set.
+type PortMapping struct {
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+}
+
+// String formats the port like `80/tcp` or `0.0.0.0:8080->80/tcp`.
+func (p *PortMapping) String() string {
+	port := strconv.Itoa(p.ContainerPort) + "/" + p.Protocol
+	if p.HostPort == 0 {
+		return port
+	}
+
+	return net.JoinHostPort(p.HostIP, strconv.Itoa(p.HostPort)) + "->" + port
+}
+
+type NetworkInfo struct {
+	Name        string   `json:"name"`
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+}
+
+// ResourceLimits are the limits a container runs with, CPUs is the share of
+// cores the container may use.
+type ResourceLimits struct {
+	CPUs        float64 `json:"cpus,omitempty"`
+	CPUShares   int64   `json:"cpu_shares,omitempty"`
+	MemoryLimit int64   `json:"memory_limit,omitempty"`
 }
 
 type StateChange struct {
-	State     ContainerState  `json:"state"`
-	Source    *ContainerEvent `json:"source_event"`
-	Container *ContainerInfo  `json:"container"`
+	PreviousState ContainerState  `json:"previous_state"`
+	State         ContainerState  `json:"state"`
+	Source        *ContainerEvent `json:"source_event"`
+	Container     *ContainerInfo  `json:"container,omitempty"`
 }
 
 type ContainerEvent struct {
-	Type      ContainerEventType `json:"type"`
-	Container *ContainerInfo     `json:"container"`
-	Timestamp int64              `json:"timestamp"`
+	Type          ContainerEventType `json:"type"`
+	Container     *ContainerInfo     `json:"container,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	PreviousState ContainerState     `json:"previous_state,omitempty"`
 }
 
 type ContainerState string
@@ -127,7 +505,7 @@ const (
 
 func StateFromEvent(eventType ContainerEventType) ContainerState {
 	switch eventType {
-	case EventContainerCreation:
+	case EventContainerCreation, EventContainerExisted:
 		return StateRunning
 	case EventContainerDeletion:
 		return StateStopped
@@ -136,6 +514,67 @@ func StateFromEvent(eventType ContainerEventType) ContainerState {
 	return StateUnknown
 }
 
+func EventFromState(state ContainerState) EventType {
+	switch state {
+	case StateRunning:
+		return EventCreate
+	case StateStopped:
+		return EventDelete
+	}
+
+	return ""
+}
+
+// EventFromContainerEvent returns the hook event for a container event, or
+// an empty string for events hooks can't subscribe to.
+func EventFromContainerEvent(eventType ContainerEventType) EventType {
+	switch eventType {
+	case EventContainerCreation:
+		return EventCreate
+	case EventContainerDeletion:
+		return EventDelete
+	case EventContainerExisted:
+		return EventExist
+	}
+
+	return ""
+}
+
+// Event returns the hook event of the reaction, from its source event or
+// else from the container's state.
+func (r *Reaction) Event() EventType {
+	if r.Change != nil && r.Change.Source != nil {
+		if e := EventFromContainerEvent(r.Change.Source.Type); e != "" {
+			return e
+		}
+	}
+
+	return EventFromState(r.Container.State)
+}
+
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded"
+)
+
+// Health is the agent's status, degraded while it isn't receiving container
+// events.
+type Health struct {
+	Status HealthStatus `json:"status"`
+	Events EventsHealth `json:"events"`
+}
+
+// EventsHealth is the state of the container events stream since the last
+// change.
+type EventsHealth struct {
+	Connected  bool   `json:"connected"`
+	Since      int64  `json:"since,omitempty"`
+	Reconnects int    `json:"reconnects"`
+	Error      string `json:"error,omitempty"`
+}
+
 func ServeJSON(w http.ResponseWriter, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
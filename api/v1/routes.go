@@ -3,9 +3,16 @@ package v1
 import "github.com/gorilla/mux"
 
 const (
-	RouteNameBase  = "base"
-	RouteNameHooks = "hooks"
-	RouteNameHook  = "hook"
+	RouteNameBase           = "base"
+	RouteNameHealth         = "health"
+	RouteNameHooks          = "hooks"
+	RouteNameHook           = "hook"
+	RouteNameHookDeliveries = "hook_deliveries"
+	RouteNameHookVerify     = "hook_verify"
+	RouteNameReceivers      = "receivers"
+	RouteNameReceiver       = "receiver"
+	RouteNameSilences       = "silences"
+	RouteNameSilence        = "silence"
 )
 
 func Router() *mux.Router {
@@ -91,6 +91,17 @@ func (ub *URLBuilder) BuildHooks() (string, error) {
 	return routeUrl.String(), nil
 }
 
+func (ub *URLBuilder) BuildHook(id string) (string, error) {
+	route := ub.cloneRoute(RouteNameHook)
+
+	routeUrl, err := route.URL("hook_id", id)
+	if err != nil {
+		return "", err
+	}
+
+	return routeUrl.String(), nil
+}
+
 type clonedRoute struct {
 	*mux.Route
 
@@ -48,7 +48,7 @@ func run(ctx context.Context, args []string) error {
 		go runHTTPServer(ctx, config.HTTP, actionPack, quitCh)
 	}
 
-	go runAgent(ctx, actionPack, quitCh)
+	go runAgent(ctx, config, actionPack, quitCh)
 	go handleSignals(ctx, quitCh)
 	<-quitCh
 	return nil
@@ -76,8 +76,8 @@ func runHTTPServer(ctx context.Context, config configuration.HTTPConfig, actionP
 	}
 }
 
-func runAgent(ctx context.Context, actionPack actions.Pack, quitCh chan struct{}) {
-	agent, err := agent.New(ctx, actionPack, quitCh)
+func runAgent(ctx context.Context, config *configuration.Config, actionPack actions.Pack, quitCh chan struct{}) {
+	agent, err := agent.New(ctx, config, actionPack, quitCh)
 	if err != nil {
 		acontext.GetLogger(ctx).Fatalf("error starting agent: %v", err)
 		return
@@ -12,3 +12,48 @@ type StoreHook struct {
 	New  bool
 	Hook *v1.Hook
 }
+
+type DeleteReceiver struct {
+	ID string
+}
+
+type StoreReceiver struct {
+	New      bool
+	Receiver *v1.Receiver
+}
+
+type DeleteSilence struct {
+	ID string
+}
+
+type StoreSilence struct {
+	New     bool
+	Silence *v1.Silence
+}
+
+// PurgeSilences removes silences that ended before the given unix time.
+type PurgeSilences struct {
+	Before int64
+}
+
+type VerifyHook struct {
+	Hook *v1.Hook
+}
+
+// ReserveSequence takes the next delivery sequence number for a hook, the
+// number is written to Sequence.
+type ReserveSequence struct {
+	HookID   string
+	Sequence uint64
+}
+
+type StoreDelivery struct {
+	Delivery *v1.Delivery
+}
+
+// ReportEventsStatus records whether the agent is receiving container events
+// and why not.
+type ReportEventsStatus struct {
+	Connected bool
+	Error     string
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
+	"time"
 
 	cfg "github.com/danielkrainas/gobag/configuration"
+	"gopkg.in/yaml.v2"
 )
 
 type LogConfig struct {
@@ -29,11 +33,152 @@ type HTTPConfig struct {
 	CORS    CORSConfig `yaml:"cors"`
 }
 
+type ExecConfig struct {
+	Allowed []string      `yaml:"allowed"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type FileConfig struct {
+	Allowed []string `yaml:"allowed"`
+}
+
+type SyslogConfig struct {
+	// unix sockets hooks may send to, only /dev/log when empty
+	Sockets []string `yaml:"sockets"`
+}
+
+type SMTPConfig struct {
+	Addr     string `yaml:"addr"`
+	From     string `yaml:"from"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	StartTLS bool   `yaml:"starttls"`
+}
+
+type TransportConfig struct {
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+	MinVersion string `yaml:"min_version"`
+	Proxy      string `yaml:"proxy"`
+	// directories hooks may load CA bundles and client certificates from
+	AllowedDirs []string      `yaml:"allowed_dirs"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+type DestinationsConfig struct {
+	AllowedSchemes []string `yaml:"allowed_schemes"`
+	DeniedSchemes  []string `yaml:"denied_schemes"`
+	AllowedHosts   []string `yaml:"allowed_hosts"`
+	DeniedHosts    []string `yaml:"denied_hosts"`
+	AllowedCIDRs   []string `yaml:"allowed_cidrs"`
+	DeniedCIDRs    []string `yaml:"denied_cidrs"`
+	AllowPrivate   bool     `yaml:"allow_private"`
+}
+
+type HooksConfig struct {
+	Destinations DestinationsConfig `yaml:"destinations"`
+	Transport    TransportConfig    `yaml:"transport"`
+	Exec         ExecConfig         `yaml:"exec"`
+	File         FileConfig         `yaml:"file"`
+	Syslog       SyslogConfig       `yaml:"syslog"`
+	SMTP         SMTPConfig         `yaml:"smtp"`
+}
+
+// ContainersConfig is the containers drivers to use, written as a single
+// driver or a list of them.
+type ContainersConfig []cfg.Driver
+
+func (c *ContainersConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var drivers []cfg.Driver
+	if err := unmarshal(&drivers); err == nil {
+		*c = drivers
+		return nil
+	}
+
+	var driver cfg.Driver
+	if err := unmarshal(&driver); err != nil {
+		return err
+	}
+
+	*c = ContainersConfig{driver}
+	return nil
+}
+
+// override applies `CSENSE_CONTAINERS_<DRIVER>_<PARAM>` variables to the
+// configured drivers. The environment parser only walks structs and maps so
+// it skips the drivers list. The rest of the name is the parameter, so
+// `CSENSE_CONTAINERS_CRI_POLL_INTERVAL` sets the cri driver's `poll_interval`.
+func (c ContainersConfig) override(environ []string) error {
+	const prefix = "CSENSE_CONTAINERS_"
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(parts[0], prefix)
+		for _, d := range c {
+			driverPrefix := strings.ToUpper(d.Type()) + "_"
+			if !strings.HasPrefix(name, driverPrefix) || len(name) == len(driverPrefix) {
+				continue
+			}
+
+			var value interface{}
+			if err := yaml.Unmarshal([]byte(parts[1]), &value); err != nil {
+				return fmt.Errorf("%s: %v", parts[0], err)
+			}
+
+			if d[d.Type()] == nil {
+				d[d.Type()] = cfg.Parameters{}
+			}
+
+			d[d.Type()][strings.ToLower(strings.TrimPrefix(name, driverPrefix))] = value
+		}
+	}
+
+	return nil
+}
+
+// Names returns the runtime names in order, a driver's `name` parameter or
+// its type when it has none.
+func (c ContainersConfig) Names() []string {
+	names := make([]string, 0, len(c))
+	for _, d := range c {
+		name, _ := d.Parameters()["name"].(string)
+		if name == "" {
+			name = d.Type()
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Types returns the driver types in order.
+func (c ContainersConfig) Types() []string {
+	types := make([]string, 0, len(c))
+	for _, d := range c {
+		types = append(types, d.Type())
+	}
+
+	return types
+}
+
+type InventoryConfig struct {
+	EmitExisted bool   `yaml:"emit_existed"`
+	Snapshot    string `yaml:"snapshot"`
+}
+
 type Config struct {
-	Log        LogConfig  `yaml:"logging"`
-	Containers cfg.Driver `yaml:"containers"`
-	HTTP       HTTPConfig `yaml:"http"`
-	Storage    cfg.Driver `yaml:"storage"`
+	Log        LogConfig        `yaml:"logging"`
+	Containers ContainersConfig `yaml:"containers"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	Storage    cfg.Driver       `yaml:"storage"`
+	Hooks      HooksConfig      `yaml:"hooks"`
+	Inventory  InventoryConfig  `yaml:"inventory"`
 }
 
 type v1_0Config Config
@@ -46,13 +191,33 @@ func newConfig() *Config {
 			Fields:    make(map[string]interface{}),
 		},
 
-		Containers: make(cfg.Driver),
+		Containers: make(ContainersConfig, 0),
 
 		HTTP: HTTPConfig{
 			Enabled: true,
 			Addr:    ":9181",
 			Host:    "localhost",
 		},
+
+		Hooks: HooksConfig{
+			Exec: ExecConfig{
+				Allowed: make([]string, 0),
+				Timeout: 30 * time.Second,
+			},
+
+			File: FileConfig{
+				Allowed: make([]string, 0),
+			},
+
+			Transport: TransportConfig{
+				AllowedDirs: make([]string, 0),
+				Timeout:     30 * time.Second,
+			},
+
+			SMTP: SMTPConfig{
+				From: "csense@localhost",
+			},
+		},
 	}
 
 	return config
@@ -70,10 +235,25 @@ func Parse(rd io.Reader) (*Config, error) {
 			ParseAs: reflect.TypeOf(v1_0Config{}),
 			ConversionFunc: func(c interface{}) (interface{}, error) {
 				if v1_0, ok := c.(*v1_0Config); ok {
-					if v1_0.Containers.Type() == "" {
+					if len(v1_0.Containers) == 0 {
 						return nil, fmt.Errorf("no containers configuration provided")
 					}
 
+					for _, t := range v1_0.Containers.Types() {
+						if t == "" {
+							return nil, fmt.Errorf("containers driver type missing")
+						}
+					}
+
+					seen := make(map[string]bool)
+					for _, name := range v1_0.Containers.Names() {
+						if seen[name] {
+							return nil, fmt.Errorf("containers runtime %q configured more than once, give each a unique `name`", name)
+						}
+
+						seen[name] = true
+					}
+
 					return (*Config)(v1_0), nil
 				}
 
@@ -88,5 +268,9 @@ func Parse(rd io.Reader) (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.Containers.override(os.Environ()); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
@@ -0,0 +1,56 @@
+package configuration
+
+import (
+	"strings"
+	"testing"
+
+	cfg "github.com/danielkrainas/gobag/configuration"
+)
+
+func TestContainersOverride(t *testing.T) {
+	c := ContainersConfig{
+		cfg.Driver{"docker": cfg.Parameters{"host": "unix:///var/run/docker.sock"}},
+		cfg.Driver{"cri": nil},
+	}
+
+	err := c.override([]string{
+		"CSENSE_CONTAINERS_DOCKER_HOST=tcp://10.0.0.1:2376",
+		"CSENSE_CONTAINERS_CRI_POLL_INTERVAL=5s",
+		"CSENSE_CONTAINERS_CONTAINERD_ADDRESS=unix:///run/containerd.sock",
+		"CSENSE_HTTP_ADDR=:9000",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host := c[0].Parameters()["host"]; host != "tcp://10.0.0.1:2376" {
+		t.Errorf("got docker host %v", host)
+	}
+
+	if interval := c[1].Parameters()["poll_interval"]; interval != "5s" {
+		t.Errorf("got cri poll_interval %v", interval)
+	}
+
+	if len(c) != 2 {
+		t.Errorf("got %d drivers, unconfigured drivers aren't added", len(c))
+	}
+}
+
+func TestContainersNames(t *testing.T) {
+	in := "version: 1.0\ncontainers:\n  - docker:\n      host: x\n  - docker:\n      name: rootless\n  - cri\n"
+	config, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := config.Containers.Names()
+	if len(names) != 3 || names[0] != "docker" || names[1] != "rootless" || names[2] != "cri" {
+		t.Errorf("got names %q", names)
+	}
+
+	in = "version: 1.0\ncontainers:\n  - docker: {}\n  - docker: {}\n"
+	if _, err := Parse(strings.NewReader(in)); err == nil {
+		t.Error("runtimes with the same name parsed")
+	}
+}
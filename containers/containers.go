@@ -3,7 +3,9 @@ package containers
 import (
 	"context"
 	"errors"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/danielkrainas/csense/api/v1"
 )
@@ -21,6 +23,16 @@ type Driver interface {
 	GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error)
 }
 
+// QuotaCPUs converts a CFS quota and period to a number of CPUs, zero when
+// there's no quota.
+func QuotaCPUs(quota int64, period int64) float64 {
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+
+	return float64(quota) / float64(period)
+}
+
 func IndexByName(conts []*v1.ContainerInfo) map[string]*v1.ContainerInfo {
 	m := make(map[string]*v1.ContainerInfo)
 	for _, c := range conts {
@@ -30,6 +42,91 @@ func IndexByName(conts []*v1.ContainerInfo) map[string]*v1.ContainerInfo {
 	return m
 }
 
+// Diff returns the events missed by an index of tracked containers, a
+// deletion for every tracked container not among the current ones and a
+// creation for every current container not tracked. A container with the
+// same name but another ID was replaced and gets both.
+func Diff(index map[string]*v1.ContainerInfo, current []*v1.ContainerInfo, timestamp int64) []*v1.ContainerEvent {
+	events := make([]*v1.ContainerEvent, 0)
+	byName := IndexByName(current)
+	for name, tracked := range index {
+		if c, ok := byName[name]; !ok || replaced(tracked, c) {
+			events = append(events, &v1.ContainerEvent{
+				Type:      v1.EventContainerDeletion,
+				Container: tracked,
+				Timestamp: timestamp,
+			})
+		}
+	}
+
+	for _, c := range current {
+		if tracked, ok := index[c.Name]; !ok || replaced(tracked, c) {
+			events = append(events, &v1.ContainerEvent{
+				Type:      v1.EventContainerCreation,
+				Container: c,
+				Timestamp: timestamp,
+			})
+		}
+	}
+
+	return events
+}
+
+// Existed returns a containerExisted event for every current container
+// without a creation among the events.
+func Existed(current []*v1.ContainerInfo, events []*v1.ContainerEvent, timestamp int64) []*v1.ContainerEvent {
+	created := make(map[string]bool)
+	for _, e := range events {
+		if e.Type == v1.EventContainerCreation {
+			created[e.Container.Name] = true
+		}
+	}
+
+	existed := make([]*v1.ContainerEvent, 0)
+	for _, c := range current {
+		if !created[c.Name] {
+			existed = append(existed, &v1.ContainerEvent{
+				Type:      v1.EventContainerExisted,
+				Container: c,
+				Timestamp: timestamp,
+			})
+		}
+	}
+
+	return existed
+}
+
+func replaced(tracked *v1.ContainerInfo, c *v1.ContainerInfo) bool {
+	return tracked.ID != "" && c.ID != "" && tracked.ID != c.ID
+}
+
+// EventsChannelPrefix sends Events before the events of the channel.
+type EventsChannelPrefix struct {
+	EventsChannel
+	Events []*v1.ContainerEvent
+	setup  sync.Once
+	ch     chan *v1.ContainerEvent
+}
+
+func (prefix *EventsChannelPrefix) GetChannel() <-chan *v1.ContainerEvent {
+	prefix.setup.Do(func() {
+		prefix.ch = make(chan *v1.ContainerEvent)
+		go func() {
+			for _, event := range prefix.Events {
+				prefix.ch <- event
+			}
+
+			for event := range prefix.EventsChannel.GetChannel() {
+				prefix.ch <- event
+			}
+
+			close(prefix.ch)
+		}()
+	})
+
+	return prefix.ch
+}
+
 type EventsChannelFilter struct {
 	EventsChannel
 	Filter func(*v1.ContainerEvent) *v1.ContainerEvent
@@ -52,11 +149,16 @@ func (filter *EventsChannelFilter) GetChannel() <-chan *v1.ContainerEvent {
 	return filter.ch
 }
 
+// resolveTimeout bounds the driver's lookup of an event's container.
+const resolveTimeout = 10 * time.Second
+
 type EventsContainerResolver struct {
 	EventsChannel
 	Driver Driver
-	filter *EventsChannelFilter
-	setup  sync.Once
+	// Context is the lookups' context, the background context when nil.
+	Context context.Context
+	filter  *EventsChannelFilter
+	setup   sync.Once
 }
 
 func (resolver *EventsContainerResolver) GetChannel() <-chan *v1.ContainerEvent {
@@ -64,11 +166,28 @@ func (resolver *EventsContainerResolver) GetChannel() <-chan *v1.ContainerEvent
 		resolver.filter = &EventsChannelFilter{
 			EventsChannel: resolver.EventsChannel,
 			Filter: func(event *v1.ContainerEvent) *v1.ContainerEvent {
-				c, err := resolver.Driver.GetContainer(context.Background(), event.Container.Name)
-				if err == nil {
-					event.Container = c
+				ctx := resolver.Context
+				if ctx == nil {
+					ctx = context.Background()
+				}
+
+				ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+				c, err := resolver.Driver.GetContainer(ctx, event.Container.Name)
+				cancel()
+				if err != nil {
+					return event
 				}
 
+				// the driver may not know a deleted container anymore or
+				// report it from before it was deleted, what the deletion
+				// says about it wins
+				if event.Type == v1.EventContainerDeletion {
+					dupe := *event.Container
+					fillMissing(&dupe, c)
+					c = &dupe
+				}
+
+				event.Container = c
 				return event
 			},
 		}
@@ -77,6 +196,22 @@ func (resolver *EventsContainerResolver) GetChannel() <-chan *v1.ContainerEvent
 	return resolver.filter.GetChannel()
 }
 
+// fillMissing sets the empty fields of dst to the ones of src.
+func fillMissing(dst *v1.ContainerInfo, src *v1.ContainerInfo) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		f := d.Field(i)
+		empty := f.IsZero()
+		if k := f.Kind(); k == reflect.Slice || k == reflect.Map {
+			empty = f.Len() == 0
+		}
+
+		if empty {
+			f.Set(s.Field(i))
+		}
+	}
+}
+
 type EventsContainerTracker struct {
 	EventsChannel
 	Index  map[string]*v1.ContainerInfo
@@ -86,22 +221,38 @@ type EventsContainerTracker struct {
 
 func (tracker *EventsContainerTracker) GetChannel() <-chan *v1.ContainerEvent {
 	tracker.setup.Do(func() {
-		tracker.Index = make(map[string]*v1.ContainerInfo)
+		if tracker.Index == nil {
+			tracker.Index = make(map[string]*v1.ContainerInfo)
+		}
 
 		tracker.filter = &EventsChannelFilter{
 			EventsChannel: tracker.EventsChannel,
 			Filter: func(event *v1.ContainerEvent) *v1.ContainerEvent {
 				c := event.Container
 				name := c.Name
-				if event.Type == v1.EventContainerCreation {
-					tracker.Index[name] = c
-				} else {
-					if tracked, ok := tracker.Index[name]; ok {
-						c = tracked
-						delete(tracker.Index, name)
+				tracked, ok := tracker.Index[name]
+				event.PreviousState = v1.StateUnknown
+				if ok {
+					// anything still in the index was seen alive
+					event.PreviousState = tracked.State
+					if event.PreviousState == "" || event.PreviousState == v1.StateUnknown {
+						event.PreviousState = v1.StateRunning
 					}
 				}
 
+				if event.Type == v1.EventContainerCreation || event.Type == v1.EventContainerExisted {
+					tracker.Index[name] = c
+				} else if ok {
+					// the deletion's details such as the exit code win, the
+					// tracked container fills in the rest. It's copied so
+					// reactions still holding it don't see its state change.
+					dupe := *c
+					fillMissing(&dupe, tracked)
+					c = &dupe
+					delete(tracker.Index, name)
+				}
+
+				c.State = v1.StateFromEvent(event.Type)
 				event.Container = c
 				return event
 			},
@@ -0,0 +1,50 @@
+package containers
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name    string
+		index   []*v1.ContainerInfo
+		current []*v1.ContainerInfo
+		want    []string
+	}{
+		{"unchanged", []*v1.ContainerInfo{{Name: "web", ID: "1"}}, []*v1.ContainerInfo{{Name: "web", ID: "1"}}, nil},
+		{"created", nil, []*v1.ContainerInfo{{Name: "web"}}, []string{"containerCreation web"}},
+		{"deleted", []*v1.ContainerInfo{{Name: "web"}}, nil, []string{"containerDeletion web"}},
+		{"replaced", []*v1.ContainerInfo{{Name: "web", ID: "1"}}, []*v1.ContainerInfo{{Name: "web", ID: "2"}}, []string{"containerCreation web", "containerDeletion web"}},
+		{"id unknown", []*v1.ContainerInfo{{Name: "web"}}, []*v1.ContainerInfo{{Name: "web", ID: "2"}}, nil},
+		{"mixed", []*v1.ContainerInfo{{Name: "web"}, {Name: "db"}}, []*v1.ContainerInfo{{Name: "web"}, {Name: "cache"}}, []string{"containerCreation cache", "containerDeletion db"}},
+	}
+
+	for _, c := range cases {
+		events := Diff(IndexByName(c.index), c.current, 1700000000)
+		got := make([]string, 0, len(events))
+		for _, e := range events {
+			if e.Timestamp != 1700000000 {
+				t.Errorf("%s: got timestamp %d", c.name, e.Timestamp)
+			}
+
+			got = append(got, string(e.Type)+" "+e.Container.Name)
+		}
+
+		sort.Strings(got)
+		if strings.Join(got, ", ") != strings.Join(c.want, ", ") {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDiffKeepsTrackedDetails(t *testing.T) {
+	tracked := &v1.ContainerInfo{Name: "web", ID: "1", ImageName: "nginx"}
+	events := Diff(IndexByName([]*v1.ContainerInfo{tracked}), nil, 0)
+	if len(events) != 1 || events[0].Container != tracked {
+		t.Errorf("deletion doesn't report the tracked container: %+v", events)
+	}
+}
@@ -0,0 +1,237 @@
+package containerd
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// Messages of the containerd services used by the driver. Only the fields
+// the driver reads are declared, the rest are skipped when decoding.
+
+const (
+	methodSubscribe      = "/containerd.services.events.v1.Events/Subscribe"
+	methodGetContainer   = "/containerd.services.containers.v1.Containers/Get"
+	methodListTasks      = "/containerd.services.tasks.v1.Tasks/List"
+	methodGetTask        = "/containerd.services.tasks.v1.Tasks/Get"
+	methodListNamespaces = "/containerd.services.namespaces.v1.Namespaces/List"
+	methodGetImage       = "/containerd.services.images.v1.Images/Get"
+)
+
+const (
+	topicTaskStart = "/tasks/start"
+	topicTaskExit  = "/tasks/exit"
+	topicTaskOOM   = "/tasks/oom"
+)
+
+// task process states
+const (
+	processRunning int32 = 2
+	processStopped int32 = 3
+	processPaused  int32 = 4
+	processPausing int32 = 5
+)
+
+type timestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3"`
+}
+
+func (m *timestamp) Reset()         { *m = timestamp{} }
+func (m *timestamp) String() string { return proto.CompactTextString(m) }
+func (*timestamp) ProtoMessage()    {}
+
+type subscribeRequest struct {
+	Filters []string `protobuf:"bytes,1,rep,name=filters"`
+}
+
+func (m *subscribeRequest) Reset()         { *m = subscribeRequest{} }
+func (m *subscribeRequest) String() string { return proto.CompactTextString(m) }
+func (*subscribeRequest) ProtoMessage()    {}
+
+type envelope struct {
+	Timestamp *timestamp `protobuf:"bytes,1,opt,name=timestamp"`
+	Namespace string     `protobuf:"bytes,2,opt,name=namespace,proto3"`
+	Topic     string     `protobuf:"bytes,3,opt,name=topic,proto3"`
+	Event     *any.Any   `protobuf:"bytes,4,opt,name=event"`
+}
+
+func (m *envelope) Reset()         { *m = envelope{} }
+func (m *envelope) String() string { return proto.CompactTextString(m) }
+func (*envelope) ProtoMessage()    {}
+
+type taskStart struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,proto3"`
+	Pid         uint32 `protobuf:"varint,2,opt,name=pid,proto3"`
+}
+
+func (m *taskStart) Reset()         { *m = taskStart{} }
+func (m *taskStart) String() string { return proto.CompactTextString(m) }
+func (*taskStart) ProtoMessage()    {}
+
+type taskExit struct {
+	ContainerID string     `protobuf:"bytes,1,opt,name=container_id,proto3"`
+	ID          string     `protobuf:"bytes,2,opt,name=id,proto3"`
+	Pid         uint32     `protobuf:"varint,3,opt,name=pid,proto3"`
+	ExitStatus  uint32     `protobuf:"varint,4,opt,name=exit_status,proto3"`
+	ExitedAt    *timestamp `protobuf:"bytes,5,opt,name=exited_at"`
+}
+
+func (m *taskExit) Reset()         { *m = taskExit{} }
+func (m *taskExit) String() string { return proto.CompactTextString(m) }
+func (*taskExit) ProtoMessage()    {}
+
+type taskOOM struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,proto3"`
+}
+
+func (m *taskOOM) Reset()         { *m = taskOOM{} }
+func (m *taskOOM) String() string { return proto.CompactTextString(m) }
+func (*taskOOM) ProtoMessage()    {}
+
+type container struct {
+	ID        string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	Labels    map[string]string `protobuf:"bytes,2,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Image     string            `protobuf:"bytes,3,opt,name=image,proto3"`
+	Spec      *any.Any          `protobuf:"bytes,5,opt,name=spec"`
+	CreatedAt *timestamp        `protobuf:"bytes,8,opt,name=created_at"`
+}
+
+func (m *container) Reset()         { *m = container{} }
+func (m *container) String() string { return proto.CompactTextString(m) }
+func (*container) ProtoMessage()    {}
+
+type getContainerRequest struct {
+	ID string `protobuf:"bytes,1,opt,name=id,proto3"`
+}
+
+func (m *getContainerRequest) Reset()         { *m = getContainerRequest{} }
+func (m *getContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*getContainerRequest) ProtoMessage()    {}
+
+type getContainerResponse struct {
+	Container *container `protobuf:"bytes,1,opt,name=container"`
+}
+
+func (m *getContainerResponse) Reset()         { *m = getContainerResponse{} }
+func (m *getContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*getContainerResponse) ProtoMessage()    {}
+
+type process struct {
+	ContainerID string     `protobuf:"bytes,1,opt,name=container_id,proto3"`
+	ID          string     `protobuf:"bytes,2,opt,name=id,proto3"`
+	Status      int32      `protobuf:"varint,4,opt,name=status,proto3"`
+	ExitStatus  uint32     `protobuf:"varint,9,opt,name=exit_status,proto3"`
+	ExitedAt    *timestamp `protobuf:"bytes,10,opt,name=exited_at"`
+}
+
+func (m *process) Reset()         { *m = process{} }
+func (m *process) String() string { return proto.CompactTextString(m) }
+func (*process) ProtoMessage()    {}
+
+type listTasksRequest struct {
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3"`
+}
+
+func (m *listTasksRequest) Reset()         { *m = listTasksRequest{} }
+func (m *listTasksRequest) String() string { return proto.CompactTextString(m) }
+func (*listTasksRequest) ProtoMessage()    {}
+
+type listTasksResponse struct {
+	Tasks []*process `protobuf:"bytes,1,rep,name=tasks"`
+}
+
+func (m *listTasksResponse) Reset()         { *m = listTasksResponse{} }
+func (m *listTasksResponse) String() string { return proto.CompactTextString(m) }
+func (*listTasksResponse) ProtoMessage()    {}
+
+type getTaskRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,proto3"`
+}
+
+func (m *getTaskRequest) Reset()         { *m = getTaskRequest{} }
+func (m *getTaskRequest) String() string { return proto.CompactTextString(m) }
+func (*getTaskRequest) ProtoMessage()    {}
+
+type getTaskResponse struct {
+	Process *process `protobuf:"bytes,1,opt,name=process"`
+}
+
+func (m *getTaskResponse) Reset()         { *m = getTaskResponse{} }
+func (m *getTaskResponse) String() string { return proto.CompactTextString(m) }
+func (*getTaskResponse) ProtoMessage()    {}
+
+type namespace struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+}
+
+func (m *namespace) Reset()         { *m = namespace{} }
+func (m *namespace) String() string { return proto.CompactTextString(m) }
+func (*namespace) ProtoMessage()    {}
+
+type listNamespacesRequest struct{}
+
+func (m *listNamespacesRequest) Reset()         { *m = listNamespacesRequest{} }
+func (m *listNamespacesRequest) String() string { return proto.CompactTextString(m) }
+func (*listNamespacesRequest) ProtoMessage()    {}
+
+type listNamespacesResponse struct {
+	Namespaces []*namespace `protobuf:"bytes,1,rep,name=namespaces"`
+}
+
+func (m *listNamespacesResponse) Reset()         { *m = listNamespacesResponse{} }
+func (m *listNamespacesResponse) String() string { return proto.CompactTextString(m) }
+func (*listNamespacesResponse) ProtoMessage()    {}
+
+type descriptor struct {
+	MediaType string `protobuf:"bytes,1,opt,name=media_type,proto3"`
+	Digest    string `protobuf:"bytes,2,opt,name=digest,proto3"`
+}
+
+func (m *descriptor) Reset()         { *m = descriptor{} }
+func (m *descriptor) String() string { return proto.CompactTextString(m) }
+func (*descriptor) ProtoMessage()    {}
+
+type image struct {
+	Name   string      `protobuf:"bytes,1,opt,name=name,proto3"`
+	Target *descriptor `protobuf:"bytes,3,opt,name=target"`
+}
+
+func (m *image) Reset()         { *m = image{} }
+func (m *image) String() string { return proto.CompactTextString(m) }
+func (*image) ProtoMessage()    {}
+
+type getImageRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+}
+
+func (m *getImageRequest) Reset()         { *m = getImageRequest{} }
+func (m *getImageRequest) String() string { return proto.CompactTextString(m) }
+func (*getImageRequest) ProtoMessage()    {}
+
+type getImageResponse struct {
+	Image *image `protobuf:"bytes,1,opt,name=image"`
+}
+
+func (m *getImageResponse) Reset()         { *m = getImageResponse{} }
+func (m *getImageResponse) String() string { return proto.CompactTextString(m) }
+func (*getImageResponse) ProtoMessage()    {}
+
+// ociSpec holds the parts of a container's OCI runtime spec the driver
+// reads, containerd stores it as JSON.
+type ociSpec struct {
+	Process *struct {
+		Args []string `json:"args"`
+	} `json:"process"`
+	Linux *struct {
+		Resources *struct {
+			Memory *struct {
+				Limit *int64 `json:"limit"`
+			} `json:"memory"`
+			CPU *struct {
+				Shares *uint64 `json:"shares"`
+				Quota  *int64  `json:"quota"`
+				Period *uint64 `json:"period"`
+			} `json:"cpu"`
+		} `json:"resources"`
+	} `json:"linux"`
+}
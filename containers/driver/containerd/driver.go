@@ -0,0 +1,287 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/factory"
+	"github.com/danielkrainas/csense/containers/driver/grpcconn"
+)
+
+const (
+	defaultAddress  = "unix:///run/containerd/containerd.sock"
+	namespaceHeader = "containerd-namespace"
+)
+
+// LabelNamespace is added to container labels with the containerd namespace
+// the container belongs to.
+const LabelNamespace = "io.containerd.namespace"
+
+func init() {
+	factory.Register("containerd", &driverFactory{})
+}
+
+type driverFactory struct{}
+
+func (factory *driverFactory) Create(parameters map[string]interface{}) (containers.Driver, error) {
+	address, ok := parameters["address"].(string)
+	if !ok || address == "" {
+		address = defaultAddress
+	}
+
+	conn, err := grpcconn.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &driver{
+		conn:       conn,
+		namespaces: namespacesParam(parameters["namespaces"]),
+	}, nil
+}
+
+// namespacesParam accepts a list or a comma separated string.
+func namespacesParam(raw interface{}) []string {
+	var names []string
+	switch v := raw.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []interface{}:
+		for _, n := range v {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			result = append(result, n)
+		}
+	}
+
+	return result
+}
+
+type driver struct {
+	conn *grpcconn.Conn
+	// namespaces to watch, all of them when empty
+	namespaces []string
+}
+
+func withNamespace(ctx context.Context, ns string) context.Context {
+	return grpcconn.WithMetadata(ctx, namespaceHeader, ns)
+}
+
+func (d *driver) watching(ns string) bool {
+	if len(d.namespaces) == 0 {
+		return true
+	}
+
+	for _, n := range d.namespaces {
+		if n == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *driver) listNamespaces(ctx context.Context) ([]string, error) {
+	if len(d.namespaces) > 0 {
+		return d.namespaces, nil
+	}
+
+	resp := &listNamespacesResponse{}
+	if err := d.conn.Invoke(ctx, methodListNamespaces, &listNamespacesRequest{}, resp); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(resp.Namespaces))
+	for _, ns := range resp.Namespaces {
+		names = append(names, ns.Name)
+	}
+
+	return names, nil
+}
+
+func (d *driver) getContainer(ctx context.Context, ns string, id string) (*v1.ContainerInfo, error) {
+	ctx = withNamespace(ctx, ns)
+	resp := &getContainerResponse{}
+	if err := d.conn.Invoke(ctx, methodGetContainer, &getContainerRequest{ID: id}, resp); err != nil {
+		if grpcconn.IsNotFound(err) {
+			return nil, containers.ErrContainerNotFound
+		}
+
+		return nil, err
+	} else if resp.Container == nil {
+		return nil, containers.ErrContainerNotFound
+	}
+
+	c := convertContainer(ns, resp.Container)
+	if resp.Container.Image != "" {
+		img := &getImageResponse{}
+		if err := d.conn.Invoke(ctx, methodGetImage, &getImageRequest{Name: resp.Container.Image}, img); err == nil && img.Image != nil && img.Image.Target != nil {
+			c.ImageDigest = img.Image.Target.Digest
+		}
+	}
+
+	return c, nil
+}
+
+func convertContainer(ns string, c *container) *v1.ContainerInfo {
+	labels := make(map[string]string)
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+
+	labels[LabelNamespace] = ns
+	info := &v1.ContainerInfo{
+		ID:     c.ID,
+		Name:   c.ID,
+		Labels: labels,
+		State:  v1.StateUnknown,
+	}
+
+	containers.ParseImageReference(c.Image).Apply(info)
+
+	if c.CreatedAt != nil {
+		info.Created = c.CreatedAt.Seconds
+	}
+
+	spec := &ociSpec{}
+	if c.Spec == nil || json.Unmarshal(c.Spec.Value, spec) != nil {
+		return info
+	}
+
+	if spec.Process != nil {
+		info.Command = spec.Process.Args
+	}
+
+	if spec.Linux != nil && spec.Linux.Resources != nil {
+		r := spec.Linux.Resources
+		info.Resources = &v1.ResourceLimits{}
+		if r.Memory != nil && r.Memory.Limit != nil && *r.Memory.Limit > 0 {
+			info.Resources.MemoryLimit = *r.Memory.Limit
+		}
+
+		if r.CPU != nil {
+			if r.CPU.Shares != nil {
+				info.Resources.CPUShares = int64(*r.CPU.Shares)
+			}
+
+			if r.CPU.Quota != nil && r.CPU.Period != nil {
+				info.Resources.CPUs = containers.QuotaCPUs(*r.CPU.Quota, int64(*r.CPU.Period))
+			}
+		}
+
+		if *info.Resources == (v1.ResourceLimits{}) {
+			info.Resources = nil
+		}
+	}
+
+	return info
+}
+
+func isRunning(p *process) bool {
+	switch p.Status {
+	case processRunning, processPaused, processPausing:
+		return true
+	}
+
+	return false
+}
+
+func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	wanted := make(map[string]v1.ContainerEventType)
+	filters := []string{}
+	for _, t := range types {
+		if topic, ok := eventTopics[t]; ok {
+			wanted[topic] = t
+			filters = append(filters, `topic=="`+topic+`"`)
+		}
+	}
+
+	s, err := d.conn.NewStream(ctx, methodSubscribe, &subscribeRequest{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventChannel(ctx, d, s, wanted), nil
+}
+
+func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	namespaces, err := d.listNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.ContainerInfo, 0)
+	for _, ns := range namespaces {
+		resp := &listTasksResponse{}
+		if err := d.conn.Invoke(withNamespace(ctx, ns), methodListTasks, &listTasksRequest{}, resp); err != nil {
+			return nil, err
+		}
+
+		for _, p := range resp.Tasks {
+			if !isRunning(p) {
+				continue
+			}
+
+			c, err := d.getContainer(ctx, ns, p.ContainerID)
+			if err == containers.ErrContainerNotFound {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			c.State = v1.StateRunning
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// GetContainer finds a container by ID in the watched namespaces.
+func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	namespaces, err := d.listNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ns := range namespaces {
+		c, err := d.getContainer(ctx, ns, name)
+		if err == containers.ErrContainerNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		// containers without a task aren't running
+		c.State = v1.StateStopped
+		resp := &getTaskResponse{}
+		err = d.conn.Invoke(withNamespace(ctx, ns), methodGetTask, &getTaskRequest{ContainerID: c.ID}, resp)
+		if err != nil && !grpcconn.IsNotFound(err) {
+			return nil, err
+		} else if err == nil && resp.Process != nil {
+			if isRunning(resp.Process) {
+				c.State = v1.StateRunning
+			} else if resp.Process.Status == processStopped {
+				exitCode := int(resp.Process.ExitStatus)
+				c.ExitCode = &exitCode
+				if resp.Process.ExitedAt != nil && resp.Process.ExitedAt.Seconds > 0 {
+					c.FinishedAt = resp.Process.ExitedAt.Seconds
+				}
+			}
+		}
+
+		return c, nil
+	}
+
+	return nil, containers.ErrContainerNotFound
+}
@@ -0,0 +1,259 @@
+package containerd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/grpcconn/grpctest"
+)
+
+// wire encodes messages the way containerd does, independently of the
+// driver's message declarations.
+type wire struct {
+	buf *proto.Buffer
+}
+
+func newWire() *wire {
+	return &wire{buf: proto.NewBuffer(nil)}
+}
+
+func (w *wire) str(field uint64, s string) *wire {
+	w.buf.EncodeVarint(field<<3 | 2)
+	w.buf.EncodeStringBytes(s)
+	return w
+}
+
+func (w *wire) varint(field uint64, v uint64) *wire {
+	w.buf.EncodeVarint(field << 3)
+	w.buf.EncodeVarint(v)
+	return w
+}
+
+func (w *wire) msg(field uint64, m *wire) *wire {
+	w.buf.EncodeVarint(field<<3 | 2)
+	w.buf.EncodeRawBytes(m.bytes())
+	return w
+}
+
+func (w *wire) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func taskStartEvent(id string, pid uint64) []byte {
+	return newWire().str(1, id).varint(2, pid).bytes()
+}
+
+func taskExitEvent(containerID string, id string, pid uint64, status uint64, exitedAt int64) []byte {
+	return newWire().
+		str(1, containerID).
+		str(2, id).
+		varint(3, pid).
+		varint(4, status).
+		msg(5, newWire().varint(1, uint64(exitedAt))).
+		bytes()
+}
+
+func taskOOMEvent(id string) []byte {
+	return newWire().str(1, id).bytes()
+}
+
+type fakeContainerd struct {
+	*grpctest.Server
+	events chan *envelope
+}
+
+func newFakeContainerd(t *testing.T) *fakeContainerd {
+	srv, err := grpctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeContainerd{
+		Server: srv,
+		events: make(chan *envelope, 10),
+	}
+
+	conts := map[string]*container{
+		"default/web": {
+			ID:     "web",
+			Image:  "docker.io/library/nginx:1.2",
+			Labels: map[string]string{"app": "web"},
+		},
+	}
+
+	f.Handle(methodListNamespaces, func(c *grpctest.Call) error {
+		return c.Send(&listNamespacesResponse{Namespaces: []*namespace{{Name: "default"}, {Name: "k8s.io"}}})
+	})
+
+	f.Handle(methodGetContainer, func(c *grpctest.Call) error {
+		req := &getContainerRequest{}
+		if err := c.Decode(req); err != nil {
+			return err
+		}
+
+		cont, ok := conts[c.Header.Get(namespaceHeader)+"/"+req.ID]
+		if !ok {
+			return grpctest.NotFound("container %q not found", req.ID)
+		}
+
+		return c.Send(&getContainerResponse{Container: cont})
+	})
+
+	f.Handle(methodGetImage, func(c *grpctest.Call) error {
+		return c.Send(&getImageResponse{Image: &image{Target: &descriptor{Digest: "sha256:abc"}}})
+	})
+
+	f.Handle(methodGetTask, func(c *grpctest.Call) error {
+		return c.Send(&getTaskResponse{Process: &process{ContainerID: "web", Status: processRunning}})
+	})
+
+	f.Handle(methodListTasks, func(c *grpctest.Call) error {
+		if c.Header.Get(namespaceHeader) != "default" {
+			return c.Send(&listTasksResponse{})
+		}
+
+		return c.Send(&listTasksResponse{Tasks: []*process{
+			{ContainerID: "web", Status: processRunning},
+			{ContainerID: "old", Status: processStopped},
+		}})
+	})
+
+	f.Handle(methodSubscribe, func(c *grpctest.Call) error {
+		for {
+			select {
+			case env := <-f.events:
+				if err := c.Send(env); err != nil {
+					return err
+				}
+			case <-c.Context().Done():
+				return nil
+			}
+		}
+	})
+
+	return f
+}
+
+func (f *fakeContainerd) publish(ns string, topic string, event []byte) {
+	f.events <- &envelope{
+		Timestamp: &timestamp{Seconds: 1700000000},
+		Namespace: ns,
+		Topic:     topic,
+		Event:     &any.Any{TypeUrl: "containerd.events" + topic, Value: event},
+	}
+}
+
+func newTestDriver(t *testing.T, f *fakeContainerd, parameters map[string]interface{}) containers.Driver {
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+
+	parameters["address"] = f.Addr
+	d, err := (&driverFactory{}).Create(parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func nextEvent(t *testing.T, ch containers.EventsChannel) *v1.ContainerEvent {
+	select {
+	case e, ok := <-ch.GetChannel():
+		if !ok {
+			t.Fatal("events channel closed")
+		}
+
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	return nil
+}
+
+func TestWatchEventsDecodesTaskTopics(t *testing.T) {
+	f := newFakeContainerd(t)
+	defer f.Close()
+
+	d := newTestDriver(t, f, map[string]interface{}{"namespaces": "default"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := d.WatchEvents(ctx, v1.EventContainerCreation, v1.EventContainerDeletion, v1.EventContainerOomKill)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+
+	// the start carries the pid as a varint in field 2
+	f.publish("default", topicTaskStart, taskStartEvent("web", 4242))
+	e := nextEvent(t, ch)
+	if e.Type != v1.EventContainerCreation || e.Container.ID != "web" {
+		t.Fatalf("start: got %s for %q", e.Type, e.Container.ID)
+	} else if e.Container.ImageName != "nginx" || e.Container.ImageDigest != "sha256:abc" {
+		t.Errorf("start: got image %q digest %q", e.Container.ImageName, e.Container.ImageDigest)
+	} else if e.Container.Labels[LabelNamespace] != "default" || e.Container.Labels["app"] != "web" {
+		t.Errorf("start: got labels %v", e.Container.Labels)
+	} else if e.Timestamp != 1700000000 {
+		t.Errorf("start: got timestamp %d", e.Timestamp)
+	}
+
+	// other namespaces and exec'd processes are skipped
+	f.publish("k8s.io", topicTaskStart, taskStartEvent("web", 1))
+	f.publish("default", topicTaskExit, taskExitEvent("web", "exec-1", 99, 1, 1700000001))
+	f.publish("default", topicTaskExit, taskExitEvent("web", "web", 4242, 137, 1700000002))
+	e = nextEvent(t, ch)
+	if e.Type != v1.EventContainerDeletion {
+		t.Fatalf("exit: got %s", e.Type)
+	} else if e.Container.ExitCode == nil || *e.Container.ExitCode != 137 {
+		t.Errorf("exit: got exit code %v", e.Container.ExitCode)
+	} else if e.Container.FinishedAt != 1700000002 {
+		t.Errorf("exit: got finished at %d", e.Container.FinishedAt)
+	}
+
+	f.publish("default", topicTaskOOM, taskOOMEvent("gone"))
+	e = nextEvent(t, ch)
+	if e.Type != v1.EventContainerOomKill || e.Container.Name != "gone" {
+		t.Errorf("oom: got %s for %q", e.Type, e.Container.Name)
+	}
+}
+
+func TestGetContainers(t *testing.T) {
+	f := newFakeContainerd(t)
+	defer f.Close()
+
+	d := newTestDriver(t, f, nil)
+	conts, err := d.GetContainers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conts) != 1 || conts[0].ID != "web" || conts[0].State != v1.StateRunning {
+		t.Fatalf("got %+v", conts)
+	}
+}
+
+func TestGetContainer(t *testing.T) {
+	f := newFakeContainerd(t)
+	defer f.Close()
+
+	d := newTestDriver(t, f, nil)
+	c, err := d.GetContainer(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	} else if c.State != v1.StateRunning || c.ImageTag != "1.2" {
+		t.Errorf("got state %s tag %q", c.State, c.ImageTag)
+	}
+
+	if _, err := d.GetContainer(context.Background(), "nope"); err != containers.ErrContainerNotFound {
+		t.Errorf("got %v for a missing container", err)
+	}
+}
@@ -0,0 +1,124 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers/driver/grpcconn"
+)
+
+// eventTopics maps event types to the containerd task topics that produce
+// them.
+var eventTopics = map[v1.ContainerEventType]string{
+	v1.EventContainerCreation: topicTaskStart,
+	v1.EventContainerDeletion: topicTaskExit,
+	v1.EventContainerOomKill:  topicTaskOOM,
+}
+
+type eventChannel struct {
+	stream  *grpcconn.Stream
+	channel chan *v1.ContainerEvent
+}
+
+func newEventChannel(ctx context.Context, d *driver, s *grpcconn.Stream, wanted map[string]v1.ContainerEventType) *eventChannel {
+	ec := &eventChannel{
+		stream:  s,
+		channel: make(chan *v1.ContainerEvent),
+	}
+
+	go func() {
+		defer close(ec.channel)
+		for {
+			env := &envelope{}
+			if err := s.Recv(env); err != nil {
+				return
+			}
+
+			t, ok := wanted[env.Topic]
+			if !ok || env.Event == nil || !d.watching(env.Namespace) {
+				continue
+			}
+
+			containerID, exit, err := decodeTask(env)
+			if err != nil {
+				continue
+			}
+
+			// exits of exec'd processes carry their own ID
+			if exit != nil && exit.ID != "" && exit.ID != exit.ContainerID {
+				continue
+			}
+
+			select {
+			case ec.channel <- convertEvent(ctx, d, env, containerID, exit, t):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ec
+}
+
+// decodeTask decodes the envelope's event with the message of its topic,
+// the exit is only set for exits.
+func decodeTask(env *envelope) (string, *taskExit, error) {
+	switch env.Topic {
+	case topicTaskStart:
+		m := &taskStart{}
+		err := proto.Unmarshal(env.Event.Value, m)
+		return m.ContainerID, nil, err
+	case topicTaskExit:
+		m := &taskExit{}
+		err := proto.Unmarshal(env.Event.Value, m)
+		return m.ContainerID, m, err
+	case topicTaskOOM:
+		m := &taskOOM{}
+		err := proto.Unmarshal(env.Event.Value, m)
+		return m.ContainerID, nil, err
+	}
+
+	return "", nil, fmt.Errorf("unsupported topic %q", env.Topic)
+}
+
+func convertEvent(ctx context.Context, d *driver, env *envelope, containerID string, exit *taskExit, t v1.ContainerEventType) *v1.ContainerEvent {
+	c, err := d.getContainer(ctx, env.Namespace, containerID)
+	if err != nil {
+		c = &v1.ContainerInfo{
+			ID:     containerID,
+			Name:   containerID,
+			Labels: map[string]string{LabelNamespace: env.Namespace},
+		}
+	}
+
+	if exit != nil {
+		exitCode := int(exit.ExitStatus)
+		c.ExitCode = &exitCode
+		if exit.ExitedAt != nil && exit.ExitedAt.Seconds > 0 {
+			c.FinishedAt = exit.ExitedAt.Seconds
+		}
+	}
+
+	ts := time.Now().Unix()
+	if env.Timestamp != nil {
+		ts = env.Timestamp.Seconds
+	}
+
+	return &v1.ContainerEvent{
+		Type:      t,
+		Container: c,
+		Timestamp: ts,
+	}
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	return ec.stream.Close()
+}
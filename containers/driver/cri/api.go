@@ -0,0 +1,228 @@
+package cri
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// Messages of the CRI `runtime.v1.RuntimeService` used by the driver. Only
+// the fields the driver reads are declared, the rest are skipped when
+// decoding.
+
+const (
+	methodListContainers   = "/runtime.v1.RuntimeService/ListContainers"
+	methodContainerStatus  = "/runtime.v1.RuntimeService/ContainerStatus"
+	methodListPodSandbox   = "/runtime.v1.RuntimeService/ListPodSandbox"
+	methodPodSandboxStatus = "/runtime.v1.RuntimeService/PodSandboxStatus"
+)
+
+// container states
+const (
+	containerCreated int32 = 0
+	containerRunning int32 = 1
+	containerExited  int32 = 2
+	containerUnknown int32 = 3
+)
+
+type containerMetadata struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Attempt uint32 `protobuf:"varint,2,opt,name=attempt,proto3"`
+}
+
+func (m *containerMetadata) Reset()         { *m = containerMetadata{} }
+func (m *containerMetadata) String() string { return proto.CompactTextString(m) }
+func (*containerMetadata) ProtoMessage()    {}
+
+type imageSpec struct {
+	Image string `protobuf:"bytes,1,opt,name=image,proto3"`
+}
+
+func (m *imageSpec) Reset()         { *m = imageSpec{} }
+func (m *imageSpec) String() string { return proto.CompactTextString(m) }
+func (*imageSpec) ProtoMessage()    {}
+
+type container struct {
+	ID           string             `protobuf:"bytes,1,opt,name=id,proto3"`
+	PodSandboxID string             `protobuf:"bytes,2,opt,name=pod_sandbox_id,proto3"`
+	Metadata     *containerMetadata `protobuf:"bytes,3,opt,name=metadata"`
+	Image        *imageSpec         `protobuf:"bytes,4,opt,name=image"`
+	ImageRef     string             `protobuf:"bytes,5,opt,name=image_ref,proto3"`
+	State        int32              `protobuf:"varint,6,opt,name=state,proto3"`
+	CreatedAt    int64              `protobuf:"varint,7,opt,name=created_at,proto3"`
+	Labels       map[string]string  `protobuf:"bytes,8,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations  map[string]string  `protobuf:"bytes,9,rep,name=annotations" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *container) Reset()         { *m = container{} }
+func (m *container) String() string { return proto.CompactTextString(m) }
+func (*container) ProtoMessage()    {}
+
+type containerFilter struct {
+	ID           string `protobuf:"bytes,1,opt,name=id,proto3"`
+	PodSandboxID string `protobuf:"bytes,3,opt,name=pod_sandbox_id,proto3"`
+}
+
+func (m *containerFilter) Reset()         { *m = containerFilter{} }
+func (m *containerFilter) String() string { return proto.CompactTextString(m) }
+func (*containerFilter) ProtoMessage()    {}
+
+type listContainersRequest struct {
+	Filter *containerFilter `protobuf:"bytes,1,opt,name=filter"`
+}
+
+func (m *listContainersRequest) Reset()         { *m = listContainersRequest{} }
+func (m *listContainersRequest) String() string { return proto.CompactTextString(m) }
+func (*listContainersRequest) ProtoMessage()    {}
+
+type listContainersResponse struct {
+	Containers []*container `protobuf:"bytes,1,rep,name=containers"`
+}
+
+func (m *listContainersResponse) Reset()         { *m = listContainersResponse{} }
+func (m *listContainersResponse) String() string { return proto.CompactTextString(m) }
+func (*listContainersResponse) ProtoMessage()    {}
+
+type containerStatusRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,proto3"`
+}
+
+func (m *containerStatusRequest) Reset()         { *m = containerStatusRequest{} }
+func (m *containerStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*containerStatusRequest) ProtoMessage()    {}
+
+type linuxContainerResources struct {
+	CPUPeriod          int64 `protobuf:"varint,1,opt,name=cpu_period,proto3"`
+	CPUQuota           int64 `protobuf:"varint,2,opt,name=cpu_quota,proto3"`
+	CPUShares          int64 `protobuf:"varint,3,opt,name=cpu_shares,proto3"`
+	MemoryLimitInBytes int64 `protobuf:"varint,4,opt,name=memory_limit_in_bytes,proto3"`
+}
+
+func (m *linuxContainerResources) Reset()         { *m = linuxContainerResources{} }
+func (m *linuxContainerResources) String() string { return proto.CompactTextString(m) }
+func (*linuxContainerResources) ProtoMessage()    {}
+
+type containerResources struct {
+	Linux *linuxContainerResources `protobuf:"bytes,1,opt,name=linux"`
+}
+
+func (m *containerResources) Reset()         { *m = containerResources{} }
+func (m *containerResources) String() string { return proto.CompactTextString(m) }
+func (*containerResources) ProtoMessage()    {}
+
+type containerStatus struct {
+	ID          string              `protobuf:"bytes,1,opt,name=id,proto3"`
+	Metadata    *containerMetadata  `protobuf:"bytes,2,opt,name=metadata"`
+	State       int32               `protobuf:"varint,3,opt,name=state,proto3"`
+	CreatedAt   int64               `protobuf:"varint,4,opt,name=created_at,proto3"`
+	StartedAt   int64               `protobuf:"varint,5,opt,name=started_at,proto3"`
+	FinishedAt  int64               `protobuf:"varint,6,opt,name=finished_at,proto3"`
+	ExitCode    int32               `protobuf:"varint,7,opt,name=exit_code,proto3"`
+	Image       *imageSpec          `protobuf:"bytes,8,opt,name=image"`
+	ImageRef    string              `protobuf:"bytes,9,opt,name=image_ref,proto3"`
+	Reason      string              `protobuf:"bytes,10,opt,name=reason,proto3"`
+	Message     string              `protobuf:"bytes,11,opt,name=message,proto3"`
+	Labels      map[string]string   `protobuf:"bytes,12,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string   `protobuf:"bytes,13,rep,name=annotations" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Resources   *containerResources `protobuf:"bytes,16,opt,name=resources"`
+}
+
+func (m *containerStatus) Reset()         { *m = containerStatus{} }
+func (m *containerStatus) String() string { return proto.CompactTextString(m) }
+func (*containerStatus) ProtoMessage()    {}
+
+type containerStatusResponse struct {
+	Status *containerStatus `protobuf:"bytes,1,opt,name=status"`
+}
+
+func (m *containerStatusResponse) Reset()         { *m = containerStatusResponse{} }
+func (m *containerStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*containerStatusResponse) ProtoMessage()    {}
+
+type podSandboxMetadata struct {
+	Name      string `protobuf:"bytes,1,opt,name=name,proto3"`
+	UID       string `protobuf:"bytes,2,opt,name=uid,proto3"`
+	Namespace string `protobuf:"bytes,3,opt,name=namespace,proto3"`
+	Attempt   uint32 `protobuf:"varint,4,opt,name=attempt,proto3"`
+}
+
+func (m *podSandboxMetadata) Reset()         { *m = podSandboxMetadata{} }
+func (m *podSandboxMetadata) String() string { return proto.CompactTextString(m) }
+func (*podSandboxMetadata) ProtoMessage()    {}
+
+type podSandbox struct {
+	ID          string              `protobuf:"bytes,1,opt,name=id,proto3"`
+	Metadata    *podSandboxMetadata `protobuf:"bytes,2,opt,name=metadata"`
+	State       int32               `protobuf:"varint,3,opt,name=state,proto3"`
+	CreatedAt   int64               `protobuf:"varint,4,opt,name=created_at,proto3"`
+	Labels      map[string]string   `protobuf:"bytes,5,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string   `protobuf:"bytes,6,rep,name=annotations" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *podSandbox) Reset()         { *m = podSandbox{} }
+func (m *podSandbox) String() string { return proto.CompactTextString(m) }
+func (*podSandbox) ProtoMessage()    {}
+
+type podSandboxFilter struct {
+	ID string `protobuf:"bytes,1,opt,name=id,proto3"`
+}
+
+func (m *podSandboxFilter) Reset()         { *m = podSandboxFilter{} }
+func (m *podSandboxFilter) String() string { return proto.CompactTextString(m) }
+func (*podSandboxFilter) ProtoMessage()    {}
+
+type listPodSandboxRequest struct {
+	Filter *podSandboxFilter `protobuf:"bytes,1,opt,name=filter"`
+}
+
+func (m *listPodSandboxRequest) Reset()         { *m = listPodSandboxRequest{} }
+func (m *listPodSandboxRequest) String() string { return proto.CompactTextString(m) }
+func (*listPodSandboxRequest) ProtoMessage()    {}
+
+type listPodSandboxResponse struct {
+	Items []*podSandbox `protobuf:"bytes,1,rep,name=items"`
+}
+
+func (m *listPodSandboxResponse) Reset()         { *m = listPodSandboxResponse{} }
+func (m *listPodSandboxResponse) String() string { return proto.CompactTextString(m) }
+func (*listPodSandboxResponse) ProtoMessage()    {}
+
+type podIP struct {
+	IP string `protobuf:"bytes,1,opt,name=ip,proto3"`
+}
+
+func (m *podIP) Reset()         { *m = podIP{} }
+func (m *podIP) String() string { return proto.CompactTextString(m) }
+func (*podIP) ProtoMessage()    {}
+
+type podSandboxNetworkStatus struct {
+	IP            string   `protobuf:"bytes,1,opt,name=ip,proto3"`
+	AdditionalIPs []*podIP `protobuf:"bytes,2,rep,name=additional_ips"`
+}
+
+func (m *podSandboxNetworkStatus) Reset()         { *m = podSandboxNetworkStatus{} }
+func (m *podSandboxNetworkStatus) String() string { return proto.CompactTextString(m) }
+func (*podSandboxNetworkStatus) ProtoMessage()    {}
+
+type podSandboxStatus struct {
+	ID      string                   `protobuf:"bytes,1,opt,name=id,proto3"`
+	Network *podSandboxNetworkStatus `protobuf:"bytes,5,opt,name=network"`
+}
+
+func (m *podSandboxStatus) Reset()         { *m = podSandboxStatus{} }
+func (m *podSandboxStatus) String() string { return proto.CompactTextString(m) }
+func (*podSandboxStatus) ProtoMessage()    {}
+
+type podSandboxStatusRequest struct {
+	PodSandboxID string `protobuf:"bytes,1,opt,name=pod_sandbox_id,proto3"`
+}
+
+func (m *podSandboxStatusRequest) Reset()         { *m = podSandboxStatusRequest{} }
+func (m *podSandboxStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*podSandboxStatusRequest) ProtoMessage()    {}
+
+type podSandboxStatusResponse struct {
+	Status *podSandboxStatus `protobuf:"bytes,1,opt,name=status"`
+}
+
+func (m *podSandboxStatusResponse) Reset()         { *m = podSandboxStatusResponse{} }
+func (m *podSandboxStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*podSandboxStatusResponse) ProtoMessage()    {}
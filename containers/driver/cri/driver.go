@@ -0,0 +1,352 @@
+package cri
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/factory"
+	"github.com/danielkrainas/csense/containers/driver/grpcconn"
+)
+
+const (
+	defaultEndpoint     = "unix:///run/containerd/containerd.sock"
+	defaultPollInterval = time.Second
+)
+
+// Labels the driver adds from the container's pod sandbox, named like the
+// ones the kubelet sets.
+const (
+	LabelPodName      = "io.kubernetes.pod.name"
+	LabelPodNamespace = "io.kubernetes.pod.namespace"
+	LabelPodUID       = "io.kubernetes.pod.uid"
+)
+
+// networkPod names the network of the pod sandbox's IP addresses.
+const networkPod = "pod"
+
+func init() {
+	factory.Register("cri", &driverFactory{})
+}
+
+type driverFactory struct{}
+
+func (factory *driverFactory) Create(parameters map[string]interface{}) (containers.Driver, error) {
+	endpoint, ok := parameters["endpoint"].(string)
+	if !ok || endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	interval := defaultPollInterval
+	if raw, ok := parameters["poll_interval"].(string); ok && raw != "" {
+		var err error
+		if interval, err = time.ParseDuration(raw); err != nil {
+			return nil, fmt.Errorf("invalid poll interval %q: %v", raw, err)
+		} else if interval <= 0 {
+			return nil, fmt.Errorf("poll interval must be positive")
+		}
+	}
+
+	conn, err := grpcconn.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &driver{
+		conn:     conn,
+		interval: interval,
+	}, nil
+}
+
+// driver reads containers from a CRI runtime service. CRI has no event
+// stream every runtime supports so events are found by polling the
+// container list, like the kubelet does.
+type driver struct {
+	conn     *grpcconn.Conn
+	interval time.Duration
+}
+
+func (d *driver) listContainers(ctx context.Context) ([]*container, error) {
+	resp := &listContainersResponse{}
+	if err := d.conn.Invoke(ctx, methodListContainers, &listContainersRequest{}, resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Containers, nil
+}
+
+func (d *driver) listSandboxes(ctx context.Context, id string) (map[string]*podSandbox, error) {
+	req := &listPodSandboxRequest{}
+	if id != "" {
+		req.Filter = &podSandboxFilter{ID: id}
+	}
+
+	resp := &listPodSandboxResponse{}
+	if err := d.conn.Invoke(ctx, methodListPodSandbox, req, resp); err != nil {
+		return nil, err
+	}
+
+	sandboxes := make(map[string]*podSandbox)
+	for _, s := range resp.Items {
+		sandboxes[s.ID] = s
+	}
+
+	return sandboxes, nil
+}
+
+// running returns the running containers by ID.
+func (d *driver) running(ctx context.Context) (map[string]*v1.ContainerInfo, error) {
+	list, err := d.listContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sandboxes, err := d.listSandboxes(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*v1.ContainerInfo)
+	for _, c := range list {
+		if c.State == containerRunning {
+			result[c.ID] = convertContainer(c, sandboxes[c.PodSandboxID])
+		}
+	}
+
+	return result, nil
+}
+
+func containerName(name string, id string, sandbox *podSandbox) string {
+	if name == "" {
+		return id
+	} else if sandbox == nil || sandbox.Metadata == nil {
+		return name
+	}
+
+	return sandbox.Metadata.Namespace + "/" + sandbox.Metadata.Name + "/" + name
+}
+
+func convertState(state int32) v1.ContainerState {
+	switch state {
+	case containerRunning:
+		return v1.StateRunning
+	case containerCreated, containerExited:
+		return v1.StateStopped
+	}
+
+	return v1.StateUnknown
+}
+
+func podLabels(labels map[string]string, sandbox *podSandbox) map[string]string {
+	result := make(map[string]string)
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	if sandbox != nil && sandbox.Metadata != nil {
+		result[LabelPodName] = sandbox.Metadata.Name
+		result[LabelPodNamespace] = sandbox.Metadata.Namespace
+		result[LabelPodUID] = sandbox.Metadata.UID
+	}
+
+	return result
+}
+
+func convertContainer(c *container, sandbox *podSandbox) *v1.ContainerInfo {
+	name := ""
+	if c.Metadata != nil {
+		name = c.Metadata.Name
+	}
+
+	image := ""
+	if c.Image != nil {
+		image = c.Image.Image
+	}
+
+	info := &v1.ContainerInfo{
+		ID:          c.ID,
+		Name:        containerName(name, c.ID, sandbox),
+		Labels:      podLabels(c.Labels, sandbox),
+		Annotations: c.Annotations,
+		State:       convertState(c.State),
+		Created:     unixNano(c.CreatedAt),
+	}
+
+	setImage(info, image, c.ImageRef)
+	return info
+}
+
+// setImage sets the image fields from the image the container was created
+// with, and the digest from the runtime's image reference when that one
+// isn't pinned by digest.
+func setImage(info *v1.ContainerInfo, image string, imageRef string) {
+	containers.ParseImageReference(image).Apply(info)
+	if info.ImageDigest == "" {
+		info.ImageDigest = containers.ParseImageReference(imageRef).Digest
+	}
+}
+
+func convertStatus(s *containerStatus, sandbox *podSandbox) *v1.ContainerInfo {
+	name := ""
+	if s.Metadata != nil {
+		name = s.Metadata.Name
+	}
+
+	image := ""
+	if s.Image != nil {
+		image = s.Image.Image
+	}
+
+	info := &v1.ContainerInfo{
+		ID:          s.ID,
+		Name:        containerName(name, s.ID, sandbox),
+		Labels:      podLabels(s.Labels, sandbox),
+		Annotations: s.Annotations,
+		State:       convertState(s.State),
+		Created:     unixNano(s.CreatedAt),
+		StartedAt:   unixNano(s.StartedAt),
+		FinishedAt:  unixNano(s.FinishedAt),
+		Reason:      s.Reason,
+	}
+
+	setImage(info, image, s.ImageRef)
+	if s.Metadata != nil {
+		// the attempt counts the restarts of the container in its pod
+		restarts := int(s.Metadata.Attempt)
+		info.RestartCount = &restarts
+	}
+
+	if s.State == containerExited {
+		exitCode := int(s.ExitCode)
+		info.ExitCode = &exitCode
+	}
+
+	if s.Resources != nil && s.Resources.Linux != nil {
+		r := s.Resources.Linux
+		info.Resources = &v1.ResourceLimits{
+			CPUs:        containers.QuotaCPUs(r.CPUQuota, r.CPUPeriod),
+			CPUShares:   r.CPUShares,
+			MemoryLimit: r.MemoryLimitInBytes,
+		}
+	}
+
+	return info
+}
+
+func unixNano(ns int64) int64 {
+	if ns <= 0 {
+		return 0
+	}
+
+	return ns / int64(time.Second)
+}
+
+// sandboxNetwork returns the pod network of the sandbox, shared by all of
+// its containers.
+func (d *driver) sandboxNetwork(ctx context.Context, id string) (*v1.NetworkInfo, error) {
+	resp := &podSandboxStatusResponse{}
+	if err := d.conn.Invoke(ctx, methodPodSandboxStatus, &podSandboxStatusRequest{PodSandboxID: id}, resp); err != nil {
+		return nil, err
+	} else if resp.Status == nil || resp.Status.Network == nil || resp.Status.Network.IP == "" {
+		return nil, nil
+	}
+
+	n := &v1.NetworkInfo{
+		Name:        networkPod,
+		IPAddresses: []string{resp.Status.Network.IP},
+	}
+
+	for _, ip := range resp.Status.Network.AdditionalIPs {
+		n.IPAddresses = append(n.IPAddresses, ip.IP)
+	}
+
+	return n, nil
+}
+
+func (d *driver) status(ctx context.Context, c *container) (*v1.ContainerInfo, error) {
+	resp := &containerStatusResponse{}
+	if err := d.conn.Invoke(ctx, methodContainerStatus, &containerStatusRequest{ContainerID: c.ID}, resp); err != nil {
+		if grpcconn.IsNotFound(err) {
+			return nil, containers.ErrContainerNotFound
+		}
+
+		return nil, err
+	} else if resp.Status == nil {
+		return nil, containers.ErrContainerNotFound
+	}
+
+	sandboxes, err := d.listSandboxes(ctx, c.PodSandboxID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := convertStatus(resp.Status, sandboxes[c.PodSandboxID])
+	if c.PodSandboxID != "" {
+		n, err := d.sandboxNetwork(ctx, c.PodSandboxID)
+		if err != nil && !grpcconn.IsNotFound(err) {
+			return nil, err
+		} else if n != nil {
+			info.Networks = []*v1.NetworkInfo{n}
+		}
+	}
+
+	return info, nil
+}
+
+func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	known, err := d.running(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventChannel(ctx, d, known, types), nil
+}
+
+func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	running, err := d.running(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.ContainerInfo, 0, len(running))
+	for _, c := range running {
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// GetContainer finds a container by its ID or by its
+// `namespace/pod/container` name.
+func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	list, err := d.listContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sandboxes, err := d.listSandboxes(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var found *container
+	for _, c := range list {
+		info := convertContainer(c, sandboxes[c.PodSandboxID])
+		if info.ID != name && info.Name != name {
+			continue
+		}
+
+		// prefer the running attempt of a restarted container
+		if found == nil || c.State == containerRunning || c.CreatedAt > found.CreatedAt && found.State != containerRunning {
+			found = c
+		}
+	}
+
+	if found == nil {
+		return nil, containers.ErrContainerNotFound
+	}
+
+	return d.status(ctx, found)
+}
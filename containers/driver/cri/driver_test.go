@@ -0,0 +1,219 @@
+package cri
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/grpcconn/grpctest"
+)
+
+type fakeRuntime struct {
+	*grpctest.Server
+	mu         sync.Mutex
+	containers map[string]*container
+	exitCodes  map[string]int32
+}
+
+func newFakeRuntime(t *testing.T) *fakeRuntime {
+	srv, err := grpctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRuntime{
+		Server:     srv,
+		containers: make(map[string]*container),
+		exitCodes:  make(map[string]int32),
+	}
+
+	sandbox := &podSandbox{
+		ID:       "pod1",
+		Metadata: &podSandboxMetadata{Name: "web", Namespace: "default", UID: "uid1"},
+	}
+
+	f.Handle(methodListContainers, func(c *grpctest.Call) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp := &listContainersResponse{}
+		for _, cont := range f.containers {
+			resp.Containers = append(resp.Containers, cont)
+		}
+
+		return c.Send(resp)
+	})
+
+	f.Handle(methodListPodSandbox, func(c *grpctest.Call) error {
+		return c.Send(&listPodSandboxResponse{Items: []*podSandbox{sandbox}})
+	})
+
+	f.Handle(methodPodSandboxStatus, func(c *grpctest.Call) error {
+		return c.Send(&podSandboxStatusResponse{Status: &podSandboxStatus{
+			ID:      "pod1",
+			Network: &podSandboxNetworkStatus{IP: "10.0.0.5"},
+		}})
+	})
+
+	f.Handle(methodContainerStatus, func(c *grpctest.Call) error {
+		req := &containerStatusRequest{}
+		if err := c.Decode(req); err != nil {
+			return err
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		cont, ok := f.containers[req.ContainerID]
+		if !ok {
+			return grpctest.NotFound("container %q not found", req.ContainerID)
+		}
+
+		return c.Send(&containerStatusResponse{Status: &containerStatus{
+			ID:        cont.ID,
+			Metadata:  cont.Metadata,
+			State:     cont.State,
+			CreatedAt: cont.CreatedAt,
+			ExitCode:  f.exitCodes[cont.ID],
+			Image:     cont.Image,
+			ImageRef:  cont.ImageRef,
+			Labels:    cont.Labels,
+		}})
+	})
+
+	return f
+}
+
+func (f *fakeRuntime) run(id string, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers[id] = &container{
+		ID:           id,
+		PodSandboxID: "pod1",
+		Metadata:     &containerMetadata{Name: name},
+		Image:        &imageSpec{Image: "nginx:1.2"},
+		ImageRef:     "docker.io/library/nginx@sha256:abc",
+		State:        containerRunning,
+		CreatedAt:    int64(1700000000 * time.Second),
+		Labels:       map[string]string{"app": name},
+	}
+}
+
+func (f *fakeRuntime) exit(id string, code int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers[id].State = containerExited
+	f.exitCodes[id] = code
+}
+
+func newTestDriver(t *testing.T, f *fakeRuntime) containers.Driver {
+	d, err := (&driverFactory{}).Create(map[string]interface{}{
+		"endpoint":      f.Addr,
+		"poll_interval": "10ms",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func nextEvent(t *testing.T, ch containers.EventsChannel) *v1.ContainerEvent {
+	select {
+	case e, ok := <-ch.GetChannel():
+		if !ok {
+			t.Fatal("events channel closed")
+		}
+
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	return nil
+}
+
+func TestGetContainers(t *testing.T) {
+	f := newFakeRuntime(t)
+	defer f.Close()
+
+	f.run("c1", "app")
+	f.run("c2", "sidecar")
+	f.exit("c2", 0)
+	conts, err := newTestDriver(t, f).GetContainers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conts) != 1 {
+		t.Fatalf("got %d containers, want 1", len(conts))
+	}
+
+	c := conts[0]
+	if c.Name != "default/web/app" || c.State != v1.StateRunning || c.Created != 1700000000 {
+		t.Errorf("got name %q state %s created %d", c.Name, c.State, c.Created)
+	}
+
+	if c.Labels[LabelPodName] != "web" || c.Labels[LabelPodNamespace] != "default" || c.Labels[LabelPodUID] != "uid1" || c.Labels["app"] != "app" {
+		t.Errorf("got labels %v", c.Labels)
+	}
+}
+
+func TestGetContainer(t *testing.T) {
+	f := newFakeRuntime(t)
+	defer f.Close()
+
+	f.run("c1", "app")
+	f.exit("c1", 137)
+	d := newTestDriver(t, f)
+	for _, name := range []string{"c1", "default/web/app"} {
+		c, err := d.GetContainer(context.Background(), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if c.ID != "c1" || c.State != v1.StateStopped || c.ExitCode == nil || *c.ExitCode != 137 {
+			t.Errorf("%s: got id %q state %s exit code %v", name, c.ID, c.State, c.ExitCode)
+		} else if c.ImageDigest != "sha256:abc" {
+			t.Errorf("%s: got digest %q", name, c.ImageDigest)
+		} else if len(c.Networks) != 1 || c.Networks[0].IPAddresses[0] != "10.0.0.5" {
+			t.Errorf("%s: got networks %v", name, c.Networks)
+		}
+	}
+
+	if _, err := d.GetContainer(context.Background(), "nope"); err != containers.ErrContainerNotFound {
+		t.Errorf("got %v for a missing container", err)
+	}
+}
+
+func TestWatchEventsPollsChanges(t *testing.T) {
+	f := newFakeRuntime(t)
+	defer f.Close()
+
+	f.run("c1", "app")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := newTestDriver(t, f).WatchEvents(ctx, v1.EventContainerCreation, v1.EventContainerDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+
+	f.run("c2", "sidecar")
+	e := nextEvent(t, ch)
+	if e.Type != v1.EventContainerCreation || e.Container.ID != "c2" {
+		t.Fatalf("got %s for %q, want a creation of c2", e.Type, e.Container.ID)
+	}
+
+	f.exit("c1", 1)
+	e = nextEvent(t, ch)
+	if e.Type != v1.EventContainerDeletion || e.Container.ID != "c1" {
+		t.Fatalf("got %s for %q, want a deletion of c1", e.Type, e.Container.ID)
+	} else if e.Container.ExitCode == nil || *e.Container.ExitCode != 1 {
+		t.Errorf("got exit code %v", e.Container.ExitCode)
+	}
+}
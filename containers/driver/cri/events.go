@@ -0,0 +1,99 @@
+package cri
+
+import (
+	"context"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+type eventChannel struct {
+	cancel  context.CancelFunc
+	channel chan *v1.ContainerEvent
+}
+
+// newEventChannel polls the runtime and emits creation events for containers
+// that started running and deletion events for ones that stopped since the
+// last poll. The channel is closed when polling fails.
+func newEventChannel(ctx context.Context, d *driver, known map[string]*v1.ContainerInfo, types []v1.ContainerEventType) *eventChannel {
+	ctx, cancel := context.WithCancel(ctx)
+	ec := &eventChannel{
+		cancel:  cancel,
+		channel: make(chan *v1.ContainerEvent),
+	}
+
+	wanted := make(map[v1.ContainerEventType]bool)
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	emit := func(t v1.ContainerEventType, c *v1.ContainerInfo) bool {
+		if !wanted[t] {
+			return true
+		}
+
+		select {
+		case ec.channel <- &v1.ContainerEvent{Type: t, Container: c, Timestamp: time.Now().Unix()}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(ec.channel)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			running, err := d.running(ctx)
+			if err != nil {
+				return
+			}
+
+			for id, c := range known {
+				if _, ok := running[id]; ok {
+					continue
+				}
+
+				resp := &containerStatusResponse{}
+				if err := d.conn.Invoke(ctx, methodContainerStatus, &containerStatusRequest{ContainerID: id}, resp); err == nil && resp.Status != nil && resp.Status.State == containerExited {
+					exitCode := int(resp.Status.ExitCode)
+					c.ExitCode = &exitCode
+				}
+
+				if !emit(v1.EventContainerDeletion, c) {
+					return
+				}
+			}
+
+			for id, c := range running {
+				if _, ok := known[id]; ok {
+					continue
+				}
+
+				if !emit(v1.EventContainerCreation, c) {
+					return
+				}
+			}
+
+			known = running
+		}
+	}()
+
+	return ec
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	ec.cancel()
+	return nil
+}
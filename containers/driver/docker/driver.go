@@ -0,0 +1,498 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/factory"
+)
+
+const (
+	defaultHost = "unix:///var/run/docker.sock"
+
+	// requestTimeout bounds the engine's answers, except the events stream
+	// which stays open.
+	requestTimeout = 30 * time.Second
+)
+
+func init() {
+	factory.Register("docker", &driverFactory{})
+}
+
+type driverFactory struct{}
+
+func (factory *driverFactory) Create(parameters map[string]interface{}) (containers.Driver, error) {
+	host := stringParam(parameters, "host")
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+
+	if host == "" {
+		host = defaultHost
+	}
+
+	tlsConfig, err := tlsFromParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(host, stringParam(parameters, "api_version"), tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &driver{
+		client: c,
+	}, nil
+}
+
+func stringParam(parameters map[string]interface{}, key string) string {
+	if v, ok := parameters[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
+
+func tlsFromParameters(parameters map[string]interface{}) (*tls.Config, error) {
+	caFile := stringParam(parameters, "tls_ca_file")
+	certFile := stringParam(parameters, "tls_cert_file")
+	keyFile := stringParam(parameters, "tls_key_file")
+	if caFile == "" && certFile == "" && keyFile == "" && stringParam(parameters, "tls") != "true" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca bundle: %v", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// client is a minimal Docker Engine API client for the endpoints the driver
+// needs.
+type client struct {
+	http    *http.Client
+	base    string
+	version string
+	timeout time.Duration
+}
+
+func newClient(host string, version string, tlsConfig *tls.Config) (*client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %v", host, err)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext:     dialer.DialContext,
+	}
+
+	c := &client{
+		http:    &http.Client{Transport: transport},
+		version: strings.TrimPrefix(version, "v"),
+		timeout: requestTimeout,
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+
+		c.base = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if tlsConfig != nil || u.Scheme == "https" {
+			scheme = "https"
+		}
+
+		c.base = scheme + "://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+	}
+
+	return c, nil
+}
+
+func (c *client) url(path string, query url.Values) string {
+	if c.version != "" {
+		path = "/v" + c.version + path
+	}
+
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	return u
+}
+
+func (c *client) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, containers.ErrContainerNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("docker api %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// get decodes the answer to a request that's bounded by the client's
+// timeout.
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.do(ctx, path, query)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type endpoint struct {
+	IPAddress         string `json:"IPAddress"`
+	GlobalIPv6Address string `json:"GlobalIPv6Address"`
+}
+
+type containerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+	State   string            `json:"State"`
+	Ports   []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]*endpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type containerJSON struct {
+	ID           string   `json:"Id"`
+	Name         string   `json:"Name"`
+	Created      string   `json:"Created"`
+	Path         string   `json:"Path"`
+	Args         []string `json:"Args"`
+	Image        string   `json:"Image"`
+	RestartCount int      `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"`
+		ExitCode   int    `json:"ExitCode"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		NanoCPUs  int64 `json:"NanoCpus"`
+		CPUShares int64 `json:"CpuShares"`
+		CPUQuota  int64 `json:"CpuQuota"`
+		CPUPeriod int64 `json:"CpuPeriod"`
+		Memory    int64 `json:"Memory"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+		Networks map[string]*endpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type imageJSON struct {
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// parseTime reads the engine's RFC 3339 times, which are the zero time when
+// unset.
+func parseTime(raw string) int64 {
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil || t.Year() <= 1 {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+func convertNetworks(networks map[string]*endpoint) []*v1.NetworkInfo {
+	result := make([]*v1.NetworkInfo, 0, len(networks))
+	for name, e := range networks {
+		n := &v1.NetworkInfo{Name: name}
+		for _, ip := range []string{e.IPAddress, e.GlobalIPv6Address} {
+			if ip != "" {
+				n.IPAddresses = append(n.IPAddresses, ip)
+			}
+		}
+
+		result = append(result, n)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func convertState(status string) v1.ContainerState {
+	switch status {
+	case "running", "paused", "restarting":
+		return v1.StateRunning
+	case "created", "exited", "dead", "removing":
+		return v1.StateStopped
+	}
+
+	return v1.StateUnknown
+}
+
+func convertSummary(s *containerSummary) *v1.ContainerInfo {
+	name := ""
+	if len(s.Names) > 0 {
+		name = strings.TrimPrefix(s.Names[0], "/")
+	}
+
+	info := &v1.ContainerInfo{
+		ID:       s.ID,
+		Name:     name,
+		Labels:   s.Labels,
+		State:    convertState(s.State),
+		Created:  s.Created,
+		Networks: convertNetworks(s.NetworkSettings.Networks),
+	}
+
+	containers.ParseImageReference(s.Image).Apply(info)
+
+	for _, p := range s.Ports {
+		info.Ports = append(info.Ports, &v1.PortMapping{
+			ContainerPort: p.PrivatePort,
+			Protocol:      p.Type,
+			HostIP:        p.IP,
+			HostPort:      p.PublicPort,
+		})
+	}
+
+	return info
+}
+
+func convertContainerJSON(c *containerJSON) *v1.ContainerInfo {
+	restarts := c.RestartCount
+	info := &v1.ContainerInfo{
+		ID:           c.ID,
+		Name:         strings.TrimPrefix(c.Name, "/"),
+		Labels:       c.Config.Labels,
+		State:        convertState(c.State.Status),
+		Created:      parseTime(c.Created),
+		StartedAt:    parseTime(c.State.StartedAt),
+		FinishedAt:   parseTime(c.State.FinishedAt),
+		RestartCount: &restarts,
+		Command:      append([]string{c.Path}, c.Args...),
+		Networks:     convertNetworks(c.NetworkSettings.Networks),
+	}
+
+	containers.ParseImageReference(c.Config.Image).Apply(info)
+	if c.Path == "" {
+		info.Command = nil
+	}
+
+	if c.State.Status == "exited" || c.State.Status == "dead" {
+		exitCode := c.State.ExitCode
+		info.ExitCode = &exitCode
+	}
+
+	for spec, bindings := range c.NetworkSettings.Ports {
+		parts := strings.SplitN(spec, "/", 2)
+		port, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+
+		protocol := "tcp"
+		if len(parts) == 2 {
+			protocol = parts[1]
+		}
+
+		if len(bindings) == 0 {
+			info.Ports = append(info.Ports, &v1.PortMapping{ContainerPort: port, Protocol: protocol})
+		}
+
+		for _, b := range bindings {
+			hostPort, _ := strconv.Atoi(b.HostPort)
+			info.Ports = append(info.Ports, &v1.PortMapping{
+				ContainerPort: port,
+				Protocol:      protocol,
+				HostIP:        b.HostIP,
+				HostPort:      hostPort,
+			})
+		}
+	}
+
+	sort.Slice(info.Ports, func(i, j int) bool {
+		return info.Ports[i].String() < info.Ports[j].String()
+	})
+
+	hc := c.HostConfig
+	if hc.NanoCPUs != 0 || hc.CPUShares != 0 || hc.CPUQuota != 0 || hc.Memory != 0 {
+		info.Resources = &v1.ResourceLimits{
+			CPUs:        float64(hc.NanoCPUs) / 1e9,
+			CPUShares:   hc.CPUShares,
+			MemoryLimit: hc.Memory,
+		}
+
+		if hc.NanoCPUs == 0 {
+			// the engine's default period is 100ms
+			period := hc.CPUPeriod
+			if period == 0 {
+				period = 100000
+			}
+
+			info.Resources.CPUs = containers.QuotaCPUs(hc.CPUQuota, period)
+		}
+	}
+
+	return info
+}
+
+// imageDigest finds the repository digest of the container's image, the one
+// of the repository it was pulled from when there are several. Locally built
+// images have none.
+func (d *driver) imageDigest(ctx context.Context, imageID string, info *v1.ContainerInfo) string {
+	image := &imageJSON{}
+	if err := d.client.get(ctx, "/images/"+url.PathEscape(imageID)+"/json", nil, image); err != nil {
+		return ""
+	}
+
+	for _, raw := range image.RepoDigests {
+		ref := containers.ParseImageReference(raw)
+		if ref.Registry == info.ImageRegistry && ref.Repository == info.ImageRepository {
+			return ref.Digest
+		}
+	}
+
+	if len(image.RepoDigests) > 0 {
+		return containers.ParseImageReference(image.RepoDigests[0]).Digest
+	}
+
+	return ""
+}
+
+type driver struct {
+	client *client
+}
+
+func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	wanted := make(map[string]v1.ContainerEventType)
+	for _, t := range types {
+		if action, ok := eventActions[t]; ok {
+			wanted[action] = t
+		}
+	}
+
+	actions := make([]string, 0, len(wanted))
+	for action := range wanted {
+		actions = append(actions, action)
+	}
+
+	filters, err := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": actions,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := d.client.do(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return newEventChannel(ctx, resp.Body, wanted, cancel), nil
+}
+
+func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	var summaries []*containerSummary
+	if err := d.client.get(ctx, "/containers/json", nil, &summaries); err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.ContainerInfo, 0, len(summaries))
+	for _, s := range summaries {
+		result = append(result, convertSummary(s))
+	}
+
+	return result, nil
+}
+
+func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	c := &containerJSON{}
+	if err := d.client.get(ctx, "/containers/"+url.PathEscape(name)+"/json", nil, c); err != nil {
+		return nil, err
+	}
+
+	info := convertContainerJSON(c)
+	if info.ImageDigest == "" && c.Image != "" {
+		info.ImageDigest = d.imageDigest(ctx, c.Image, info)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// serveUnix serves the handler on a unix socket and returns its docker host.
+func serveUnix(t *testing.T, handler http.Handler) (string, func()) {
+	dir, err := ioutil.TempDir("", "csense-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(l)
+	return "unix://" + socket, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newFakeEngine answers container lookups with canned engine responses.
+func newFakeEngine(t *testing.T, responses map[string]string) (*driver, func()) {
+	host, stop := serveUnix(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	c, err := newClient(host, "1.24", nil)
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+
+	return &driver{client: c}, stop
+}
+
+func TestGetContainers(t *testing.T) {
+	d, stop := newFakeEngine(t, map[string]string{
+		"/v1.24/containers/json": `[{
+			"Id": "8dfafdbc3a40",
+			"Names": ["/web"],
+			"Image": "nginx:1.25",
+			"Created": 1700000000,
+			"Labels": {"app": "web"},
+			"State": "running",
+			"Ports": [{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}],
+			"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+		}]`,
+	})
+
+	defer stop()
+	conts, err := d.GetContainers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conts) != 1 {
+		t.Fatalf("got %d containers", len(conts))
+	}
+
+	c := conts[0]
+	if c.ID != "8dfafdbc3a40" || c.Name != "web" || c.State != v1.StateRunning || c.Created != 1700000000 {
+		t.Errorf("got %+v", c)
+	}
+
+	if c.ImageName != "nginx" || c.ImageTag != "1.25" || c.Labels["app"] != "web" {
+		t.Errorf("got image %q:%q and labels %v", c.ImageName, c.ImageTag, c.Labels)
+	}
+
+	if len(c.Ports) != 1 || c.Ports[0].String() != "0.0.0.0:8080->80/tcp" {
+		t.Errorf("got ports %v", c.Ports)
+	}
+
+	if len(c.Networks) != 1 || c.Networks[0].Name != "bridge" || c.Networks[0].IPAddresses[0] != "172.17.0.2" {
+		t.Errorf("got networks %v", c.Networks)
+	}
+}
+
+func TestGetContainer(t *testing.T) {
+	d, stop := newFakeEngine(t, map[string]string{
+		"/v1.24/containers/web/json": `{
+			"Id": "8dfafdbc3a40",
+			"Name": "/web",
+			"Created": "2023-11-14T22:13:20Z",
+			"Path": "nginx",
+			"Args": ["-g", "daemon off;"],
+			"Image": "sha256:a6bd71f48f68",
+			"RestartCount": 2,
+			"State": {"Status": "exited", "ExitCode": 137, "StartedAt": "2023-11-14T22:13:21Z", "FinishedAt": "2023-11-14T22:15:00Z"},
+			"Config": {"Image": "nginx:1.25", "Labels": {"app": "web"}},
+			"NetworkSettings": {"Ports": {"80/tcp": [{"HostIp": "0.0.0.0", "HostPort": "8080"}], "443/tcp": null}}
+		}`,
+		"/v1.24/images/sha256:a6bd71f48f68/json": `{"RepoDigests": ["nginx@sha256:0d17b565c37b"]}`,
+	})
+
+	defer stop()
+	c, err := d.GetContainer(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ID != "8dfafdbc3a40" || c.Name != "web" || c.State != v1.StateStopped || c.Labels["app"] != "web" {
+		t.Errorf("got %+v", c)
+	}
+
+	if c.ExitCode == nil || *c.ExitCode != 137 || c.FinishedAt != 1700000100 || c.RestartCount == nil || *c.RestartCount != 2 {
+		t.Errorf("got exit code %v at %d after %v restarts", c.ExitCode, c.FinishedAt, c.RestartCount)
+	}
+
+	if c.ImageDigest != "sha256:0d17b565c37b" {
+		t.Errorf("got image digest %q", c.ImageDigest)
+	}
+
+	if len(c.Ports) != 2 || c.Ports[0].String() != "0.0.0.0:8080->80/tcp" || c.Ports[1].String() != "443/tcp" {
+		t.Errorf("got ports %v", c.Ports)
+	}
+
+	if _, err := d.GetContainer(context.Background(), "db"); err == nil {
+		t.Error("got an unknown container")
+	}
+}
+
+func TestGetContainerTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	host, stop := serveUnix(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+
+	defer stop()
+	defer close(release)
+	c, err := newClient(host, "1.24", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.timeout = 50 * time.Millisecond
+	d := &driver{client: c}
+	started := time.Now()
+	if _, err := d.GetContainer(context.Background(), "web"); err == nil {
+		t.Error("got a container from a daemon that never answered")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("lookup took %v", elapsed)
+	}
+}
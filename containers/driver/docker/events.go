@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+)
+
+// eventActions maps event types to the docker container actions that
+// produce them.
+var eventActions = map[v1.ContainerEventType]string{
+	v1.EventContainerCreation: "start",
+	v1.EventContainerDeletion: "die",
+	v1.EventContainerOomKill:  "oom",
+}
+
+// attributes docker adds to container events next to the container labels
+var eventAttributes = map[string]bool{
+	"name":     true,
+	"image":    true,
+	"exitCode": true,
+	"signal":   true,
+}
+
+type message struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time int64 `json:"time"`
+}
+
+type eventChannel struct {
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	channel chan *v1.ContainerEvent
+}
+
+func newEventChannel(ctx context.Context, body io.ReadCloser, wanted map[string]v1.ContainerEventType, cancel context.CancelFunc) *eventChannel {
+	ec := &eventChannel{
+		body:    body,
+		cancel:  cancel,
+		channel: make(chan *v1.ContainerEvent),
+	}
+
+	go func() {
+		defer close(ec.channel)
+		defer body.Close()
+		dec := json.NewDecoder(body)
+		for {
+			m := &message{}
+			if err := dec.Decode(m); err != nil {
+				return
+			}
+
+			// actions such as `exec_start: sh` carry their arguments
+			action := strings.SplitN(m.Action, ":", 2)[0]
+			t, ok := wanted[action]
+			if m.Type != "container" || !ok {
+				continue
+			}
+
+			// nobody reads the channel once it's closed
+			select {
+			case ec.channel <- convertMessage(m, t):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ec
+}
+
+func convertMessage(m *message, t v1.ContainerEventType) *v1.ContainerEvent {
+	attrs := m.Actor.Attributes
+	info := &v1.ContainerInfo{
+		ID:     m.Actor.ID,
+		Name:   attrs["name"],
+		Labels: make(map[string]string),
+	}
+
+	containers.ParseImageReference(attrs["image"]).Apply(info)
+
+	for k, v := range attrs {
+		if !eventAttributes[k] {
+			info.Labels[k] = v
+		}
+	}
+
+	if code, err := strconv.Atoi(attrs["exitCode"]); err == nil {
+		info.ExitCode = &code
+	}
+
+	return &v1.ContainerEvent{
+		Type:      t,
+		Container: info,
+		Timestamp: m.Time,
+	}
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	ec.cancel()
+	return ec.body.Close()
+}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// newFakeDaemon serves a stream of container start events on a unix socket
+// until the client goes away.
+func newFakeDaemon(t *testing.T) (string, func()) {
+	return serveUnix(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.24/events" {
+			http.NotFound(w, r)
+			return
+		}
+
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, `{"Type":"container","Action":"start","Actor":{"ID":"c%d","Attributes":{"name":"web%d","image":"nginx:1.25"}},"time":%d}`+"\n", i, i, 1700000000+i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}))
+}
+
+func TestWatchEventsStopsAfterClose(t *testing.T) {
+	host, stop := newFakeDaemon(t)
+	defer stop()
+
+	c, err := newClient(host, "1.24", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &driver{client: c}
+	ec, err := d.WatchEvents(context.Background(), v1.EventContainerCreation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-ec.GetChannel()
+	if event.Type != v1.EventContainerCreation || event.Container.Name != "web0" || event.Container.ID != "c0" {
+		t.Errorf("got %+v", event)
+	}
+
+	// the daemon keeps sending while nobody reads the channel
+	time.Sleep(20 * time.Millisecond)
+	ec.Close()
+	select {
+	case _, ok := <-ec.GetChannel():
+		if ok {
+			t.Error("got an event sent after the channel was closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("channel not closed")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -53,6 +54,7 @@ func (factory *driverFactory) Create(parameters map[string]interface{}) (contain
 
 	d := &driver{
 		manager: m,
+		refs:    make(map[string]cadvisorV1.ContainerReference),
 	}
 
 	if err = m.Start(); err != nil {
@@ -82,6 +84,11 @@ func init() {
 
 type driver struct {
 	manager manager.Manager
+
+	// refs holds the references of the containers seen by cgroup path,
+	// cAdvisor forgets a container before its deletion event is sent.
+	mu   sync.Mutex
+	refs map[string]cadvisorV1.ContainerReference
 }
 
 func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
@@ -95,40 +102,156 @@ func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType
 		return nil, err
 	}
 
-	return newEventChannel(cec), nil
+	return newEventChannel(cec, d), nil
 }
 
-func parseImageData(image string) (string, string) {
-	parts := strings.Split(image, ":")
-	if len(parts) < 2 {
-		return image, ""
-	} else if parts[1] == "" {
-		parts[1] = "latest"
+// containerIDPattern matches the full container IDs runtimes put in cgroup
+// paths.
+var containerIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// applyReference names the container after its runtime name, the first of
+// its aliases that isn't its ID, and keeps the cgroup path cAdvisor names it
+// by separately. Containers without aliases keep the cgroup path as name.
+func applyReference(c *v1.ContainerInfo, ref cadvisorV1.ContainerReference) {
+	c.CgroupPath = ref.Name
+	c.ID = ref.Id
+	if c.ID == "" {
+		for _, alias := range ref.Aliases {
+			if containerIDPattern.MatchString(alias) && strings.Contains(ref.Name, alias) {
+				c.ID = alias
+				break
+			}
+		}
+	}
+
+	c.Name = ref.Name
+	for _, alias := range ref.Aliases {
+		if alias != c.ID {
+			c.Name = alias
+			break
+		}
 	}
 
-	return parts[0], parts[1]
+	if len(ref.Aliases) > 0 {
+		c.Aliases = append([]string{}, ref.Aliases...)
+	}
+}
+
+func (d *driver) remember(ref cadvisorV1.ContainerReference) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refs[ref.Name] = ref
+}
+
+// reference returns the reference of the container at the cgroup path,
+// falling back to the last one seen when cAdvisor no longer knows it. The
+// reference is forgotten when the container is gone.
+func (d *driver) reference(cgroupPath string, gone bool) cadvisorV1.ContainerReference {
+	ref := cadvisorV1.ContainerReference{Name: cgroupPath}
+	specs, err := d.manager.GetContainerSpec(cgroupPath, cadvisorV2.RequestOptions{IdType: cadvisorV2.TypeName})
+	if spec, ok := specs[cgroupPath]; err == nil && ok {
+		ref.Aliases = spec.Aliases
+		ref.Namespace = spec.Namespace
+		d.remember(ref)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if known, ok := d.refs[cgroupPath]; ok {
+		ref = known
+	}
+
+	if gone {
+		delete(d.refs, cgroupPath)
+	}
+
+	return ref
+}
+
+func isNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unable to find data for container") ||
+		strings.Contains(msg, "unknown container") ||
+		strings.Contains(msg, "unable to find Docker container")
+}
+
+// unlimitedMemory is the smallest memory limit treated as no limit, cgroups
+// report the lack of one as the largest page aligned int64.
+const unlimitedMemory = 1 << 62
+
+// resourceLimits returns nil when the container has none of the limits.
+func resourceLimits(cpus float64, shares uint64, memory uint64) *v1.ResourceLimits {
+	limits := &v1.ResourceLimits{
+		CPUs:      cpus,
+		CPUShares: int64(shares),
+	}
+
+	if memory < unlimitedMemory {
+		limits.MemoryLimit = int64(memory)
+	}
+
+	if *limits == (v1.ResourceLimits{}) {
+		return nil
+	}
+
+	return limits
 }
 
 func convertContainerInfo(info cadvisorV1.ContainerInfo) *v1.ContainerInfo {
-	imageName, imageTag := parseImageData(info.Spec.Image)
-	return &v1.ContainerInfo{
-		Name:      info.Name,
-		ImageName: imageName,
-		ImageTag:  imageTag,
-		Labels:    info.Labels,
-		State:     v1.StateUnknown,
+	c := &v1.ContainerInfo{
+		Labels: info.Labels,
+		State:  v1.StateUnknown,
 	}
+
+	applyReference(c, info.ContainerReference)
+	containers.ParseImageReference(info.Spec.Image).Apply(c)
+
+	if !info.Spec.CreationTime.IsZero() {
+		c.Created = info.Spec.CreationTime.Unix()
+	}
+
+	var cpus float64
+	var shares, memory uint64
+	if info.Spec.HasCpu {
+		cpus = containers.QuotaCPUs(int64(info.Spec.Cpu.Quota), int64(info.Spec.Cpu.Period))
+		shares = info.Spec.Cpu.Limit
+	}
+
+	if info.Spec.HasMemory {
+		memory = info.Spec.Memory.Limit
+	}
+
+	c.Resources = resourceLimits(cpus, shares, memory)
+	return c
 }
 
-func convertContainerSpec(name string, spec cadvisorV2.ContainerSpec) *v1.ContainerInfo {
-	imageName, imageTag := parseImageData(spec.Image)
-	return &v1.ContainerInfo{
-		Name:      name,
-		ImageName: imageName,
-		ImageTag:  imageTag,
-		Labels:    spec.Labels,
-		State:     v1.StateUnknown,
+func convertContainerSpec(ref cadvisorV1.ContainerReference, spec cadvisorV2.ContainerSpec) *v1.ContainerInfo {
+	c := &v1.ContainerInfo{
+		Labels: spec.Labels,
+		State:  v1.StateUnknown,
+	}
+
+	applyReference(c, ref)
+	containers.ParseImageReference(spec.Image).Apply(c)
+
+	if !spec.CreationTime.IsZero() {
+		c.Created = spec.CreationTime.Unix()
+	}
+
+	var cpus float64
+	var shares, memory uint64
+	if spec.HasCpu {
+		// the hard limit is in milli-cpus
+		cpus = float64(spec.Cpu.MaxLimit) / 1000
+		shares = spec.Cpu.Limit
+	}
+
+	if spec.HasMemory {
+		memory = spec.Memory.Limit
 	}
+
+	c.Resources = resourceLimits(cpus, shares, memory)
+	return c
 }
 
 func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
@@ -140,26 +263,69 @@ func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error)
 
 	result := make([]*v1.ContainerInfo, 0)
 	for _, info := range rawContainers {
+		d.remember(info.ContainerReference)
 		result = append(result, convertContainerInfo(info))
 	}
 
 	return result, nil
 }
 
+// knownCgroupPath returns the cgroup path of a container seen before with
+// the runtime name, alias or ID.
+func (d *driver) knownCgroupPath(name string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for cgroupPath, ref := range d.refs {
+		if ref.Id == name {
+			return cgroupPath, true
+		}
+
+		for _, alias := range ref.Aliases {
+			if alias == name {
+				return cgroupPath, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// GetContainer finds the container by its cgroup path, or by its runtime
+// name, alias or ID. Names of containers seen before are looked up by their
+// cgroup path, cAdvisor only finds other names of docker containers.
 func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	idType := cadvisorV2.TypeDocker
+	if strings.HasPrefix(name, "/") {
+		idType = cadvisorV2.TypeName
+	} else if cgroupPath, ok := d.knownCgroupPath(name); ok {
+		name = cgroupPath
+		idType = cadvisorV2.TypeName
+	}
+
 	specMap, err := d.manager.GetContainerSpec(name, cadvisorV2.RequestOptions{
-		IdType:    "name",
+		IdType:    idType,
 		Count:     0,
 		Recursive: false,
 	})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "unable to find data for container") {
+		if isNotFound(err) {
 			return nil, containers.ErrContainerNotFound
 		}
 
 		return nil, err
 	}
 
-	return convertContainerSpec(name, specMap[name]), nil
+	for cgroupPath, spec := range specMap {
+		ref := cadvisorV1.ContainerReference{
+			Name:      cgroupPath,
+			Aliases:   spec.Aliases,
+			Namespace: spec.Namespace,
+		}
+
+		d.remember(ref)
+		return convertContainerSpec(ref, spec), nil
+	}
+
+	return nil, containers.ErrContainerNotFound
 }
@@ -0,0 +1,57 @@
+package embedded
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cadvisorV1 "github.com/google/cadvisor/info/v1"
+	cadvisorV2 "github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+)
+
+// fakeManager knows containers by cgroup path the way cAdvisor does for
+// runtimes other than docker, which it can't find by name.
+type fakeManager struct {
+	manager.Manager
+	specs map[string]cadvisorV2.ContainerSpec
+}
+
+func (m *fakeManager) GetContainerSpec(name string, options cadvisorV2.RequestOptions) (map[string]cadvisorV2.ContainerSpec, error) {
+	if options.IdType != cadvisorV2.TypeName {
+		return nil, errors.New("unable to find Docker container " + name)
+	}
+
+	spec, ok := m.specs[name]
+	if !ok {
+		return nil, errors.New("unknown container " + name)
+	}
+
+	return map[string]cadvisorV2.ContainerSpec{name: spec}, nil
+}
+
+func TestGetContainerByKnownAlias(t *testing.T) {
+	cgroupPath := "/system.slice/crio-web.scope"
+	d := &driver{
+		manager: &fakeManager{specs: map[string]cadvisorV2.ContainerSpec{
+			cgroupPath: {Aliases: []string{"web"}, Namespace: "crio"},
+		}},
+		refs: make(map[string]cadvisorV1.ContainerReference),
+	}
+
+	if _, err := d.GetContainer(context.Background(), "web"); err == nil {
+		t.Fatal("got a container that was never seen")
+	}
+
+	d.remember(cadvisorV1.ContainerReference{Name: cgroupPath, Aliases: []string{"web"}})
+	for _, name := range []string{"web", cgroupPath} {
+		c, err := d.GetContainer(context.Background(), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if c.Name != "web" || c.CgroupPath != cgroupPath {
+			t.Errorf("%s: got name %q and cgroup path %q", name, c.Name, c.CgroupPath)
+		}
+	}
+}
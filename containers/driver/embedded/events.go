@@ -11,7 +11,7 @@ type eventChannel struct {
 	channel chan *v1.ContainerEvent
 }
 
-func newEventChannel(cec *events.EventChannel) *eventChannel {
+func newEventChannel(cec *events.EventChannel, d *driver) *eventChannel {
 	ec := &eventChannel{
 		inner:   cec,
 		channel: make(chan *v1.ContainerEvent),
@@ -20,13 +20,14 @@ func newEventChannel(cec *events.EventChannel) *eventChannel {
 	go func() {
 		for src := range cec.GetChannel() {
 			e := &v1.ContainerEvent{
-				Container: &v1.ContainerInfo{
-					Name: src.ContainerName,
-				},
+				Container: &v1.ContainerInfo{},
 				Timestamp: src.Timestamp.Unix(),
 				Type:      v1.ContainerEventType(string(src.EventType)),
 			}
 
+			ref := d.reference(src.ContainerName, e.Type == v1.EventContainerDeletion)
+			applyReference(e.Container, ref)
+
 			ec.channel <- e
 		}
 
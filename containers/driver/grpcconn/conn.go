@@ -0,0 +1,244 @@
+// Package grpcconn is a minimal gRPC client for talking to container runtimes
+// over their local sockets. It speaks HTTP/2 without TLS and supports the
+// unary and server streaming calls the runtime drivers need.
+package grpcconn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// maxMessageSize bounds the size of a single received message.
+const maxMessageSize = 16 << 20
+
+// gRPC status codes the drivers look for.
+const (
+	CodeOK          = 0
+	CodeNotFound    = 5
+	CodeUnavailable = 14
+)
+
+// StatusError is a non-OK status returned by the server.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", err.Code, err.Message)
+}
+
+// IsNotFound reports whether err is a NotFound status.
+func IsNotFound(err error) bool {
+	serr, ok := err.(*StatusError)
+	return ok && serr.Code == CodeNotFound
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns a context whose calls send the given metadata in
+// addition to the connection's.
+func WithMetadata(ctx context.Context, key string, value string) context.Context {
+	md := map[string]string{key: value}
+	if parent, ok := ctx.Value(metadataKey{}).(map[string]string); ok {
+		for k, v := range parent {
+			if k != key {
+				md[k] = v
+			}
+		}
+	}
+
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+type Conn struct {
+	// Metadata is sent as headers with every call.
+	Metadata map[string]string
+	client   *http.Client
+	base     string
+}
+
+// Dial returns a connection to a `unix://` socket path or a `tcp://`
+// address, a bare path is taken to be a unix socket. Connections are made
+// lazily on the first call.
+func Dial(addr string) (*Conn, error) {
+	network, address := "unix", addr
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", addr, err)
+		}
+
+		switch u.Scheme {
+		case "unix":
+			address = u.Path
+		case "tcp":
+			network, address = "tcp", u.Host
+		default:
+			return nil, fmt.Errorf("unsupported address scheme %q", u.Scheme)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+
+		Protocols: new(http.Protocols),
+	}
+
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &Conn{
+		client: &http.Client{Transport: transport},
+		base:   "http://localhost",
+	}, nil
+}
+
+// Invoke makes a unary call.
+func (c *Conn) Invoke(ctx context.Context, method string, in proto.Message, out proto.Message) error {
+	s, err := c.NewStream(ctx, method, in)
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+	if err := s.Recv(out); err == io.EOF {
+		return errors.New("rpc error: no response message")
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewStream starts a server streaming call, messages are read with Recv.
+func (c *Conn) NewStream(ctx context.Context, method string, in proto.Message) (*Stream, error) {
+	payload, err := proto.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequest(http.MethodPost, c.base+method, bytes.NewReader(frame))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+	for k, v := range c.Metadata {
+		req.Header.Set(k, v)
+	}
+
+	if md, ok := ctx.Value(metadataKey{}).(map[string]string); ok {
+		for k, v := range md {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("rpc error: unexpected http status %d", resp.StatusCode)
+	}
+
+	// trailers-only responses carry the status in the headers
+	if err := status(resp.Header); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	return &Stream{
+		resp:   resp,
+		cancel: cancel,
+	}, nil
+}
+
+func status(h http.Header) error {
+	raw := h.Get("Grpc-Status")
+	if raw == "" {
+		return nil
+	}
+
+	code, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("rpc error: invalid status %q", raw)
+	} else if code == CodeOK {
+		return nil
+	}
+
+	msg, err := url.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		msg = h.Get("Grpc-Message")
+	}
+
+	return &StatusError{Code: code, Message: msg}
+}
+
+type Stream struct {
+	resp   *http.Response
+	cancel context.CancelFunc
+}
+
+// Recv reads the next message into out. It returns io.EOF once the server
+// ends the call successfully and the call's status otherwise.
+func (s *Stream) Recv(out proto.Message) error {
+	var header [5]byte
+	if _, err := io.ReadFull(s.resp.Body, header[:]); err == io.EOF {
+		if err := status(s.resp.Trailer); err != nil {
+			return err
+		}
+
+		return io.EOF
+	} else if err != nil {
+		return err
+	}
+
+	if header[0] != 0 {
+		return errors.New("rpc error: compressed messages are not supported")
+	}
+
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxMessageSize {
+		return fmt.Errorf("rpc error: message of %d bytes exceeds the limit", n)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.resp.Body, buf); err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(buf, out)
+}
+
+// Close ends the call.
+func (s *Stream) Close() error {
+	s.cancel()
+	return s.resp.Body.Close()
+}
@@ -0,0 +1,185 @@
+// Package grpctest runs in-process gRPC servers to test the drivers built on
+// grpcconn against.
+package grpctest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/danielkrainas/csense/containers/driver/grpcconn"
+)
+
+// codeUnknown is the status of calls whose handler returns an error that
+// isn't a status.
+const codeUnknown = 2
+
+// Call is a call made to the server.
+type Call struct {
+	Method string
+	Header http.Header
+	ctx    context.Context
+	req    []byte
+	w      http.ResponseWriter
+}
+
+// Context is done once the client ends the call.
+func (c *Call) Context() context.Context {
+	return c.ctx
+}
+
+// Decode decodes the request message.
+func (c *Call) Decode(m proto.Message) error {
+	return proto.Unmarshal(c.req, m)
+}
+
+// Send sends a response message.
+func (c *Call) Send(m proto.Message) error {
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return c.SendRaw(payload)
+}
+
+// SendRaw sends an already encoded response message.
+func (c *Call) SendRaw(payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if _, err := c.w.Write(frame); err != nil {
+		return err
+	}
+
+	c.w.(http.Flusher).Flush()
+	return nil
+}
+
+// Handler serves a method. Returning a *grpcconn.StatusError ends the call
+// with its status.
+type Handler func(c *Call) error
+
+type Server struct {
+	// Addr is the `unix://` address to dial.
+	Addr     string
+	dir      string
+	srv      *http.Server
+	mu       sync.Mutex
+	handlers map[string]Handler
+}
+
+// NewServer starts a server on a unix socket in a temporary directory.
+func NewServer() (*Server, error) {
+	dir, err := ioutil.TempDir("", "grpctest")
+	if err != nil {
+		return nil, err
+	}
+
+	sock := filepath.Join(dir, "grpc.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:     "unix://" + sock,
+		dir:      dir,
+		handlers: make(map[string]Handler),
+	}
+
+	s.srv = &http.Server{Handler: http.HandlerFunc(s.serve), Protocols: new(http.Protocols)}
+	s.srv.Protocols.SetUnencryptedHTTP2(true)
+	go s.srv.Serve(l)
+	return s, nil
+}
+
+// Handle sets the handler of a method, such as
+// `/containerd.services.events.v1.Events/Subscribe`.
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Close stops the server and ends the calls in progress.
+func (s *Server) Close() error {
+	err := s.srv.Close()
+	os.RemoveAll(s.dir)
+	return err
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	h, ok := s.handlers[r.URL.Path]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	if !ok {
+		writeStatus(w, &grpcconn.StatusError{Code: 12, Message: "unimplemented " + r.URL.Path})
+		return
+	}
+
+	req, err := readRequest(r.Body)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	// streams send their headers before the first message
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	writeStatus(w, h(&Call{
+		Method: r.URL.Path,
+		Header: r.Header,
+		ctx:    r.Context(),
+		req:    req,
+		w:      w,
+	}))
+}
+
+func readRequest(body io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(body, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func writeStatus(w http.ResponseWriter, err error) {
+	code, msg := 0, ""
+	if serr, ok := err.(*grpcconn.StatusError); ok {
+		code, msg = serr.Code, serr.Message
+	} else if err != nil {
+		code, msg = codeUnknown, err.Error()
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", url.PathEscape(msg))
+	}
+}
+
+// NotFound returns a NotFound status.
+func NotFound(format string, args ...interface{}) error {
+	return &grpcconn.StatusError{Code: grpcconn.CodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
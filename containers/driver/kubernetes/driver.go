@@ -0,0 +1,571 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/cri"
+	"github.com/danielkrainas/csense/containers/driver/factory"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	nodeNameEnv       = "NODE_NAME"
+)
+
+// Names of the networks containers are on, their pod's or the node's.
+const (
+	networkPod  = "pod"
+	networkHost = "host"
+)
+
+func init() {
+	factory.Register("kubernetes", &driverFactory{})
+}
+
+type driverFactory struct{}
+
+func stringParam(parameters map[string]interface{}, key string) string {
+	if v, ok := parameters[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
+
+func (factory *driverFactory) Create(parameters map[string]interface{}) (containers.Driver, error) {
+	server := stringParam(parameters, "api_server")
+	tokenFile := stringParam(parameters, "token_file")
+	caFile := stringParam(parameters, "ca_file")
+	if server == "" {
+		// running in a pod, use its service account
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("api_server is required outside of a cluster")
+		}
+
+		server = "https://" + net.JoinHostPort(host, port)
+		if tokenFile == "" {
+			tokenFile = serviceAccountDir + "/token"
+		}
+
+		if caFile == "" {
+			caFile = serviceAccountDir + "/ca.crt"
+		}
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca bundle: %v", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", caFile)
+		}
+	}
+
+	certFile, keyFile := stringParam(parameters, "cert_file"), stringParam(parameters, "key_file")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// scope to a node, the whole cluster when no node or selector is given
+	fieldSelector := stringParam(parameters, "field_selector")
+	node := stringParam(parameters, "node")
+	if node == "" && stringParam(parameters, "node_from_env") == "true" {
+		node = os.Getenv(nodeNameEnv)
+	}
+
+	if node != "" {
+		selector := "spec.nodeName=" + node
+		if fieldSelector != "" {
+			selector = fieldSelector + "," + selector
+		}
+
+		fieldSelector = selector
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &driver{
+		client: &client{
+			http:      &http.Client{Transport: transport},
+			server:    strings.TrimSuffix(server, "/"),
+			token:     stringParam(parameters, "token"),
+			tokenFile: tokenFile,
+		},
+
+		namespace:     stringParam(parameters, "namespace"),
+		fieldSelector: fieldSelector,
+		labelSelector: stringParam(parameters, "label_selector"),
+	}, nil
+}
+
+// client is a minimal Kubernetes API client for reading and watching pods.
+type client struct {
+	http      *http.Client
+	server    string
+	token     string
+	tokenFile string
+}
+
+// statusError is a non-OK response from the API server.
+type statusError struct {
+	Code    int
+	Message string
+}
+
+func (err *statusError) Error() string {
+	return fmt.Sprintf("kubernetes api returned %d: %s", err.Code, err.Message)
+}
+
+func (c *client) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token := c.token
+	if c.tokenFile != "" {
+		// service account tokens are rotated so read it every time
+		raw, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading token: %v", err)
+		}
+
+		token = strings.TrimSpace(string(raw))
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, &statusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	return resp, nil
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	resp, err := c.do(ctx, path, query)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+type containerState struct {
+	Waiting *struct {
+		Reason string `json:"reason"`
+	} `json:"waiting"`
+	Running *struct {
+		StartedAt string `json:"startedAt"`
+	} `json:"running"`
+	Terminated *struct {
+		ExitCode   int    `json:"exitCode"`
+		Reason     string `json:"reason"`
+		StartedAt  string `json:"startedAt"`
+		FinishedAt string `json:"finishedAt"`
+	} `json:"terminated"`
+}
+
+type containerStatus struct {
+	Name         string         `json:"name"`
+	ContainerID  string         `json:"containerID"`
+	Image        string         `json:"image"`
+	ImageID      string         `json:"imageID"`
+	RestartCount int            `json:"restartCount"`
+	State        containerState `json:"state"`
+	LastState    containerState `json:"lastState"`
+}
+
+type containerSpec struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+	Args    []string `json:"args"`
+	Ports   []struct {
+		ContainerPort int    `json:"containerPort"`
+		HostPort      int    `json:"hostPort"`
+		HostIP        string `json:"hostIP"`
+		Protocol      string `json:"protocol"`
+	} `json:"ports"`
+	Resources struct {
+		Limits   map[string]string `json:"limits"`
+		Requests map[string]string `json:"requests"`
+	} `json:"resources"`
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		InitContainers []*containerSpec `json:"initContainers"`
+		Containers     []*containerSpec `json:"containers"`
+		HostNetwork    bool             `json:"hostNetwork"`
+	} `json:"spec"`
+	Status struct {
+		PodIP  string `json:"podIP"`
+		PodIPs []struct {
+			IP string `json:"ip"`
+		} `json:"podIPs"`
+		InitContainerStatuses []*containerStatus `json:"initContainerStatuses"`
+		ContainerStatuses     []*containerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+func (p *pod) statuses() []*containerStatus {
+	return append(append([]*containerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+}
+
+func (p *pod) spec(name string) *containerSpec {
+	for _, c := range append(append([]*containerSpec{}, p.Spec.InitContainers...), p.Spec.Containers...) {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// network returns the network the pod's containers share, nil until the pod
+// has an address.
+func (p *pod) network() *v1.NetworkInfo {
+	n := &v1.NetworkInfo{Name: networkPod}
+	if p.Spec.HostNetwork {
+		n.Name = networkHost
+	}
+
+	for _, ip := range p.Status.PodIPs {
+		n.IPAddresses = append(n.IPAddresses, ip.IP)
+	}
+
+	if len(n.IPAddresses) == 0 && p.Status.PodIP != "" {
+		n.IPAddresses = []string{p.Status.PodIP}
+	}
+
+	if len(n.IPAddresses) == 0 {
+		return nil
+	}
+
+	return n
+}
+
+type podList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []*pod `json:"items"`
+}
+
+func containerName(p *pod, name string) string {
+	return p.Metadata.Namespace + "/" + p.Metadata.Name + "/" + name
+}
+
+// containerID strips the runtime prefix such as `containerd://`.
+func containerID(raw string) string {
+	if i := strings.Index(raw, "://"); i >= 0 {
+		return raw[i+3:]
+	}
+
+	return raw
+}
+
+func convertStatus(p *pod, s *containerStatus) *v1.ContainerInfo {
+	labels := make(map[string]string)
+	for k, v := range p.Metadata.Labels {
+		labels[k] = v
+	}
+
+	labels[cri.LabelPodName] = p.Metadata.Name
+	labels[cri.LabelPodNamespace] = p.Metadata.Namespace
+	labels[cri.LabelPodUID] = p.Metadata.UID
+	info := &v1.ContainerInfo{
+		ID:          containerID(s.ContainerID),
+		Name:        containerName(p, s.Name),
+		Labels:      labels,
+		Annotations: p.Metadata.Annotations,
+		State:       v1.StateUnknown,
+	}
+
+	containers.ParseImageReference(s.Image).Apply(info)
+	if info.ImageDigest == "" {
+		// the image ID is the reference the kubelet pulled, pinned by digest
+		info.ImageDigest = containers.ParseImageReference(s.ImageID).Digest
+	}
+
+	restarts := s.RestartCount
+	info.RestartCount = &restarts
+	if n := p.network(); n != nil {
+		info.Networks = []*v1.NetworkInfo{n}
+	}
+
+	if spec := p.spec(s.Name); spec != nil {
+		convertSpec(info, spec)
+	}
+
+	switch {
+	case s.State.Running != nil:
+		info.State = v1.StateRunning
+		info.StartedAt = parseTimeOrZero(s.State.Running.StartedAt)
+	case s.State.Terminated != nil:
+		info.State = v1.StateStopped
+		info.Reason = s.State.Terminated.Reason
+		info.ExitCode = &s.State.Terminated.ExitCode
+		info.StartedAt = parseTimeOrZero(s.State.Terminated.StartedAt)
+		info.FinishedAt = parseTimeOrZero(s.State.Terminated.FinishedAt)
+	case s.State.Waiting != nil:
+		// waiting to be restarted, the exit code is from the last run
+		info.State = v1.StateStopped
+		info.Reason = s.State.Waiting.Reason
+		if s.LastState.Terminated != nil {
+			info.ExitCode = &s.LastState.Terminated.ExitCode
+			info.StartedAt = parseTimeOrZero(s.LastState.Terminated.StartedAt)
+			info.FinishedAt = parseTimeOrZero(s.LastState.Terminated.FinishedAt)
+			if info.Reason == "" {
+				info.Reason = s.LastState.Terminated.Reason
+			}
+		}
+	}
+
+	return info
+}
+
+// convertSpec adds the command, ports and resource limits of the
+// container's spec. The command is left empty when the image's is used.
+func convertSpec(info *v1.ContainerInfo, spec *containerSpec) {
+	if len(spec.Command) > 0 {
+		info.Command = append(append([]string{}, spec.Command...), spec.Args...)
+	}
+
+	for _, port := range spec.Ports {
+		protocol := strings.ToLower(port.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		info.Ports = append(info.Ports, &v1.PortMapping{
+			ContainerPort: port.ContainerPort,
+			Protocol:      protocol,
+			HostIP:        port.HostIP,
+			HostPort:      port.HostPort,
+		})
+	}
+
+	limits := &v1.ResourceLimits{}
+	if cpu, ok := parseQuantity(spec.Resources.Limits["cpu"]); ok {
+		limits.CPUs = cpu
+	}
+
+	if memory, ok := parseQuantity(spec.Resources.Limits["memory"]); ok {
+		limits.MemoryLimit = int64(memory)
+	}
+
+	if cpu, ok := parseQuantity(spec.Resources.Requests["cpu"]); ok {
+		// the kubelet gives requests 1024 shares per cpu and at least 2
+		if limits.CPUShares = int64(cpu * 1024); limits.CPUShares < 2 {
+			limits.CPUShares = 2
+		}
+	}
+
+	if *limits != (v1.ResourceLimits{}) {
+		info.Resources = limits
+	}
+}
+
+// quantitySuffixes are the multipliers of Kubernetes quantity suffixes,
+// binary ones first so `Mi` isn't read as `M`.
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"m", 1e-3}, {"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// parseQuantity reads a Kubernetes resource quantity such as `500m` or
+// `128Mi`.
+func parseQuantity(raw string) (float64, bool) {
+	if raw == "" {
+		return 0, false
+	}
+
+	number, multiplier := raw, 1.0
+	for _, q := range quantitySuffixes {
+		if strings.HasSuffix(raw, q.suffix) {
+			number, multiplier = strings.TrimSuffix(raw, q.suffix), q.multiplier
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return v * multiplier, true
+}
+
+type driver struct {
+	client        *client
+	namespace     string
+	fieldSelector string
+	labelSelector string
+}
+
+func (d *driver) podsPath() string {
+	if d.namespace != "" {
+		return "/api/v1/namespaces/" + url.PathEscape(d.namespace) + "/pods"
+	}
+
+	return "/api/v1/pods"
+}
+
+func (d *driver) podsQuery() url.Values {
+	q := url.Values{}
+	if d.fieldSelector != "" {
+		q.Set("fieldSelector", d.fieldSelector)
+	}
+
+	if d.labelSelector != "" {
+		q.Set("labelSelector", d.labelSelector)
+	}
+
+	return q
+}
+
+func (d *driver) listPods(ctx context.Context) (*podList, error) {
+	list := &podList{}
+	if err := d.client.get(ctx, d.podsPath(), d.podsQuery(), list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	list, err := d.listPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventChannel(ctx, d, list, types), nil
+}
+
+func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	list, err := d.listPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.ContainerInfo, 0)
+	for _, p := range list.Items {
+		for _, s := range p.statuses() {
+			if s.State.Running != nil {
+				result = append(result, convertStatus(p, s))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// GetContainer finds a container by its `namespace/pod/container` name or
+// by its runtime ID.
+func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		list, err := d.listPods(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range list.Items {
+			for _, s := range p.statuses() {
+				if containerID(s.ContainerID) == name {
+					return convertStatus(p, s), nil
+				}
+			}
+		}
+
+		return nil, containers.ErrContainerNotFound
+	}
+
+	p := &pod{}
+	path := "/api/v1/namespaces/" + url.PathEscape(parts[0]) + "/pods/" + url.PathEscape(parts[1])
+	if err := d.client.get(ctx, path, nil, p); err != nil {
+		if serr, ok := err.(*statusError); ok && serr.Code == http.StatusNotFound {
+			return nil, containers.ErrContainerNotFound
+		}
+
+		return nil, err
+	}
+
+	for _, s := range p.statuses() {
+		if s.Name == parts[2] {
+			return convertStatus(p, s), nil
+		}
+	}
+
+	return nil, containers.ErrContainerNotFound
+}
+
+func parseTimeOrZero(raw string) int64 {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.Unix()
+	}
+
+	return 0
+}
+
+func parseTime(raw string) int64 {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.Unix()
+	}
+
+	return time.Now().Unix()
+}
@@ -0,0 +1,253 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+)
+
+type object map[string]interface{}
+
+func running(startedAt string) object {
+	return object{"running": object{"startedAt": startedAt}}
+}
+
+func terminated(code int, reason string, finishedAt string) object {
+	return object{"terminated": object{"exitCode": code, "reason": reason, "finishedAt": finishedAt}}
+}
+
+// podObject builds a pod the way the API server serves it, with one container
+// named `app`.
+func podObject(name string, version string, containerID string, state object, lastState object) object {
+	return object{
+		"metadata": object{
+			"name":            name,
+			"namespace":       "default",
+			"uid":             "uid-" + name,
+			"resourceVersion": version,
+			"labels":          object{"app": name},
+		},
+		"spec": object{
+			"containers": []object{{"name": "app", "ports": []object{{"containerPort": 80, "protocol": "TCP"}}}},
+		},
+		"status": object{
+			"podIP": "10.0.0.5",
+			"containerStatuses": []object{{
+				"name":         "app",
+				"containerID":  "containerd://" + containerID,
+				"image":        "nginx:1.2",
+				"imageID":      "docker.io/library/nginx@sha256:abc",
+				"restartCount": 0,
+				"state":        state,
+				"lastState":    lastState,
+			}},
+		},
+	}
+}
+
+// fakeAPIServer serves pods from the default namespace and streams the
+// watch events it is given.
+type fakeAPIServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	pods   map[string]object
+	events chan object
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	f := &fakeAPIServer{
+		pods:   make(map[string]object),
+		events: make(chan object, 10),
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/api/v1/pods" && r.URL.Query().Get("watch") == "true":
+			f.watch(w, r)
+		case r.URL.Path == "/api/v1/pods":
+			f.mu.Lock()
+			items := make([]object, 0, len(f.pods))
+			for _, p := range f.pods {
+				items = append(items, p)
+			}
+
+			f.mu.Unlock()
+			json.NewEncoder(w).Encode(object{"metadata": object{"resourceVersion": "1"}, "items": items})
+		case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/default/pods/"):
+			f.mu.Lock()
+			p, ok := f.pods[strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/default/pods/")]
+			f.mu.Unlock()
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(object{"kind": "Status", "code": http.StatusNotFound})
+				return
+			}
+
+			json.NewEncoder(w).Encode(p)
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return f
+}
+
+func (f *fakeAPIServer) watch(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case e := <-f.events:
+			json.NewEncoder(w).Encode(e)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (f *fakeAPIServer) set(p object) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pods[p["metadata"].(object)["name"].(string)] = p
+}
+
+func (f *fakeAPIServer) publish(t string, p object) {
+	f.events <- object{"type": t, "object": p}
+}
+
+func newTestDriver(t *testing.T, f *fakeAPIServer) containers.Driver {
+	d, err := (&driverFactory{}).Create(map[string]interface{}{
+		"api_server": f.URL,
+		"token":      "secret",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func nextEvent(t *testing.T, ch <-chan *v1.ContainerEvent) *v1.ContainerEvent {
+	select {
+	case e, ok := <-ch:
+		if !ok {
+			t.Fatal("events channel closed")
+		}
+
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	return nil
+}
+
+func TestGetContainers(t *testing.T) {
+	f := newFakeAPIServer(t)
+	defer f.Close()
+
+	f.set(podObject("web", "1", "c1", running("2023-11-14T22:13:20Z"), nil))
+	f.set(podObject("job", "1", "c2", terminated(0, "Completed", "2023-11-14T22:13:20Z"), nil))
+	conts, err := newTestDriver(t, f).GetContainers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conts) != 1 {
+		t.Fatalf("got %d containers, want 1", len(conts))
+	}
+
+	c := conts[0]
+	if c.ID != "c1" || c.Name != "default/web/app" || c.State != v1.StateRunning || c.StartedAt != 1700000000 {
+		t.Errorf("got id %q name %q state %s started at %d", c.ID, c.Name, c.State, c.StartedAt)
+	} else if c.ImageName != "nginx" || c.ImageTag != "1.2" || c.ImageDigest != "sha256:abc" {
+		t.Errorf("got image %q tag %q digest %q", c.ImageName, c.ImageTag, c.ImageDigest)
+	} else if c.Labels["app"] != "web" || len(c.Ports) != 1 || len(c.Networks) != 1 {
+		t.Errorf("got labels %v ports %v networks %v", c.Labels, c.Ports, c.Networks)
+	}
+}
+
+func TestGetContainer(t *testing.T) {
+	f := newFakeAPIServer(t)
+	defer f.Close()
+
+	f.set(podObject("web", "1", "c1", running("2023-11-14T22:13:20Z"), nil))
+	d := newTestDriver(t, f)
+	for _, name := range []string{"default/web/app", "c1"} {
+		c, err := d.GetContainer(context.Background(), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		} else if c.ID != "c1" || c.Name != "default/web/app" {
+			t.Errorf("%s: got id %q name %q", name, c.ID, c.Name)
+		}
+	}
+
+	for _, name := range []string{"default/gone/app", "default/web/sidecar", "c9"} {
+		if _, err := d.GetContainer(context.Background(), name); err != containers.ErrContainerNotFound {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestWatchEventsKeepDeletionsThroughResolver(t *testing.T) {
+	f := newFakeAPIServer(t)
+	defer f.Close()
+
+	f.set(podObject("web", "1", "c1", running("2023-11-14T22:13:20Z"), nil))
+	d := newTestDriver(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := d.WatchEvents(ctx, v1.EventContainerCreation, v1.EventContainerDeletion, v1.EventContainerOomKill)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := &containers.EventsContainerResolver{EventsChannel: ch, Driver: d}
+	defer resolved.Close()
+
+	// the container was OOM killed and restarted, looking it up now finds
+	// the new run
+	restarted := podObject("web", "2", "c2", running("2023-11-14T22:15:00Z"), terminated(137, reasonOOMKilled, "2023-11-14T22:14:20Z"))
+	f.set(restarted)
+	f.publish("MODIFIED", restarted)
+
+	events := resolved.GetChannel()
+	e := nextEvent(t, events)
+	if e.Type != v1.EventContainerDeletion {
+		t.Fatalf("got %s, want a deletion", e.Type)
+	}
+
+	c := e.Container
+	if c.ID != "c1" || c.State != v1.StateStopped || c.Reason != reasonOOMKilled || c.ExitCode == nil || *c.ExitCode != 137 {
+		t.Errorf("deletion: got id %q state %s reason %q exit code %v", c.ID, c.State, c.Reason, c.ExitCode)
+	} else if c.ImageName != "nginx" || c.Labels["app"] != "web" {
+		t.Errorf("deletion: got image %q labels %v", c.ImageName, c.Labels)
+	} else if e.Timestamp != 1700000060 {
+		t.Errorf("deletion: got timestamp %d", e.Timestamp)
+	}
+
+	if e = nextEvent(t, events); e.Type != v1.EventContainerOomKill {
+		t.Errorf("got %s, want an oom kill", e.Type)
+	}
+
+	if e = nextEvent(t, events); e.Type != v1.EventContainerCreation || e.Container.ID != "c2" || e.Container.State != v1.StateRunning {
+		t.Errorf("got %s for %q in state %s, want a creation of c2", e.Type, e.Container.ID, e.Container.State)
+	}
+}
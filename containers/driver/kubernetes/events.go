@@ -0,0 +1,272 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// reasonOOMKilled is the termination reason of containers killed for
+// running out of memory.
+const reasonOOMKilled = "OOMKilled"
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// seen is the last known status of a container.
+type seen struct {
+	id      string
+	running bool
+	info    *v1.ContainerInfo
+}
+
+type eventChannel struct {
+	cancel  context.CancelFunc
+	channel chan *v1.ContainerEvent
+	wanted  map[v1.ContainerEventType]bool
+	// containers by name within pods by namespace/name
+	pods map[string]map[string]*seen
+}
+
+// newEventChannel watches pods from the list's resource version and turns
+// container status transitions into events. The watch is resumed when the
+// API server ends it, and relisted when the resource version is too old.
+// The channel is closed when the API server can't be reached.
+func newEventChannel(ctx context.Context, d *driver, list *podList, types []v1.ContainerEventType) *eventChannel {
+	ctx, cancel := context.WithCancel(ctx)
+	ec := &eventChannel{
+		cancel:  cancel,
+		channel: make(chan *v1.ContainerEvent),
+		wanted:  make(map[v1.ContainerEventType]bool),
+		pods:    make(map[string]map[string]*seen),
+	}
+
+	for _, t := range types {
+		ec.wanted[t] = true
+	}
+
+	for _, p := range list.Items {
+		ec.update(p, false, nil)
+	}
+
+	go func() {
+		defer close(ec.channel)
+		version := list.Metadata.ResourceVersion
+		for ctx.Err() == nil {
+			var err error
+			version, err = ec.watch(ctx, d, version)
+			if err == nil {
+				// the API server ended the watch, resume it shortly
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+
+				continue
+			} else if serr, ok := err.(*statusError); !ok || serr.Code != http.StatusGone {
+				return
+			}
+
+			if version, err = ec.relist(ctx, d); err != nil {
+				return
+			}
+		}
+	}()
+
+	return ec
+}
+
+// watch streams pod changes until the API server ends the watch and returns
+// the last resource version seen.
+func (ec *eventChannel) watch(ctx context.Context, d *driver, version string) (string, error) {
+	q := d.podsQuery()
+	q.Set("watch", "true")
+	q.Set("resourceVersion", version)
+	q.Set("allowWatchBookmarks", "true")
+	resp, err := d.client.do(ctx, d.podsPath(), q)
+	if err != nil {
+		return version, err
+	}
+
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		e := &watchEvent{}
+		if err := dec.Decode(e); err != nil {
+			return version, nil
+		}
+
+		if e.Type == "ERROR" {
+			serr := &statusError{}
+			status := &struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}{}
+
+			if err := json.Unmarshal(e.Object, status); err == nil {
+				serr.Code, serr.Message = status.Code, status.Message
+			}
+
+			return version, serr
+		}
+
+		p := &pod{}
+		if err := json.Unmarshal(e.Object, p); err != nil {
+			continue
+		}
+
+		version = p.Metadata.ResourceVersion
+		switch e.Type {
+		case "ADDED", "MODIFIED":
+			if !ec.update(p, false, ctx.Done()) {
+				return version, ctx.Err()
+			}
+
+		case "DELETED":
+			if !ec.update(p, true, ctx.Done()) {
+				return version, ctx.Err()
+			}
+		}
+	}
+}
+
+// relist syncs with the current pods, emitting events for anything missed
+// while the watch was behind.
+func (ec *eventChannel) relist(ctx context.Context, d *driver) (string, error) {
+	list, err := d.listPods(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	current := make(map[string]bool)
+	for _, p := range list.Items {
+		current[podKey(p)] = true
+		if !ec.update(p, false, ctx.Done()) {
+			return "", ctx.Err()
+		}
+	}
+
+	for key, known := range ec.pods {
+		if current[key] {
+			continue
+		}
+
+		for _, s := range known {
+			if s.running && !ec.emit(v1.EventContainerDeletion, stopped(s.info), time.Now().Unix(), ctx.Done()) {
+				return "", ctx.Err()
+			}
+		}
+
+		delete(ec.pods, key)
+	}
+
+	return list.Metadata.ResourceVersion, nil
+}
+
+func podKey(p *pod) string {
+	return p.Metadata.Namespace + "/" + p.Metadata.Name
+}
+
+// stopped returns a copy of a running container's info for its deletion
+// event.
+func stopped(info *v1.ContainerInfo) *v1.ContainerInfo {
+	dupe := *info
+	dupe.State = v1.StateStopped
+	return &dupe
+}
+
+// update records the pod's container statuses and emits events for the ones
+// that changed. Without a done channel nothing is emitted. It returns false
+// when done is closed before an event could be sent.
+func (ec *eventChannel) update(p *pod, deleted bool, done <-chan struct{}) bool {
+	key := podKey(p)
+	known := ec.pods[key]
+	next := make(map[string]*seen)
+	for _, s := range p.statuses() {
+		info := convertStatus(p, s)
+		cur := &seen{
+			id:      info.ID,
+			running: s.State.Running != nil && !deleted,
+			info:    info,
+		}
+
+		next[s.Name] = cur
+		prev := known[s.Name]
+		if done == nil {
+			continue
+		}
+
+		if prev != nil && prev.running && (!cur.running || prev.id != cur.id) {
+			// restarted and waiting containers report their last run in
+			// lastState
+			term := s.LastState.Terminated
+			if !cur.running && s.State.Terminated != nil {
+				term = s.State.Terminated
+			}
+
+			gone := stopped(prev.info)
+			ts := time.Now().Unix()
+			if term != nil {
+				gone.ExitCode = &term.ExitCode
+				gone.Reason = term.Reason
+				ts = parseTime(term.FinishedAt)
+			}
+
+			if !cur.running && s.State.Waiting != nil && s.State.Waiting.Reason != "" {
+				// such as CrashLoopBackOff
+				gone.Reason = s.State.Waiting.Reason
+			} else if deleted && gone.Reason == "" {
+				gone.Reason = "PodDeleted"
+			}
+
+			if !ec.emit(v1.EventContainerDeletion, gone, ts, done) {
+				return false
+			}
+
+			if term != nil && term.Reason == reasonOOMKilled && !ec.emit(v1.EventContainerOomKill, gone, ts, done) {
+				return false
+			}
+		}
+
+		if cur.running && (prev == nil || !prev.running || prev.id != cur.id) {
+			if !ec.emit(v1.EventContainerCreation, info, parseTime(s.State.Running.StartedAt), done) {
+				return false
+			}
+		}
+	}
+
+	if deleted {
+		delete(ec.pods, key)
+	} else {
+		ec.pods[key] = next
+	}
+
+	return true
+}
+
+func (ec *eventChannel) emit(t v1.ContainerEventType, c *v1.ContainerInfo, ts int64, done <-chan struct{}) bool {
+	if !ec.wanted[t] {
+		return true
+	}
+
+	select {
+	case ec.channel <- &v1.ContainerEvent{Type: t, Container: c, Timestamp: ts}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	ec.cancel()
+	return nil
+}
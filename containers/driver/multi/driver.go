@@ -0,0 +1,167 @@
+// Package multi combines several containers drivers, for hosts running more
+// than one container runtime. Containers are tagged with the runtime that
+// reported them.
+package multi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/gobag/context"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+)
+
+// Runtime is a driver and the name its containers are tagged with. Names
+// are unique, several runtimes can use the same type of driver.
+type Runtime struct {
+	Name   string
+	Driver containers.Driver
+}
+
+type driver struct {
+	runtimes []*Runtime
+	mu       sync.Mutex
+	// containers by name, tagged with the runtime that reported them
+	tracked map[string]*v1.ContainerInfo
+}
+
+// New returns a driver combining the runtimes. Runtimes are asked in order
+// for containers they haven't reported yet.
+func New(runtimes ...*Runtime) (containers.Driver, error) {
+	names := make(map[string]bool)
+	for _, r := range runtimes {
+		if r.Name == "" {
+			return nil, fmt.Errorf("runtime name missing")
+		} else if names[r.Name] {
+			return nil, fmt.Errorf("runtime %q used more than once", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return &driver{
+		runtimes: runtimes,
+		tracked:  make(map[string]*v1.ContainerInfo),
+	}, nil
+}
+
+// tag sets the container's runtime and tracks it.
+func (d *driver) tag(r *Runtime, c *v1.ContainerInfo) *v1.ContainerInfo {
+	dupe := *c
+	dupe.Runtime = r.Name
+	d.mu.Lock()
+	d.tracked[c.Name] = &dupe
+	d.mu.Unlock()
+	return &dupe
+}
+
+// forget drops a deleted container.
+func (d *driver) forget(name string) {
+	d.mu.Lock()
+	delete(d.tracked, name)
+	d.mu.Unlock()
+}
+
+// resync replaces the runtime's tracked containers with the current ones
+// and returns the events missed in between.
+func (d *driver) resync(r *Runtime, current []*v1.ContainerInfo) []*v1.ContainerEvent {
+	tagged := make([]*v1.ContainerInfo, 0, len(current))
+	for _, c := range current {
+		dupe := *c
+		dupe.Runtime = r.Name
+		tagged = append(tagged, &dupe)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	index := make(map[string]*v1.ContainerInfo)
+	for name, c := range d.tracked {
+		if c.Runtime == r.Name {
+			index[name] = c
+			delete(d.tracked, name)
+		}
+	}
+
+	for _, c := range tagged {
+		d.tracked[c.Name] = c
+	}
+
+	return containers.Diff(index, tagged, time.Now().Unix())
+}
+
+// WatchEvents merges the runtimes' events. A runtime that can't be watched
+// is retried in the background, it's an error only when none can be.
+func (d *driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	sources := make([]containers.EventsChannel, len(d.runtimes))
+	failed := make([]string, 0)
+	for i, r := range d.runtimes {
+		ch, err := r.Driver.WatchEvents(ctx, types...)
+		if err != nil {
+			acontext.GetLogger(ctx).Errorf("error watching %s events: %v", r.Name, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Name, err))
+			continue
+		}
+
+		sources[i] = ch
+	}
+
+	if len(failed) == len(d.runtimes) {
+		return nil, fmt.Errorf("%s", strings.Join(failed, ", "))
+	}
+
+	return newEventChannel(ctx, d, types, sources), nil
+}
+
+func (d *driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	result := make([]*v1.ContainerInfo, 0)
+	tracked := make(map[string]*v1.ContainerInfo)
+	for _, r := range d.runtimes {
+		conts, err := r.Driver.GetContainers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Name, err)
+		}
+
+		for _, c := range conts {
+			dupe := *c
+			dupe.Runtime = r.Name
+			result = append(result, &dupe)
+			tracked[c.Name] = &dupe
+		}
+	}
+
+	// every runtime reported all of its containers, the ones that are gone
+	// aren't tracked anymore
+	d.mu.Lock()
+	d.tracked = tracked
+	d.mu.Unlock()
+	return result, nil
+}
+
+// GetContainer asks the runtime that reported the container, or every
+// runtime in order when none has.
+func (d *driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	d.mu.Lock()
+	owner, ok := d.tracked[name]
+	d.mu.Unlock()
+	for _, r := range d.runtimes {
+		if ok && r.Name != owner.Runtime {
+			continue
+		}
+
+		c, err := r.Driver.GetContainer(ctx, name)
+		if err == containers.ErrContainerNotFound {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Name, err)
+		}
+
+		return d.tag(r, c), nil
+	}
+
+	return nil, containers.ErrContainerNotFound
+}
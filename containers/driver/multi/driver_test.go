@@ -0,0 +1,232 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+)
+
+type fakeChannel struct {
+	ch      chan *v1.ContainerEvent
+	closing sync.Once
+}
+
+func (f *fakeChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return f.ch
+}
+
+func (f *fakeChannel) Close() error {
+	f.closing.Do(func() { close(f.ch) })
+	return nil
+}
+
+type fakeDriver struct {
+	mu      sync.Mutex
+	events  *fakeChannel
+	conts   []*v1.ContainerInfo
+	watched chan struct{}
+	err     error
+}
+
+func newFakeDriver(conts ...*v1.ContainerInfo) *fakeDriver {
+	return &fakeDriver{
+		conts:   conts,
+		watched: make(chan struct{}, 10),
+	}
+}
+
+func (f *fakeDriver) channel() *fakeChannel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events
+}
+
+func (f *fakeDriver) setContainers(conts ...*v1.ContainerInfo) {
+	f.mu.Lock()
+	f.conts = conts
+	f.mu.Unlock()
+}
+
+func (f *fakeDriver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.events = &fakeChannel{ch: make(chan *v1.ContainerEvent)}
+	f.watched <- struct{}{}
+	return f.events, nil
+}
+
+func (f *fakeDriver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conts, nil
+}
+
+func (f *fakeDriver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conts {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+
+	return nil, containers.ErrContainerNotFound
+}
+
+func newTestDriver(t *testing.T, runtimes ...*Runtime) *driver {
+	d, err := New(runtimes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d.(*driver)
+}
+
+func receive(t *testing.T, ch containers.EventsChannel) *v1.ContainerEvent {
+	select {
+	case e, ok := <-ch.GetChannel():
+		if !ok {
+			t.Fatal("merged channel closed")
+		}
+
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	return nil
+}
+
+func TestNewRequiresUniqueNames(t *testing.T) {
+	docker := newFakeDriver()
+	if _, err := New(&Runtime{Name: "docker", Driver: docker}, &Runtime{Name: "docker", Driver: newFakeDriver()}); err == nil {
+		t.Error("runtimes with the same name combined")
+	}
+
+	if _, err := New(&Runtime{Driver: docker}); err == nil {
+		t.Error("runtime without a name combined")
+	}
+}
+
+func TestEventsReconnectEndedRuntime(t *testing.T) {
+	docker, cri := newFakeDriver(&v1.ContainerInfo{Name: "web", ID: "1"}), newFakeDriver()
+	d := newTestDriver(t, &Runtime{Name: "docker", Driver: docker}, &Runtime{Name: "cri", Driver: cri})
+	if _, err := d.GetContainers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := d.WatchEvents(context.Background(), v1.EventContainerCreation, v1.EventContainerDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+	<-docker.watched
+	docker.setContainers(&v1.ContainerInfo{Name: "cache", ID: "2"})
+	docker.channel().Close()
+
+	// the other runtime's events keep flowing
+	go func() {
+		cri.channel().ch <- &v1.ContainerEvent{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "db"}}
+	}()
+
+	if e := receive(t, ch); e.Container.Name != "db" || e.Container.Runtime != "cri" {
+		t.Errorf("got %s of %q on %q", e.Type, e.Container.Name, e.Container.Runtime)
+	}
+
+	// the ended runtime is watched again and its missed events are sent
+	<-docker.watched
+	got := make(map[v1.ContainerEventType]string)
+	for i := 0; i < 2; i++ {
+		e := receive(t, ch)
+		if e.Container.Runtime != "docker" {
+			t.Errorf("got runtime %q", e.Container.Runtime)
+		}
+
+		got[e.Type] = e.Container.Name
+	}
+
+	if got[v1.EventContainerDeletion] != "web" || got[v1.EventContainerCreation] != "cache" {
+		t.Errorf("got missed events %v", got)
+	}
+
+	go func() {
+		docker.channel().ch <- &v1.ContainerEvent{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "api"}}
+	}()
+
+	if e := receive(t, ch); e.Container.Name != "api" || e.Container.Runtime != "docker" {
+		t.Errorf("got %q on %q after watching again", e.Container.Name, e.Container.Runtime)
+	}
+}
+
+func TestEventsWithoutOneRuntime(t *testing.T) {
+	docker, cri := newFakeDriver(), newFakeDriver()
+	docker.err = errors.New("connection refused")
+	d := newTestDriver(t, &Runtime{Name: "docker", Driver: docker}, &Runtime{Name: "cri", Driver: cri})
+	ch, err := d.WatchEvents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+	go func() {
+		cri.channel().ch <- &v1.ContainerEvent{Type: v1.EventContainerCreation, Container: &v1.ContainerInfo{Name: "db"}}
+	}()
+
+	if e := receive(t, ch); e.Container.Runtime != "cri" {
+		t.Errorf("got runtime %q", e.Container.Runtime)
+	}
+
+	cri.mu.Lock()
+	cri.err = errors.New("connection refused")
+	cri.mu.Unlock()
+	if _, err := d.WatchEvents(context.Background()); err == nil {
+		t.Error("watched without any runtime")
+	}
+}
+
+func TestDeletedContainersForgotten(t *testing.T) {
+	docker := newFakeDriver(&v1.ContainerInfo{Name: "web"}, &v1.ContainerInfo{Name: "db"})
+	d := newTestDriver(t, &Runtime{Name: "docker", Driver: docker})
+	if _, err := d.GetContainers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := d.WatchEvents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ch.Close()
+	go func() {
+		docker.channel().ch <- &v1.ContainerEvent{Type: v1.EventContainerDeletion, Container: &v1.ContainerInfo{Name: "db"}}
+	}()
+
+	receive(t, ch)
+	d.mu.Lock()
+	_, ok := d.tracked["db"]
+	d.mu.Unlock()
+	if ok {
+		t.Error("deleted container still tracked")
+	}
+
+	docker.setContainers(&v1.ContainerInfo{Name: "web"}, &v1.ContainerInfo{Name: "cache"})
+	if _, err := d.GetContainers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tracked) != 2 || d.tracked["web"].Runtime != "docker" || d.tracked["cache"].Runtime != "docker" {
+		t.Errorf("got tracked containers %v", d.tracked)
+	}
+}
@@ -0,0 +1,230 @@
+package multi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/gobag/context"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+)
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+type eventChannel struct {
+	ctx     context.Context
+	driver  *driver
+	types   []v1.ContainerEventType
+	mu      sync.Mutex
+	sources []containers.EventsChannel
+	channel chan *v1.ContainerEvent
+	done    chan struct{}
+	closing sync.Once
+}
+
+// newEventChannel merges the runtimes' events. When a runtime's channel is
+// closed, or it couldn't be watched, the others keep running and it's
+// watched again with backoff. The containers it created and deleted in the
+// meantime are sent before its new events.
+func newEventChannel(ctx context.Context, d *driver, types []v1.ContainerEventType, sources []containers.EventsChannel) *eventChannel {
+	ec := &eventChannel{
+		ctx:     ctx,
+		driver:  d,
+		types:   types,
+		sources: sources,
+		channel: make(chan *v1.ContainerEvent),
+		done:    make(chan struct{}),
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, src containers.EventsChannel) {
+			defer wg.Done()
+			ec.run(i, src)
+		}(i, src)
+	}
+
+	go func() {
+		wg.Wait()
+		close(ec.channel)
+	}()
+
+	return ec
+}
+
+// run forwards the runtime's events until the channel is closed.
+func (ec *eventChannel) run(i int, src containers.EventsChannel) {
+	r := ec.driver.runtimes[i]
+	log := acontext.GetLogger(ec.ctx)
+	backoff := minReconnectBackoff
+	var missed []*v1.ContainerEvent
+	for {
+		if src != nil {
+			opened := time.Now()
+			if !ec.forward(r, src, missed) {
+				return
+			}
+
+			log.Warnf("%s event stream ended", r.Name)
+			src.Close()
+			if !ec.replace(i, nil) {
+				return
+			}
+
+			if time.Since(opened) > maxReconnectBackoff {
+				backoff = minReconnectBackoff
+			}
+		}
+
+		log.Infof("watching %s events again in %v", r.Name, backoff)
+		select {
+		case <-ec.done:
+			return
+		case <-ec.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+
+		var err error
+		src, missed, err = ec.reconnect(r)
+		if err != nil {
+			log.Errorf("error watching %s events: %v", r.Name, err)
+			continue
+		}
+
+		if !ec.replace(i, src) {
+			return
+		}
+	}
+}
+
+// reconnect watches the runtime's events again and returns the events
+// missed since its last ones.
+func (ec *eventChannel) reconnect(r *Runtime) (containers.EventsChannel, []*v1.ContainerEvent, error) {
+	src, err := r.Driver.WatchEvents(ec.ctx, ec.types...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := r.Driver.GetContainers(ec.ctx)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+
+	missed := make([]*v1.ContainerEvent, 0)
+	for _, e := range ec.driver.resync(r, current) {
+		if ec.wants(e.Type) {
+			missed = append(missed, e)
+		}
+	}
+
+	return src, missed, nil
+}
+
+func (ec *eventChannel) wants(t v1.ContainerEventType) bool {
+	if len(ec.types) == 0 {
+		return true
+	}
+
+	for _, want := range ec.types {
+		if want == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replace keeps the runtime's current channel to close, false when the
+// merged channel was closed already.
+func (ec *eventChannel) replace(i int, src containers.EventsChannel) bool {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	select {
+	case <-ec.done:
+		if src != nil {
+			src.Close()
+		}
+
+		return false
+	default:
+	}
+
+	ec.sources[i] = src
+	return true
+}
+
+// forward sends the missed events and then the runtime's events, false when
+// the merged channel was closed.
+func (ec *eventChannel) forward(r *Runtime, src containers.EventsChannel, missed []*v1.ContainerEvent) bool {
+	for _, e := range missed {
+		if !ec.send(e) {
+			return false
+		}
+	}
+
+	for {
+		var e *v1.ContainerEvent
+		var ok bool
+		select {
+		case e, ok = <-src.GetChannel():
+		case <-ec.done:
+			return false
+		}
+
+		if !ok {
+			return true
+		}
+
+		event := *e
+		if e.Container != nil {
+			event.Container = ec.driver.tag(r, e.Container)
+			if e.Type == v1.EventContainerDeletion {
+				ec.driver.forget(e.Container.Name)
+			}
+		}
+
+		if !ec.send(&event) {
+			return false
+		}
+	}
+}
+
+func (ec *eventChannel) send(e *v1.ContainerEvent) bool {
+	select {
+	case ec.channel <- e:
+		return true
+	case <-ec.done:
+		return false
+	}
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	ec.closing.Do(func() {
+		ec.mu.Lock()
+		defer ec.mu.Unlock()
+		close(ec.done)
+		for _, src := range ec.sources {
+			if src != nil {
+				src.Close()
+			}
+		}
+	})
+
+	return nil
+}
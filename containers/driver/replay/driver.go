@@ -0,0 +1,225 @@
+// Package replay is a containers driver that plays back scripted events, for
+// running csense without a container runtime in tests, demos and hook
+// development. Scripts are loaded from a file by the driver factory or the
+// driver is created with New and fed events with Emit.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
+	"github.com/danielkrainas/csense/containers/driver/factory"
+)
+
+type Timing string
+
+const (
+	// TimingInstant plays events back to back.
+	TimingInstant Timing = "instant"
+	// TimingRealtime waits between events as long as their timestamps are
+	// apart.
+	TimingRealtime Timing = "realtime"
+	// TimingAccelerated waits like TimingRealtime divided by the speed.
+	TimingAccelerated Timing = "accelerated"
+)
+
+const defaultSpeed = 10
+
+func init() {
+	factory.Register("replay", &driverFactory{})
+}
+
+type driverFactory struct{}
+
+func (factory *driverFactory) Create(parameters map[string]interface{}) (containers.Driver, error) {
+	path, ok := parameters["file"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("replay script file is required")
+	}
+
+	events, err := LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading replay script: %v", err)
+	}
+
+	d := New()
+	d.script = events
+	d.timing = TimingInstant
+	if raw, ok := parameters["timing"].(string); ok && raw != "" {
+		d.timing = Timing(raw)
+	}
+
+	switch d.timing {
+	case TimingInstant, TimingRealtime, TimingAccelerated:
+	default:
+		return nil, fmt.Errorf("unknown replay timing %q", d.timing)
+	}
+
+	d.speed = defaultSpeed
+	if raw, ok := parameters["speed"]; ok {
+		if d.speed, err = strconv.ParseFloat(fmt.Sprint(raw), 64); err != nil || d.speed <= 0 {
+			return nil, fmt.Errorf("invalid replay speed %v", raw)
+		}
+	}
+
+	d.closeWhenDone, _ = parameters["close_when_done"].(bool)
+	return d, nil
+}
+
+// Driver keeps the containers created by the events played so far.
+type Driver struct {
+	mu            sync.Mutex
+	containers    map[string]*v1.ContainerInfo
+	watchers      []*eventChannel
+	script        []*v1.ContainerEvent
+	timing        Timing
+	speed         float64
+	closeWhenDone bool
+	play          sync.Once
+}
+
+// New returns a driver without a script, events are sent with Emit.
+func New() *Driver {
+	return &Driver{
+		containers: make(map[string]*v1.ContainerInfo),
+	}
+}
+
+// Play emits the events in order, waiting between them as timing says.
+func (d *Driver) Play(ctx context.Context, events []*v1.ContainerEvent, timing Timing, speed float64) error {
+	var last int64
+	for _, e := range events {
+		if timing != TimingInstant && last != 0 && e.Timestamp > last {
+			wait := time.Duration(e.Timestamp-last) * time.Second
+			if timing == TimingAccelerated {
+				wait = time.Duration(float64(wait) / speed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		if e.Timestamp != 0 {
+			last = e.Timestamp
+		}
+
+		if err := d.Emit(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Emit sends the event to every watcher interested in its type and records
+// the container's new state. It blocks until the watchers received it.
+func (d *Driver) Emit(ctx context.Context, e *v1.ContainerEvent) error {
+	if e.Container == nil {
+		return fmt.Errorf("event has no container")
+	}
+
+	if e.Timestamp == 0 {
+		dupe := *e
+		dupe.Timestamp = time.Now().Unix()
+		e = &dupe
+	}
+
+	// like a runtime, the container changes before the event is seen
+	d.mu.Lock()
+	c := *e.Container
+	switch e.Type {
+	case v1.EventContainerCreation, v1.EventContainerExisted:
+		c.State = v1.StateRunning
+		d.containers[c.Name] = &c
+	case v1.EventContainerDeletion:
+		c.State = v1.StateStopped
+		d.containers[c.Name] = &c
+	}
+
+	watchers := append([]*eventChannel{}, d.watchers...)
+	d.mu.Unlock()
+	for _, w := range watchers {
+		if err := w.send(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close ends all event streams.
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	watchers := d.watchers
+	d.watchers = nil
+	d.mu.Unlock()
+	for _, w := range watchers {
+		w.Close()
+	}
+
+	return nil
+}
+
+func (d *Driver) WatchEvents(ctx context.Context, types ...v1.ContainerEventType) (containers.EventsChannel, error) {
+	w := newEventChannel(d, types)
+	d.mu.Lock()
+	d.watchers = append(d.watchers, w)
+	d.mu.Unlock()
+	if d.script != nil {
+		d.play.Do(func() {
+			go func() {
+				d.Play(context.Background(), d.script, d.timing, d.speed)
+				if d.closeWhenDone {
+					d.Close()
+				}
+			}()
+		})
+	}
+
+	return w, nil
+}
+
+func (d *Driver) GetContainers(ctx context.Context) ([]*v1.ContainerInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]*v1.ContainerInfo, 0, len(d.containers))
+	for _, c := range d.containers {
+		if c.State == v1.StateRunning {
+			dupe := *c
+			result = append(result, &dupe)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Driver) GetContainer(ctx context.Context, name string) (*v1.ContainerInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.containers[name]
+	if !ok {
+		return nil, containers.ErrContainerNotFound
+	}
+
+	dupe := *c
+	return &dupe, nil
+}
+
+func (d *Driver) remove(w *eventChannel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, x := range d.watchers {
+		if x == w {
+			d.watchers = append(d.watchers[:i], d.watchers[i+1:]...)
+			return
+		}
+	}
+}
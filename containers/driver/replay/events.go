@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"context"
+	"sync"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+type eventChannel struct {
+	driver  *Driver
+	wanted  map[v1.ContainerEventType]bool
+	channel chan *v1.ContainerEvent
+	done    chan struct{}
+	// held by senders so the channel isn't closed under them
+	mu      sync.RWMutex
+	closed  bool
+	closing sync.Once
+}
+
+func newEventChannel(d *Driver, types []v1.ContainerEventType) *eventChannel {
+	ec := &eventChannel{
+		driver:  d,
+		wanted:  make(map[v1.ContainerEventType]bool),
+		channel: make(chan *v1.ContainerEvent),
+		done:    make(chan struct{}),
+	}
+
+	for _, t := range types {
+		ec.wanted[t] = true
+	}
+
+	return ec
+}
+
+// send delivers the event unless the watcher doesn't want it or is closed.
+func (ec *eventChannel) send(ctx context.Context, e *v1.ContainerEvent) error {
+	if !ec.wanted[e.Type] {
+		return nil
+	}
+
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	if ec.closed {
+		return nil
+	}
+
+	dupe := *e.Container
+	event := *e
+	event.Container = &dupe
+	select {
+	case ec.channel <- &event:
+	case <-ec.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (ec *eventChannel) GetChannel() <-chan *v1.ContainerEvent {
+	return ec.channel
+}
+
+func (ec *eventChannel) Close() error {
+	ec.closing.Do(func() {
+		ec.driver.remove(ec)
+		close(ec.done)
+		ec.mu.Lock()
+		ec.closed = true
+		close(ec.channel)
+		ec.mu.Unlock()
+	})
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// Script formats.
+const (
+	FormatJSONL = "jsonl"
+	FormatYAML  = "yaml"
+)
+
+// LoadFile reads the events of a script file, the format is taken from the
+// file extension and defaults to JSONL.
+func LoadFile(path string) ([]*v1.ContainerEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	format := FormatJSONL
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		format = FormatYAML
+	}
+
+	return ReadEvents(f, format)
+}
+
+// ReadEvents reads a script of events. JSONL scripts have one event per
+// line, YAML scripts are a list of events. Both use the event's JSON field
+// names.
+func ReadEvents(r io.Reader, format string) ([]*v1.ContainerEvent, error) {
+	switch format {
+	case FormatJSONL:
+		return readJSONL(r)
+	case FormatYAML:
+		return readYAML(r)
+	}
+
+	return nil, fmt.Errorf("unknown script format %q", format)
+}
+
+func readJSONL(r io.Reader) ([]*v1.ContainerEvent, error) {
+	events := make([]*v1.ContainerEvent, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 || raw[0] == '#' {
+			continue
+		}
+
+		e := &v1.ContainerEvent{}
+		if err := json.Unmarshal(raw, e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func readYAML(r io.Reader) ([]*v1.ContainerEvent, error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+
+	// round trip through json so both formats share the field names
+	raw, err := json.Marshal(jsonCompatible(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*v1.ContainerEvent, 0)
+	if err := json.Unmarshal(raw, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// jsonCompatible converts the map[interface{}]interface{} values yaml
+// produces into map[string]interface{}.
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(x)
+		}
+
+		return m
+	case []interface{}:
+		for i, x := range t {
+			t[i] = jsonCompatible(x)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,102 @@
+package containers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// DefaultRegistry is the registry of images referenced without one.
+const DefaultRegistry = "docker.io"
+
+// officialRepositoryPrefix is the namespace of Docker Hub's official images,
+// `nginx` is `docker.io/library/nginx`.
+const officialRepositoryPrefix = "library/"
+
+// imageIDPattern matches bare image IDs such as `sha256:3f2a...`, only the
+// known digest algorithms with their full length so a tag of hex digits
+// isn't taken for an ID.
+var imageIDPattern = regexp.MustCompile(`^(sha256:[a-f0-9]{64}|sha384:[a-f0-9]{96}|sha512:[a-f0-9]{128})$`)
+
+// ImageReference is an image reference split into its parts, such as
+// `registry.local:5000/team/app:1.2@sha256:...`.
+type ImageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses an image reference the way the Docker CLI
+// does. The first path component is the registry when it has a `.` or `:`
+// or is `localhost`, references without one are Docker Hub's, and the tag
+// defaults to `latest` unless the reference is pinned by digest. Bare image
+// IDs aren't references and leave every part empty, runtime prefixes like
+// `docker-pullable://` are dropped.
+func ParseImageReference(ref string) *ImageReference {
+	r := &ImageReference{}
+	if i := strings.Index(ref, "://"); i >= 0 {
+		ref = ref[i+3:]
+	}
+
+	if imageIDPattern.MatchString(ref) {
+		return r
+	}
+
+	if i := strings.Index(ref, "@"); i >= 0 {
+		r.Digest = ref[i+1:]
+		ref = ref[:i]
+	}
+
+	if ref == "" {
+		return r
+	}
+
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		r.Tag = ref[i+1:]
+		ref = ref[:i]
+	}
+
+	if i := strings.Index(ref, "/"); i >= 0 && (strings.ContainsAny(ref[:i], ".:") || ref[:i] == "localhost") {
+		r.Registry = ref[:i]
+		ref = ref[i+1:]
+	}
+
+	switch r.Registry {
+	case "", "index.docker.io", "registry-1.docker.io":
+		r.Registry = DefaultRegistry
+	}
+
+	if r.Registry == DefaultRegistry && !strings.Contains(ref, "/") {
+		ref = officialRepositoryPrefix + ref
+	}
+
+	r.Repository = ref
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	return r
+}
+
+// Name returns the short name the image is usually referred to by, without
+// Docker Hub's registry and `library/` namespace.
+func (r *ImageReference) Name() string {
+	if r.Repository == "" {
+		return ""
+	} else if r.Registry != DefaultRegistry {
+		return r.Registry + "/" + r.Repository
+	}
+
+	return strings.TrimPrefix(r.Repository, officialRepositoryPrefix)
+}
+
+// Apply sets the container's image fields from the reference.
+func (r *ImageReference) Apply(c *v1.ContainerInfo) {
+	c.ImageName = r.Name()
+	c.ImageRegistry = r.Registry
+	c.ImageRepository = r.Repository
+	c.ImageTag = r.Tag
+	c.ImageDigest = r.Digest
+}
@@ -0,0 +1,24 @@
+package containers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	id := "sha256:" + strings.Repeat("ab", 32)
+	sha := strings.Repeat("0123456789", 4)
+	for ref, want := range map[string]ImageReference{
+		"nginx":                                  {Registry: DefaultRegistry, Repository: "library/nginx", Tag: "latest"},
+		"nginx:1.2":                              {Registry: DefaultRegistry, Repository: "library/nginx", Tag: "1.2"},
+		"registry.local:5000/team/app:1.2@" + id: {Registry: "registry.local:5000", Repository: "team/app", Tag: "1.2", Digest: id},
+		"app:" + sha:                             {Registry: DefaultRegistry, Repository: "library/app", Tag: sha},
+		"app:" + strings.Repeat("ab", 32):        {Registry: DefaultRegistry, Repository: "library/app", Tag: strings.Repeat("ab", 32)},
+		id:                                       {},
+	} {
+		got := ParseImageReference(ref)
+		if *got != want {
+			t.Errorf("%s: got %+v, want %+v", ref, *got, want)
+		}
+	}
+}
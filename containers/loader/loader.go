@@ -2,6 +2,7 @@ package containersloader
 
 import (
 	"context"
+	"fmt"
 
 	cfg "github.com/danielkrainas/gobag/configuration"
 	"github.com/danielkrainas/gobag/context"
@@ -9,22 +10,46 @@ import (
 	"github.com/danielkrainas/csense/configuration"
 	"github.com/danielkrainas/csense/containers"
 	"github.com/danielkrainas/csense/containers/driver/factory"
+	"github.com/danielkrainas/csense/containers/driver/multi"
 )
 
+// FromConfig creates the configured containers driver, combining them with
+// the multi driver when there are several.
 func FromConfig(config *configuration.Config) (containers.Driver, error) {
-	params := config.Containers.Parameters()
-	if params == nil {
-		params = make(cfg.Parameters)
+	runtimes := make([]*multi.Runtime, 0, len(config.Containers))
+	names := config.Containers.Names()
+	for i, c := range config.Containers {
+		// the runtime's name isn't one of the driver's parameters
+		params := make(cfg.Parameters)
+		for k, v := range c.Parameters() {
+			if k != "name" {
+				params[k] = v
+			}
+		}
+
+		d, err := factory.Create(c.Type(), params)
+		if err != nil {
+			return nil, err
+		}
+
+		runtimes = append(runtimes, &multi.Runtime{Name: names[i], Driver: d})
 	}
 
-	d, err := factory.Create(config.Containers.Type(), params)
-	if err != nil {
-		return nil, err
+	if len(runtimes) == 0 {
+		return nil, fmt.Errorf("no containers driver configured")
+	} else if len(runtimes) == 1 {
+		return runtimes[0].Driver, nil
 	}
 
-	return d, nil
+	return multi.New(runtimes...)
 }
 
 func LogSummary(ctx context.Context, config *configuration.Config) {
-	acontext.GetLogger(ctx).Infof("using %q containers driver", config.Containers.Type())
+	types := config.Containers.Types()
+	if len(types) == 1 {
+		acontext.GetLogger(ctx).Infof("using %q containers driver", types[0])
+		return
+	}
+
+	acontext.GetLogger(ctx).Infof("using %q containers drivers for runtimes %q", types, config.Containers.Names())
 }
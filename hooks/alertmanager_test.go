@@ -0,0 +1,155 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/hooks/formatting"
+)
+
+type postedAlert struct {
+	Labels   map[string]string `json:"labels"`
+	StartsAt string            `json:"startsAt"`
+	EndsAt   string            `json:"endsAt"`
+}
+
+// fakeAlertmanager stands in for the Alertmanager alerts API.
+type fakeAlertmanager struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status int
+	posts  [][]postedAlert
+}
+
+func newFakeAlertmanager(t *testing.T) *fakeAlertmanager {
+	f := &fakeAlertmanager{status: http.StatusOK}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("got request for %s", r.URL.Path)
+		}
+
+		var alerts []postedAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("error decoding alerts: %v", err)
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.posts = append(f.posts, alerts)
+		w.WriteHeader(f.status)
+	}))
+
+	return f
+}
+
+func (f *fakeAlertmanager) last() []postedAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.posts[len(f.posts)-1]
+}
+
+func (f *fakeAlertmanager) respond(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func newAlertmanagerShooter() *LiveShooter {
+	return &LiveShooter{
+		Clients:   &ClientCache{},
+		Formatter: &Formatter{Alertmanager: &formatting.Alertmanager{}},
+	}
+}
+
+func alertReaction(hook *v1.Hook, state v1.ContainerState, ts int64) *v1.Reaction {
+	return &v1.Reaction{
+		Timestamp: ts,
+		Hook:      hook,
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: "web", ImageName: "nginx", State: state},
+	}
+}
+
+func TestAlertmanagerResolvesCreationAlert(t *testing.T) {
+	am := newFakeAlertmanager(t)
+	defer am.Close()
+
+	s := newAlertmanagerShooter()
+	hook := &v1.Hook{ID: "h1", Name: "web-alerts", Url: am.URL, Format: v1.FormatAlertmanager}
+	if err := s.Fire(context.Background(), alertReaction(hook, v1.StateRunning, 1700000000)); err != nil {
+		t.Fatal(err)
+	}
+
+	created := am.last()
+	if len(created) != 1 || created[0].StartsAt != "2023-11-14T22:13:20Z" || created[0].EndsAt != "" {
+		t.Fatalf("got creation alerts %+v", created)
+	}
+
+	if err := s.Fire(context.Background(), alertReaction(hook, v1.StateStopped, 1700000060)); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the creation alert is sent again, resolved
+	resolved := am.last()
+	if len(resolved) != 1 {
+		t.Fatalf("got %d alerts for the deletion, want 1", len(resolved))
+	}
+
+	a := resolved[0]
+	if a.Labels["event"] != string(v1.EventCreate) || a.Labels["alertname"] != "web-alerts" || a.Labels["container"] != "web" {
+		t.Errorf("got labels %v", a.Labels)
+	} else if a.StartsAt != "2023-11-14T22:13:20Z" || a.EndsAt != "2023-11-14T22:14:20Z" {
+		t.Errorf("got starts at %q ends at %q", a.StartsAt, a.EndsAt)
+	}
+}
+
+func TestAlertmanagerStateIsPerDestination(t *testing.T) {
+	primary, secondary := newFakeAlertmanager(t), newFakeAlertmanager(t)
+	defer primary.Close()
+	defer secondary.Close()
+
+	s := newAlertmanagerShooter()
+	hook := &v1.Hook{
+		ID:     "h1",
+		Format: v1.FormatAlertmanager,
+		Destinations: []*v1.Destination{
+			{Url: primary.URL},
+			{Url: secondary.URL},
+		},
+	}
+
+	toPrimary, toSecondary := hook.ForDestination(hook.Destinations[0]), hook.ForDestination(hook.Destinations[1])
+	if err := s.Fire(context.Background(), alertReaction(toPrimary, v1.StateRunning, 1700000000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Fire(context.Background(), alertReaction(toSecondary, v1.StateRunning, 1700000030)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed deletion keeps the start so the retry still resolves it
+	primary.respond(http.StatusServiceUnavailable)
+	if err := s.Fire(context.Background(), alertReaction(toPrimary, v1.StateStopped, 1700000060)); err == nil {
+		t.Fatal("got no error from a failing alertmanager")
+	}
+
+	primary.respond(http.StatusOK)
+	for _, retry := range []*v1.Hook{toPrimary, toSecondary} {
+		if err := s.Fire(context.Background(), alertReaction(retry, v1.StateStopped, 1700000060)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a := primary.last()[0]; a.StartsAt != "2023-11-14T22:13:20Z" {
+		t.Errorf("primary: got starts at %q", a.StartsAt)
+	}
+
+	if a := secondary.last()[0]; a.StartsAt != "2023-11-14T22:13:50Z" {
+		t.Errorf("secondary: got starts at %q", a.StartsAt)
+	}
+}
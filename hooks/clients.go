@@ -0,0 +1,329 @@
+package hooks
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/configuration"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+const (
+	// defaultClientTimeout bounds a whole request when no timeout is
+	// configured.
+	defaultClientTimeout = 30 * time.Second
+
+	// maxCachedClients is how many clients are kept, the least recently used
+	// one is dropped for another.
+	maxCachedClients = 64
+)
+
+// ClientCache keeps an http.Client for every combination of transport
+// settings. Settings from a hook take precedence over the Defaults. Files a
+// hook sets have to be inside one of the AllowedDirs, clients are rebuilt
+// when their files change so certificates can be rotated. Only the most
+// recently used clients are kept, so settings of changed and deleted hooks
+// are dropped in time.
+type ClientCache struct {
+	Defaults    v1.TransportConfig
+	Policy      *DestinationPolicy
+	AllowedDirs []string
+	Timeout     time.Duration
+	mutex       sync.Mutex
+	clients     map[v1.TransportConfig]*list.Element
+	// cached clients, most recently used first
+	recent *list.List
+}
+
+type cachedClient struct {
+	*http.Client
+	config v1.TransportConfig
+	stamps [3]time.Time
+}
+
+// NewClientCache returns a cache for the configured transport settings.
+func NewClientCache(config configuration.TransportConfig, policy *DestinationPolicy) *ClientCache {
+	return &ClientCache{
+		Defaults:    TransportFromConfig(config),
+		Policy:      policy,
+		AllowedDirs: config.AllowedDirs,
+		Timeout:     config.Timeout,
+	}
+}
+
+// TransportFromConfig converts the configured default transport settings.
+func TransportFromConfig(config configuration.TransportConfig) v1.TransportConfig {
+	return v1.TransportConfig{
+		CAFile:     config.CAFile,
+		CertFile:   config.CertFile,
+		KeyFile:    config.KeyFile,
+		ServerName: config.ServerName,
+		MinVersion: config.MinVersion,
+		Proxy:      config.Proxy,
+	}
+}
+
+// CheckFiles validates the files set on a hook's transport settings, they
+// have to be inside one of the AllowedDirs.
+func (cache *ClientCache) CheckFiles(c *v1.TransportConfig) error {
+	if c == nil {
+		return nil
+	}
+
+	for _, path := range []string{c.CAFile, c.CertFile, c.KeyFile} {
+		if path != "" && !withinDirs(path, cache.AllowedDirs) {
+			return fmt.Errorf("transport file %q is not in an allowed directory", path)
+		}
+	}
+
+	return nil
+}
+
+// withinDirs reports if the file, with its symlinks resolved, is inside one
+// of the directories.
+func withinDirs(path string, dirs []string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range dirs {
+		if d, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = d
+		}
+
+		if isWithin(dir, resolved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileStamps returns the modification times of the files in the settings.
+func fileStamps(config v1.TransportConfig) [3]time.Time {
+	var stamps [3]time.Time
+	for i, path := range []string{config.CAFile, config.CertFile, config.KeyFile} {
+		if path == "" {
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil {
+			stamps[i] = info.ModTime()
+		}
+	}
+
+	return stamps
+}
+
+func (cache *ClientCache) Get(hook *v1.Hook) (*http.Client, error) {
+	// hooks stored before the files were restricted are checked here too
+	if err := cache.CheckFiles(hook.Transport); err != nil {
+		return nil, err
+	}
+
+	config := mergeTransportConfig(cache.Defaults, hook.Transport)
+	stamps := fileStamps(config)
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.clients == nil {
+		cache.clients = make(map[v1.TransportConfig]*list.Element)
+		cache.recent = list.New()
+	}
+
+	e, ok := cache.clients[config]
+	if ok && e.Value.(*cachedClient).stamps == stamps {
+		cache.recent.MoveToFront(e)
+		return e.Value.(*cachedClient).Client, nil
+	}
+
+	timeout := cache.Timeout
+	if timeout <= 0 {
+		timeout = defaultClientTimeout
+	}
+
+	// only the configured proxy is trusted, one set on the hook was checked
+	// against the policy when the hook was stored and is checked again when
+	// dialed
+	client, err := newHTTPClient(config, cache.Policy, config.Proxy == cache.Defaults.Proxy, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		// the files changed, requests in flight finish on the old client
+		cache.drop(e)
+	}
+
+	cache.clients[config] = cache.recent.PushFront(&cachedClient{client, config, stamps})
+	if cache.recent.Len() > maxCachedClients {
+		cache.drop(cache.recent.Back())
+	}
+
+	return client, nil
+}
+
+// drop forgets a cached client, requests in flight finish on it.
+func (cache *ClientCache) drop(e *list.Element) {
+	cached := cache.recent.Remove(e).(*cachedClient)
+	delete(cache.clients, cached.config)
+	cached.CloseIdleConnections()
+}
+
+func mergeTransportConfig(defaults v1.TransportConfig, c *v1.TransportConfig) v1.TransportConfig {
+	if c == nil {
+		return defaults
+	}
+
+	merged := defaults
+	if c.CAFile != "" {
+		merged.CAFile = c.CAFile
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		merged.CertFile = c.CertFile
+		merged.KeyFile = c.KeyFile
+	}
+
+	if c.ServerName != "" {
+		merged.ServerName = c.ServerName
+	}
+
+	if c.MinVersion != "" {
+		merged.MinVersion = c.MinVersion
+	}
+
+	if c.Proxy != "" {
+		merged.Proxy = c.Proxy
+	}
+
+	return merged
+}
+
+func newHTTPClient(config v1.TransportConfig, policy *DestinationPolicy, trustProxy bool, timeout time.Duration) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		ServerName: config.ServerName,
+	}
+
+	if config.MinVersion != "" {
+		v, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls version %q", config.MinVersion)
+		}
+
+		tlsConfig.MinVersion = v
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca bundle: %v", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", config.CAFile)
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %v", err)
+		}
+
+		proxy = http.ProxyURL(proxyURL)
+	} else {
+		trustProxy = true
+	}
+
+	// trusted proxies are dialed without the policy, the addresses the
+	// proxy is asked to reach are checked instead
+	var proxies sync.Map
+	dialer := policy.Dialer(30 * time.Second)
+	direct := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			return u, err
+		}
+
+		if err := policy.CheckAddresses(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+
+		if trustProxy {
+			proxies.Store(proxyAddr(u), true)
+		}
+
+		return u, nil
+	}
+
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if _, ok := proxies.Load(addr); ok {
+			return direct.DialContext(ctx, network, addr)
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// proxyAddr returns the address the transport dials to reach a proxy.
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		case "socks5":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
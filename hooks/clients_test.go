@@ -0,0 +1,248 @@
+package hooks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func newTestProxy(t *testing.T) (*httptest.Server, *[]string) {
+	var requested []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+	}))
+
+	return proxy, &requested
+}
+
+func TestClientChecksDestinationsBehindProxy(t *testing.T) {
+	proxy, requested := newTestProxy(t)
+	defer proxy.Close()
+
+	cache := &ClientCache{
+		Defaults: v1.TransportConfig{Proxy: proxy.URL},
+		Policy:   &DestinationPolicy{},
+	}
+
+	client, err := cache.Get(&v1.Hook{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the configured proxy is private but trusted
+	resp, err := client.Get("http://93.184.216.34/hook")
+	if err != nil {
+		t.Fatalf("public destination: %v", err)
+	}
+
+	resp.Body.Close()
+	if len(*requested) != 1 || (*requested)[0] != "http://93.184.216.34/hook" {
+		t.Fatalf("got proxied requests %v", *requested)
+	}
+
+	// the proxy would reach private destinations the dialer never sees
+	for _, dest := range []string{"http://10.0.0.1/hook", "http://169.254.169.254/latest/meta-data"} {
+		if _, err := client.Get(dest); err == nil {
+			t.Errorf("%s: got no error through the proxy", dest)
+		}
+	}
+
+	if len(*requested) != 1 {
+		t.Errorf("got proxied requests %v", *requested)
+	}
+}
+
+func TestClientDialsHookProxyThroughPolicy(t *testing.T) {
+	proxy, requested := newTestProxy(t)
+	defer proxy.Close()
+
+	cache := &ClientCache{Policy: &DestinationPolicy{}}
+	client, err := cache.Get(&v1.Hook{Transport: &v1.TransportConfig{Proxy: proxy.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get("http://93.184.216.34/hook"); err == nil {
+		t.Error("got no error dialing a private proxy set on the hook")
+	}
+
+	if len(*requested) != 0 {
+		t.Errorf("got proxied requests %v", *requested)
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	policy := &DestinationPolicy{}
+	for raw, ok := range map[string]bool{
+		"http://proxy.example.com:3128": true,
+		"socks5://203.0.113.7:1080":     true,
+		"http://127.0.0.1:3128":         false,
+		"http://10.1.2.3:3128":          false,
+		"ftp://proxy.example.com":       false,
+		"http://":                       false,
+	} {
+		if err := policy.CheckProxy(raw); (err == nil) != ok {
+			t.Errorf("%s: got %v", raw, err)
+		}
+	}
+}
+
+func TestCheckFilesAllowedDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	allowed := filepath.Join(dir, "allowed")
+	os.Mkdir(allowed, 0700)
+	inside := filepath.Join(allowed, "ca.pem")
+	outside := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(inside, []byte("ca"), 0600)
+	ioutil.WriteFile(outside, []byte("ca"), 0600)
+	link := filepath.Join(allowed, "link.pem")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &ClientCache{AllowedDirs: []string{allowed}}
+	for path, ok := range map[string]bool{
+		inside:                                 true,
+		outside:                                false,
+		link:                                   false,
+		filepath.Join(allowed, "..", "ca.pem"): false,
+		filepath.Join(allowed, "missing.pem"):  false,
+	} {
+		if err := cache.CheckFiles(&v1.TransportConfig{CAFile: path}); (err == nil) != ok {
+			t.Errorf("%s: got %v", path, err)
+		}
+	}
+
+	if err := (&ClientCache{}).CheckFiles(&v1.TransportConfig{KeyFile: inside}); err == nil {
+		t.Error("got no error without allowed directories")
+	}
+
+	if _, err := cache.Get(&v1.Hook{Transport: &v1.TransportConfig{CertFile: outside}}); err == nil {
+		t.Error("got a client for a file outside the allowed directories")
+	}
+}
+
+func TestClientReloadsRotatedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	writeCA := func(cert *x509.Certificate, stamp time.Time) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err := ioutil.WriteFile(ca, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Chtimes(ca, stamp, stamp)
+	}
+
+	// any CA but the one the test server's certificate is signed by
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeCA(other, time.Now().Add(-time.Hour))
+
+	cache := &ClientCache{Defaults: v1.TransportConfig{CAFile: ca}, Policy: &DestinationPolicy{AllowPrivate: true}}
+	client, err := cache.Get(&v1.Hook{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Timeout != defaultClientTimeout {
+		t.Errorf("got timeout %s", client.Timeout)
+	}
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("got no error from a server signed by another CA")
+	}
+
+	writeCA(server.Certificate(), time.Now())
+	client, err = cache.Get(&v1.Hook{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("rotated CA: %v", err)
+	}
+
+	resp.Body.Close()
+}
+
+func TestClientCacheKeepsRecentClients(t *testing.T) {
+	cache := &ClientCache{}
+	hook := func(i int) *v1.Hook {
+		return &v1.Hook{Transport: &v1.TransportConfig{ServerName: fmt.Sprintf("hook%d.example.com", i)}}
+	}
+
+	first, err := cache.Get(hook(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < maxCachedClients+10; i++ {
+		if _, err := cache.Get(hook(i)); err != nil {
+			t.Fatal(err)
+		}
+
+		// the first client stays recently used
+		if client, _ := cache.Get(hook(0)); client != first {
+			t.Fatalf("client %d dropped the most recently used one", i)
+		}
+	}
+
+	if len(cache.clients) != maxCachedClients || cache.recent.Len() != maxCachedClients {
+		t.Errorf("got %d clients cached, %d in order", len(cache.clients), cache.recent.Len())
+	}
+
+	if _, ok := cache.clients[mergeTransportConfig(cache.Defaults, hook(1).Transport)]; ok {
+		t.Error("least recently used client kept")
+	}
+}
@@ -0,0 +1,156 @@
+package hooks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// ParseDebounce returns the coalescing window and the create and delete pair
+// suppression window of a debounce policy. Without a policy both are zero and
+// reactions are delivered right away.
+func ParseDebounce(policy *v1.DebouncePolicy) (time.Duration, time.Duration, error) {
+	if policy == nil {
+		return 0, 0, nil
+	}
+
+	window, err := parseWindow("debounce window", policy.Window)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pairs, err := parseWindow("pair suppression window", policy.SuppressPairs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return window, pairs, nil
+}
+
+func parseWindow(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	} else if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+
+	return d, nil
+}
+
+type pendingReaction struct {
+	first   *v1.Reaction
+	last    *v1.Reaction
+	started time.Time
+	count   int
+	timer   *time.Timer
+}
+
+// Debouncer holds back reactions of hooks with a debounce policy so events
+// for the same container within the window are delivered as one reaction
+// carrying the final state and the number of coalesced transitions.
+type Debouncer struct {
+	// Fire is called with reactions ready to be delivered.
+	Fire func(r *v1.Reaction)
+	// Drop is called with the deletion reaction of a suppressed create and
+	// delete pair, it may be nil.
+	Drop func(r *v1.Reaction)
+
+	mu      sync.Mutex
+	pending map[string]*pendingReaction
+}
+
+// Add queues the reaction, reactions of hooks without a debounce policy are
+// fired immediately.
+func (d *Debouncer) Add(r *v1.Reaction) {
+	window, pairs, err := ParseDebounce(r.Hook.Debounce)
+	if err != nil || (window == 0 && pairs == 0) {
+		d.Fire(r)
+		return
+	}
+
+	key := r.Hook.ID + "/" + r.Container.Name
+	now := time.Now()
+	d.mu.Lock()
+	if d.pending == nil {
+		d.pending = make(map[string]*pendingReaction)
+	}
+
+	if p, ok := d.pending[key]; ok {
+		if eventType(p.first) == v1.EventContainerCreation && eventType(r) == v1.EventContainerDeletion && now.Sub(p.started) <= pairs {
+			p.timer.Stop()
+			delete(d.pending, key)
+			d.mu.Unlock()
+			if d.Drop != nil {
+				d.Drop(r)
+			}
+
+			return
+		}
+
+		p.last = r
+		p.count++
+		d.mu.Unlock()
+		return
+	}
+
+	hold := window
+	if eventType(r) == v1.EventContainerCreation && pairs > hold {
+		hold = pairs
+	}
+
+	if hold == 0 {
+		d.mu.Unlock()
+		d.Fire(r)
+		return
+	}
+
+	d.pending[key] = &pendingReaction{
+		first:   r,
+		last:    r,
+		started: now,
+		timer: time.AfterFunc(hold, func() {
+			d.flush(key)
+		}),
+	}
+
+	d.mu.Unlock()
+}
+
+func (d *Debouncer) flush(key string) {
+	d.mu.Lock()
+	p, ok := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	r := p.last
+	if p.count > 0 {
+		dupe := *r
+		r = &dupe
+		r.Coalesced = p.count
+		if r.Change != nil && p.first.Change != nil {
+			change := *r.Change
+			change.PreviousState = p.first.Change.PreviousState
+			r.Change = &change
+		}
+	}
+
+	d.Fire(r)
+}
+
+func eventType(r *v1.Reaction) v1.ContainerEventType {
+	if r.Change == nil || r.Change.Source == nil {
+		return ""
+	}
+
+	return r.Change.Source.Type
+}
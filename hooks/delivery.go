@@ -0,0 +1,41 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+type deliveryKey struct{}
+
+// WithDelivery returns a context carrying the delivery record for a reaction
+// so that shooters can annotate it with details such as captured output.
+func WithDelivery(ctx context.Context, d *v1.Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, d)
+}
+
+// GetDelivery returns the delivery record carried by the context or nil.
+func GetDelivery(ctx context.Context) *v1.Delivery {
+	d, _ := ctx.Value(deliveryKey{}).(*v1.Delivery)
+	return d
+}
+
+// ErrQueued is returned by shooters that queued the reaction to be sent
+// later, they call the report function from the context with the result.
+var ErrQueued = errors.New("reaction queued")
+
+type reportKey struct{}
+
+// WithReport returns a context carrying the function shooters call with the
+// result of a reaction they queued. Shooters only queue reactions when the
+// context has one.
+func WithReport(ctx context.Context, report func(err error)) context.Context {
+	return context.WithValue(ctx, reportKey{}, report)
+}
+
+// GetReport returns the report function carried by the context or nil.
+func GetReport(ctx context.Context) func(err error) {
+	report, _ := ctx.Value(reportKey{}).(func(err error))
+	return report
+}
@@ -0,0 +1,135 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	defaultExecTimeout = 30 * time.Second
+	maxExecOutput      = 64 * 1024
+	// how long to wait for the output to close after the executable was
+	// killed, children it started may still hold it open
+	execWaitDelay = time.Second
+	// the only variable passed on from the agent's environment is a
+	// standard PATH, the agent's credentials must not leak
+	execPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// ExecShooter runs a local executable for every reaction. The destination is
+// given as `exec:///path/to/executable`, with optional `arg` query values
+// passed as arguments. The formatted body is piped on stdin and the reaction
+// details are exposed through `CSENSE_*` environment variables.
+type ExecShooter struct {
+	Formatter *Formatter
+	Allowed   []string
+	Timeout   time.Duration
+}
+
+func (s *ExecShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	path := filepath.Clean(u.Path)
+	if !s.isAllowed(path) {
+		return fmt.Errorf("executable %q is not allowed", path)
+	}
+
+	body, bodyType, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output := &limitedBuffer{limit: maxExecOutput}
+	cmd := exec.CommandContext(ctx, path, u.Query()["arg"]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.Env = append([]string{execPath}, execEnv(r, bodyType)...)
+	cmd.WaitDelay = execWaitDelay
+	killProcessGroup(cmd)
+
+	err = cmd.Run()
+	if d := GetDelivery(ctx); d != nil {
+		d.Output = output.String()
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("executable %q timed out after %v", path, timeout)
+	} else if err != nil {
+		return fmt.Errorf("error running executable %q: %v", path, err)
+	}
+
+	return nil
+}
+
+func (s *ExecShooter) isAllowed(path string) bool {
+	for _, allowed := range s.Allowed {
+		if filepath.Clean(allowed) == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func execEnv(r *v1.Reaction, bodyType string) []string {
+	return []string{
+		"CSENSE_EVENT=" + string(r.Event()),
+		"CSENSE_DELIVERY_ID=" + r.ID,
+		"CSENSE_SEQUENCE=" + fmt.Sprint(r.Sequence),
+		"CSENSE_TIMESTAMP=" + fmt.Sprint(r.Timestamp),
+		"CSENSE_HOOK_ID=" + r.Hook.ID,
+		"CSENSE_HOOK_NAME=" + r.Hook.Name,
+		"CSENSE_HOST=" + r.Host.Hostname,
+		"CSENSE_CONTAINER_NAME=" + r.Container.Name,
+		"CSENSE_CONTAINER_STATE=" + string(r.Container.State),
+		"CSENSE_PREVIOUS_STATE=" + string(previousState(r)),
+		"CSENSE_IMAGE_NAME=" + r.Container.ImageName,
+		"CSENSE_IMAGE_TAG=" + r.Container.ImageTag,
+		"CSENSE_BODY_TYPE=" + bodyType,
+	}
+}
+
+// limitedBuffer keeps at most limit bytes of output and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+func previousState(r *v1.Reaction) v1.ContainerState {
+	if r.Change == nil {
+		return ""
+	}
+
+	return r.Change.PreviousState
+}
@@ -0,0 +1,78 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func writeScript(t *testing.T, dir string, script string) string {
+	path := filepath.Join(dir, "hook.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func execReaction(path string) *v1.Reaction {
+	return &v1.Reaction{
+		ID:        "r1",
+		Hook:      &v1.Hook{ID: "h1", Url: "exec://" + path, Format: v1.FormatJSON},
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: "web", State: v1.StateRunning},
+	}
+}
+
+func TestExecShooterEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	os.Setenv("CSENSE_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("CSENSE_TEST_SECRET")
+	path := writeScript(t, dir, "env\n")
+	s := &ExecShooter{Formatter: &Formatter{}, Allowed: []string{path}}
+	d := &v1.Delivery{}
+	if err := s.Fire(WithDelivery(context.Background(), d), execReaction(path)); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(d.Output, "hunter2") {
+		t.Error("the agent's environment was passed on")
+	}
+
+	for _, v := range []string{"CSENSE_HOOK_ID=h1", "CSENSE_CONTAINER_NAME=web", "PATH="} {
+		if !strings.Contains(d.Output, v) {
+			t.Errorf("missing %s in %s", v, d.Output)
+		}
+	}
+}
+
+func TestExecShooterKillsChildren(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	// the child inherits the output and outlives the script
+	path := writeScript(t, dir, "sleep 30 &\nsleep 30\n")
+	s := &ExecShooter{Formatter: &Formatter{}, Allowed: []string{path}, Timeout: 100 * time.Millisecond}
+	started := time.Now()
+	if err := s.Fire(context.Background(), execReaction(path)); err == nil {
+		t.Error("got no error from a script that timed out")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("took %s to give up", elapsed)
+	}
+}
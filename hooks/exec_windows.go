@@ -0,0 +1,9 @@
+package hooks
+
+import (
+	"os/exec"
+)
+
+// killProcessGroup leaves the command as is, children it started are only
+// cut off by the wait delay.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,254 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// FileShooter appends reactions to a local file, one formatted body per line.
+// The destination is given as `file:///var/log/csense/events.jsonl` with the
+// optional `max_size` (e.g. `100MB`), `max_backups` and `fsync` query values.
+// Only files inside one of the Allowed directories may be written.
+type FileShooter struct {
+	Formatter *Formatter
+	Allowed   []string
+	mutex     sync.Mutex
+	files     map[string]*rotatingFile
+}
+
+func (s *FileShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	path := filepath.Clean(u.Path)
+	if !s.isAllowed(path) {
+		return fmt.Errorf("file %q is not in an allowed directory", path)
+	}
+
+	q := u.Query()
+	var maxSize int64
+	if v := q.Get("max_size"); v != "" {
+		if maxSize, err = parseSize(v); err != nil {
+			return fmt.Errorf("invalid max size %q", v)
+		}
+	}
+
+	maxBackups := 1
+	if v := q.Get("max_backups"); v != "" {
+		if maxBackups, err = strconv.Atoi(v); err != nil || maxBackups < 0 {
+			return fmt.Errorf("invalid max backups %q", v)
+		}
+	}
+
+	fsync := false
+	if v := q.Get("fsync"); v != "" {
+		if fsync, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid fsync flag %q", v)
+		}
+	}
+
+	body, _, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	line := append(bytes.TrimRight(body, "\r\n"), '\n')
+	if err := s.file(path).write(line, maxSize, maxBackups, fsync); err != nil {
+		return fmt.Errorf("error writing to %q: %v", path, err)
+	}
+
+	return nil
+}
+
+// isAllowed reports if the file is inside one of the allowed directories
+// with symlinks resolved, so a link in an allowed directory can't lead out of
+// it.
+func (s *FileShooter) isAllowed(path string) bool {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range s.Allowed {
+		if d, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = d
+		}
+
+		if isWithin(dir, resolved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolvePath resolves the symlinks of the path as far as it exists, the
+// directories and file yet to be created are kept as they are.
+func resolvePath(path string) (string, error) {
+	rest := ""
+	for p := path; ; p = filepath.Dir(p) {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		} else if _, err := os.Lstat(p); err == nil {
+			// a dangling link, creating the file would follow it
+			return "", fmt.Errorf("%q links to a missing file", p)
+		}
+
+		if parent := filepath.Dir(p); parent == p {
+			return path, nil
+		}
+
+		rest = filepath.Join(filepath.Base(p), rest)
+	}
+}
+
+// isWithin reports if the path is below the directory.
+func isWithin(dir string, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (s *FileShooter) file(path string) *rotatingFile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.files == nil {
+		s.files = make(map[string]*rotatingFile)
+	}
+
+	f, ok := s.files[path]
+	if !ok {
+		f = &rotatingFile{path: path}
+		s.files[path] = f
+	}
+
+	return f
+}
+
+type rotatingFile struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	size  int64
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts `path.N` to `path.N+1` up to maxBackups and moves the current
+// file to `path.1`.
+func (f *rotatingFile) rotate(maxBackups int) error {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+
+	if maxBackups == 0 {
+		return os.Remove(f.path)
+	}
+
+	for i := maxBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(f.path, f.path+".1")
+}
+
+func (f *rotatingFile) write(line []byte, maxSize int64, maxBackups int, fsync bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > maxSize {
+		if err := f.rotate(maxBackups); err != nil {
+			return err
+		}
+
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		f.file.Close()
+		f.file = nil
+		return err
+	}
+
+	if fsync {
+		return f.file.Sync()
+	}
+
+	return nil
+}
+
+func parseSize(v string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	v = strings.ToUpper(strings.TrimSpace(v))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(v, unit.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+
+	return n * multiplier, nil
+}
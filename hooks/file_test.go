@@ -0,0 +1,63 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func TestFileShooterResolvesSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	allowed := filepath.Join(dir, "allowed")
+	outside := filepath.Join(dir, "outside")
+	os.Mkdir(allowed, 0700)
+	os.Mkdir(outside, 0700)
+	links := map[string]string{
+		"dir":      outside,
+		"file.log": filepath.Join(outside, "existing.log"),
+		"dangling": filepath.Join(outside, "missing.log"),
+	}
+
+	ioutil.WriteFile(filepath.Join(outside, "existing.log"), nil, 0600)
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(allowed, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &FileShooter{Formatter: &Formatter{}, Allowed: []string{allowed}}
+	fire := func(path string) error {
+		return s.Fire(context.Background(), &v1.Reaction{
+			Hook:      &v1.Hook{Url: "file://" + path, Format: v1.FormatJSON},
+			Container: &v1.ContainerInfo{Name: "web"},
+		})
+	}
+
+	for _, path := range []string{
+		filepath.Join(allowed, "dir", "events.log"),
+		filepath.Join(allowed, "dir", "new", "events.log"),
+		filepath.Join(allowed, "file.log"),
+		filepath.Join(allowed, "dangling"),
+	} {
+		if err := fire(path); err == nil {
+			t.Errorf("%s: got no error", path)
+		}
+	}
+
+	if files, _ := ioutil.ReadDir(outside); len(files) != 1 {
+		t.Errorf("got %d files outside the allowed directory", len(files))
+	}
+
+	if err := fire(filepath.Join(allowed, "new", "events.log")); err != nil {
+		t.Errorf("new directory: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/hooks/formatting"
+)
+
+// Formatter renders a reaction body in the format requested by its hook.
+type Formatter struct {
+	Alertmanager *formatting.Alertmanager
+}
+
+func (f *Formatter) Format(r *v1.Reaction) ([]byte, string, error) {
+	switch r.Hook.Format {
+	case v1.FormatJSON:
+		return formatting.JSON(r)
+	case v1.FormatSlackJSON:
+		return formatting.Slack(r)
+	case v1.FormatAlertmanager:
+		if f.Alertmanager == nil {
+			return nil, "", fmt.Errorf("body format %q not configured", r.Hook.Format)
+		}
+
+		return f.Alertmanager.Format(r)
+	}
+
+	return nil, "", fmt.Errorf("body format %q unsupported", r.Hook.Format)
+}
+
+// Delivered is called once a reaction's body was accepted by the destination.
+func (f *Formatter) Delivered(r *v1.Reaction) {
+	if r.Hook.Format == v1.FormatAlertmanager && f.Alertmanager != nil {
+		f.Alertmanager.Delivered(r)
+	}
+}
@@ -0,0 +1,180 @@
+package formatting
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const alertmanagerAlertsPath = "/api/v2/alerts"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// AlertmanagerURL returns the alerts endpoint for an Alertmanager base url.
+func AlertmanagerURL(base string) string {
+	base = strings.TrimSuffix(base, "/")
+	if strings.HasSuffix(base, alertmanagerAlertsPath) {
+		return base
+	}
+
+	return base + alertmanagerAlertsPath
+}
+
+// maxAlertStarts bounds the creation times kept for containers that haven't
+// been deleted, the first delivered are dropped first.
+const maxAlertStarts = 10000
+
+// Alertmanager formats reactions as Prometheus Alertmanager alerts. It keeps
+// track of container creation times delivered to each destination so that a
+// deletion can resolve the alert that was opened by the matching creation.
+type Alertmanager struct {
+	URLs   *v1.URLBuilder
+	mutex  sync.Mutex
+	starts map[string]*list.Element
+	// creation times in the order they were first delivered
+	order *list.List
+}
+
+type alertStart struct {
+	key      string
+	startsAt int64
+}
+
+func alertKey(r *v1.Reaction) string {
+	return r.Hook.ID + "/" + r.Hook.Url + "/" + r.Container.Name
+}
+
+func (f *Alertmanager) Format(r *v1.Reaction) ([]byte, string, error) {
+	labels := withLabel(alertLabels(r), "event", string(v1.EventCreate))
+	annotations := map[string]string{
+		"summary": fmt.Sprintf("Container %s %s on %s", r.Container.Name, r.Container.State, r.Host.Hostname),
+	}
+
+	a := &alert{
+		Labels:       labels,
+		Annotations:  annotations,
+		StartsAt:     formatAlertTime(r.Timestamp),
+		GeneratorURL: f.generatorURL(r.Hook),
+	}
+
+	// a deletion resolves the alert opened by the creation
+	if r.Container.State == v1.StateStopped {
+		a.StartsAt = ""
+		a.EndsAt = formatAlertTime(r.Timestamp)
+		f.mutex.Lock()
+		if e, ok := f.starts[alertKey(r)]; ok {
+			a.StartsAt = formatAlertTime(e.Value.(*alertStart).startsAt)
+		}
+
+		f.mutex.Unlock()
+	}
+
+	b, err := json.Marshal([]*alert{a})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return b, "application/json", nil
+}
+
+// Delivered records the creation time of a container once its alert was
+// delivered and forgets it once the resolving alert was delivered, so retries
+// and other destinations still resolve the same alert.
+func (f *Alertmanager) Delivered(r *v1.Reaction) {
+	key := alertKey(r)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	e, ok := f.starts[key]
+	if r.Container.State == v1.StateStopped {
+		if ok {
+			f.order.Remove(e)
+			delete(f.starts, key)
+		}
+
+		return
+	}
+
+	if ok {
+		e.Value.(*alertStart).startsAt = r.Timestamp
+		return
+	}
+
+	if f.starts == nil {
+		f.starts = make(map[string]*list.Element)
+		f.order = list.New()
+	}
+
+	if len(f.starts) >= maxAlertStarts {
+		oldest := f.order.Remove(f.order.Front()).(*alertStart)
+		delete(f.starts, oldest.key)
+	}
+
+	f.starts[key] = f.order.PushBack(&alertStart{key, r.Timestamp})
+}
+
+func (f *Alertmanager) generatorURL(hook *v1.Hook) string {
+	if f.URLs == nil {
+		return ""
+	}
+
+	u, err := f.URLs.BuildHook(hook.ID)
+	if err != nil {
+		return ""
+	}
+
+	return u
+}
+
+func alertLabels(r *v1.Reaction) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range r.Container.Labels {
+		labels[labelName(k)] = v
+	}
+
+	labels["container"] = r.Container.Name
+	labels["image"] = r.Container.ImageName
+	if r.Container.ImageTag != "" {
+		labels["image"] += ":" + r.Container.ImageTag
+	}
+
+	labels["host"] = r.Host.Hostname
+	labels["alertname"] = r.Hook.Name
+	return labels
+}
+
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	result[name] = value
+	return result
+}
+
+func labelName(name string) string {
+	name = invalidLabelChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
+
+func formatAlertTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+type alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     string            `json:"startsAt,omitempty"`
+	EndsAt       string            `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
@@ -0,0 +1,65 @@
+package formatting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func TestAlertmanagerBoundsStarts(t *testing.T) {
+	f := &Alertmanager{}
+	hook := &v1.Hook{ID: "h1", Url: "http://alertmanager:9093"}
+	for i := 0; i < maxAlertStarts+10; i++ {
+		r := &v1.Reaction{
+			Timestamp: int64(i + 1),
+			Hook:      hook,
+			Host:      &v1.HostInfo{},
+			Container: &v1.ContainerInfo{Name: fmt.Sprintf("c%d", i), State: v1.StateRunning},
+		}
+
+		f.Delivered(r)
+	}
+
+	if len(f.starts) != maxAlertStarts {
+		t.Fatalf("got %d starts, want %d", len(f.starts), maxAlertStarts)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, ok := f.starts[hook.ID+"/"+hook.Url+"/"+fmt.Sprintf("c%d", i)]; ok {
+			t.Errorf("oldest start c%d kept", i)
+		}
+	}
+}
+
+func TestAlertmanagerDropsFirstDelivered(t *testing.T) {
+	f := &Alertmanager{}
+	hook := &v1.Hook{ID: "h1", Url: "http://alertmanager:9093"}
+	reaction := func(name string, state v1.ContainerState) *v1.Reaction {
+		return &v1.Reaction{
+			Timestamp: 1700000000,
+			Hook:      hook,
+			Host:      &v1.HostInfo{},
+			Container: &v1.ContainerInfo{Name: name, State: state},
+		}
+	}
+
+	for i := 0; i < maxAlertStarts; i++ {
+		f.Delivered(reaction(fmt.Sprintf("c%d", i), v1.StateRunning))
+	}
+
+	// a deleted container's start is gone, a redelivered one keeps its place
+	f.Delivered(reaction("c1", v1.StateStopped))
+	f.Delivered(reaction("c0", v1.StateRunning))
+	f.Delivered(reaction("new1", v1.StateRunning))
+	f.Delivered(reaction("new2", v1.StateRunning))
+	if len(f.starts) != maxAlertStarts || f.order.Len() != maxAlertStarts {
+		t.Fatalf("got %d starts, %d in order", len(f.starts), f.order.Len())
+	}
+
+	for name, kept := range map[string]bool{"c0": false, "c1": false, "c2": true, "new1": true, "new2": true} {
+		if _, ok := f.starts[hook.ID+"/"+hook.Url+"/"+name]; ok != kept {
+			t.Errorf("%s: got kept %v", name, ok)
+		}
+	}
+}
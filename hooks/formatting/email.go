@@ -0,0 +1,51 @@
+package formatting
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+var emailFuncs = map[string]interface{}{
+	"time": func(ts int64) string {
+		return time.Unix(ts, 0).UTC().Format(time.RFC1123)
+	},
+}
+
+var emailText = texttemplate.Must(texttemplate.New("text").Funcs(emailFuncs).Parse(
+	`{{range .}}Container {{.Container.Name}} {{.Container.State}} on {{.Host.Hostname}}
+  Hook:  {{.Hook.Name}} ({{.Hook.ID}})
+  Image: {{.Container.ImageName}}{{if .Container.ImageTag}}:{{.Container.ImageTag}}{{end}}
+  Time:  {{time .Timestamp}}
+{{range $k, $v := .Container.Labels}}  Label: {{$k}}={{$v}}
+{{end}}
+{{end}}`))
+
+var emailHTML = htmltemplate.Must(htmltemplate.New("html").Funcs(emailFuncs).Parse(
+	`<html><body>
+{{range .}}<h3>Container <code>{{.Container.Name}}</code> {{.Container.State}} on {{.Host.Hostname}}</h3>
+<table>
+<tr><th align="left">Hook</th><td>{{.Hook.Name}} ({{.Hook.ID}})</td></tr>
+<tr><th align="left">Image</th><td>{{.Container.ImageName}}{{if .Container.ImageTag}}:{{.Container.ImageTag}}{{end}}</td></tr>
+<tr><th align="left">Time</th><td>{{time .Timestamp}}</td></tr>
+{{range $k, $v := .Container.Labels}}<tr><th align="left">Label</th><td>{{$k}}={{$v}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>`))
+
+// Email renders the reactions as the plain text and HTML parts of an email.
+func Email(reactions []*v1.Reaction) ([]byte, []byte, error) {
+	text := &bytes.Buffer{}
+	if err := emailText.Execute(text, reactions); err != nil {
+		return nil, nil, err
+	}
+
+	html := &bytes.Buffer{}
+	if err := emailHTML.Execute(html, reactions); err != nil {
+		return nil, nil, err
+	}
+
+	return text.Bytes(), html.Bytes(), nil
+}
@@ -2,11 +2,14 @@ package hooks
 
 import (
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/danielkrainas/gobag/util/uuid"
 
 	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/containers"
 )
 
 type Filter interface {
@@ -19,21 +22,19 @@ func (f *CriteriaFilter) Match(hook *v1.Hook, c *v1.ContainerInfo) bool {
 	crit := hook.Criteria
 
 	for fieldName, condition := range crit.Fields {
-		valid := false
-		switch fieldName {
-		case v1.FieldName:
-			valid = IsValid(condition, c.Name)
-		case v1.FieldImageName:
-			valid = IsValid(condition, c.ImageName)
+		if isValidAny(condition, FieldValues(c, fieldName)) {
+			return true
 		}
+	}
 
-		if valid {
-			return valid
+	for k, v := range crit.Labels {
+		if x, ok := c.Labels[k]; ok && x == v {
+			return true
 		}
 	}
 
-	for k, v := range c.Labels {
-		if x, ok := c.Labels[k]; ok && x == v {
+	for k, v := range crit.Annotations {
+		if x, ok := c.Annotations[k]; ok && x == v {
 			return true
 		}
 	}
@@ -41,6 +42,174 @@ func (f *CriteriaFilter) Match(hook *v1.Hook, c *v1.ContainerInfo) bool {
 	return false
 }
 
+// containerFields are the fields FieldValues knows.
+var containerFields = map[v1.ContainerField]bool{
+	v1.FieldName:            true,
+	v1.FieldImageName:       true,
+	v1.FieldImageRegistry:   true,
+	v1.FieldImageRepository: true,
+	v1.FieldImageTag:        true,
+	v1.FieldImageDigest:     true,
+	v1.FieldID:              true,
+	v1.FieldCreated:         true,
+	v1.FieldStartedAt:       true,
+	v1.FieldFinishedAt:      true,
+	v1.FieldExitCode:        true,
+	v1.FieldRestartCount:    true,
+	v1.FieldCommand:         true,
+	v1.FieldPort:            true,
+	v1.FieldNetwork:         true,
+	v1.FieldIPAddress:       true,
+	v1.FieldCPUs:            true,
+	v1.FieldCPUShares:       true,
+	v1.FieldMemoryLimit:     true,
+}
+
+// FieldValues returns the container's values for a criteria field. Names
+// match any of the container's aliases or its cgroup path, image names
+// match in their short and fully qualified forms, numbers are
+// formatted in base 10, times as unix timestamps, and the command is joined
+// with spaces. Unknown fields have no value.
+func FieldValues(c *v1.ContainerInfo, field v1.ContainerField) []string {
+	switch field {
+	case v1.FieldName:
+		return containerNames(c)
+	case v1.FieldImageName:
+		return imageNames(c)
+	case v1.FieldImageRegistry:
+		return []string{c.ImageRegistry}
+	case v1.FieldImageRepository:
+		return []string{c.ImageRepository}
+	case v1.FieldImageTag:
+		return []string{c.ImageTag}
+	case v1.FieldImageDigest:
+		return []string{c.ImageDigest}
+	case v1.FieldID:
+		return []string{c.ID}
+	case v1.FieldCreated:
+		return []string{formatInt(c.Created)}
+	case v1.FieldStartedAt:
+		return []string{formatInt(c.StartedAt)}
+	case v1.FieldFinishedAt:
+		return []string{formatInt(c.FinishedAt)}
+	case v1.FieldExitCode:
+		return []string{formatIntPtr(c.ExitCode)}
+	case v1.FieldRestartCount:
+		return []string{formatIntPtr(c.RestartCount)}
+	case v1.FieldCommand:
+		return []string{strings.Join(c.Command, " ")}
+	case v1.FieldPort:
+		// either form matches, `80/tcp` or `0.0.0.0:8080->80/tcp`
+		values := make([]string, 0, len(c.Ports)*2)
+		for _, p := range c.Ports {
+			values = append(values, strconv.Itoa(p.ContainerPort)+"/"+p.Protocol)
+			if p.HostPort != 0 {
+				values = append(values, p.String())
+			}
+		}
+
+		return values
+	case v1.FieldNetwork:
+		values := make([]string, 0, len(c.Networks))
+		for _, n := range c.Networks {
+			values = append(values, n.Name)
+		}
+
+		return values
+	case v1.FieldIPAddress:
+		values := make([]string, 0)
+		for _, n := range c.Networks {
+			values = append(values, n.IPAddresses...)
+		}
+
+		return values
+	}
+
+	if c.Resources == nil {
+		return nil
+	}
+
+	switch field {
+	case v1.FieldCPUs:
+		if c.Resources.CPUs == 0 {
+			return nil
+		}
+
+		return []string{strconv.FormatFloat(c.Resources.CPUs, 'f', -1, 64)}
+	case v1.FieldCPUShares:
+		return []string{formatInt(c.Resources.CPUShares)}
+	case v1.FieldMemoryLimit:
+		return []string{formatInt(c.Resources.MemoryLimit)}
+	}
+
+	return nil
+}
+
+// containerNames returns the names the container goes by.
+func containerNames(c *v1.ContainerInfo) []string {
+	names := append([]string{c.Name}, c.Aliases...)
+	if c.CgroupPath != "" {
+		names = append(names, c.CgroupPath)
+	}
+
+	return names
+}
+
+// imageNames returns the names the container's image goes by, `nginx`,
+// `library/nginx` and `docker.io/library/nginx` for Docker Hub's images.
+func imageNames(c *v1.ContainerInfo) []string {
+	names := []string{c.ImageName}
+	if c.ImageRegistry == "" || c.ImageRepository == "" {
+		return names
+	}
+
+	if c.ImageRegistry == containers.DefaultRegistry && c.ImageRepository != c.ImageName {
+		names = append(names, c.ImageRepository)
+	}
+
+	if full := c.ImageRegistry + "/" + c.ImageRepository; full != c.ImageName {
+		names = append(names, full)
+	}
+
+	return names
+}
+
+func formatInt(v int64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+func formatIntPtr(v *int) string {
+	if v == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*v)
+}
+
+// isValidAny checks the condition against every value, a missing value is
+// empty. Not equal holds when no value is equal, the others when any value
+// matches.
+func isValidAny(c *v1.Condition, values []string) bool {
+	if c == nil {
+		return false
+	} else if len(values) == 0 {
+		values = []string{""}
+	}
+
+	negated := c.Op == v1.OperandNotEqual || c.Op == v1.OperandNotEqualShort
+	for _, v := range values {
+		if IsValid(c, v) != negated {
+			return !negated
+		}
+	}
+
+	return negated
+}
+
 func IsValid(c *v1.Condition, v string) bool {
 	if c == nil {
 		return false
@@ -75,9 +244,30 @@ func DefaultHook() *v1.Hook {
 	}
 }
 
+// Subscribed reports whether the hook wants reactions to the event. Hooks
+// without events get creations and deletions, existing containers only when
+// they ask for them.
+func Subscribed(hook *v1.Hook, e v1.EventType) bool {
+	if len(hook.Events) == 0 {
+		return e != v1.EventExist
+	}
+
+	for _, x := range hook.Events {
+		if x == e {
+			return true
+		}
+	}
+
+	return false
+}
+
 func FilterAll(hooks []*v1.Hook, c *v1.ContainerInfo, f Filter) []*v1.Hook {
 	results := make([]*v1.Hook, 0)
 	for _, hook := range hooks {
+		if hook.Status == v1.HookPendingVerification {
+			continue
+		}
+
 		if f.Match(hook, c) {
 			results = append(results, hook)
 		}
@@ -0,0 +1,437 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	defaultKafkaPort      = "9092"
+	defaultKafkaLinger    = 100 * time.Millisecond
+	defaultKafkaBatchSize = 100
+	kafkaMetadataTTL      = 5 * time.Minute
+	kafkaHookIDHeader     = "csense-hook-id"
+	kafkaDeliveryHeader   = "csense-delivery-id"
+	kafkaSequenceHeader   = "csense-sequence"
+	kafkaEventHeader      = "csense-event"
+)
+
+// KafkaShooter produces reactions to a Kafka topic. The destination is given
+// as `kafka://broker:9092/topic?acks=all&compression=gzip&linger=100ms&batch_size=100`.
+// Records are keyed by container name so that events for a container stay in
+// order on a single partition, and producers are shared by every hook that
+// targets the same bootstrap broker.
+type KafkaShooter struct {
+	Formatter *Formatter
+	Policy    *DestinationPolicy
+	mutex     sync.Mutex
+	producers map[string]*kafkaProducer
+}
+
+func (s *KafkaShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	topic := strings.TrimPrefix(u.Path, "/")
+	if topic == "" {
+		return fmt.Errorf("kafka topic not specified")
+	}
+
+	opts, err := parseKafkaOptions(u.Query())
+	if err != nil {
+		return err
+	}
+
+	body, _, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	record := &kafkaRecord{
+		Key:       []byte(r.Container.Name),
+		Value:     body,
+		Timestamp: time.Now(),
+		Headers: []kafkaHeader{
+			{Key: kafkaHookIDHeader, Value: []byte(r.Hook.ID)},
+			{Key: kafkaDeliveryHeader, Value: []byte(r.ID)},
+			{Key: kafkaSequenceHeader, Value: []byte(fmt.Sprint(r.Sequence))},
+			{Key: kafkaEventHeader, Value: []byte(r.Event())},
+		},
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultKafkaPort)
+	}
+
+	produced := func(err error) error {
+		if err != nil {
+			return fmt.Errorf("error producing to kafka topic %q: %v", topic, err)
+		}
+
+		return nil
+	}
+
+	// the next reaction doesn't wait for the batch when the result can be
+	// reported later
+	if report := GetReport(ctx); report != nil {
+		s.producer(addr).enqueue(topic, opts, record, func(err error) {
+			report(produced(err))
+		})
+
+		return ErrQueued
+	}
+
+	done := make(chan error, 1)
+	s.producer(addr).enqueue(topic, opts, record, func(err error) {
+		done <- err
+	})
+
+	select {
+	case err := <-done:
+		return produced(err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *KafkaShooter) producer(addr string) *kafkaProducer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.producers == nil {
+		s.producers = make(map[string]*kafkaProducer)
+	}
+
+	p, ok := s.producers[addr]
+	if !ok {
+		p = &kafkaProducer{
+			bootstrap: addr,
+			dialer:    s.Policy.Dialer(brokerDialTimeout),
+			conns:     make(map[string]*kafkaConn),
+			topics:    make(map[string]*kafkaTopic),
+			batches:   make(map[kafkaBatchKey]*kafkaBatch),
+		}
+
+		s.producers[addr] = p
+	}
+
+	return p
+}
+
+type kafkaOptions struct {
+	acks        int16
+	compression int16
+	linger      time.Duration
+	batchSize   int
+}
+
+func parseKafkaOptions(q url.Values) (kafkaOptions, error) {
+	opts := kafkaOptions{
+		acks:        -1,
+		compression: kafkaCompressionNone,
+		linger:      defaultKafkaLinger,
+		batchSize:   defaultKafkaBatchSize,
+	}
+
+	switch v := q.Get("acks"); v {
+	case "", "all", "-1":
+	case "0":
+		opts.acks = 0
+	case "1":
+		opts.acks = 1
+	default:
+		return opts, fmt.Errorf("invalid kafka acks %q", v)
+	}
+
+	switch v := q.Get("compression"); v {
+	case "", "none":
+	case "gzip":
+		opts.compression = kafkaCompressionGzip
+	default:
+		return opts, fmt.Errorf("unsupported kafka compression %q", v)
+	}
+
+	if v := q.Get("linger"); v != "" {
+		linger, err := time.ParseDuration(v)
+		if err != nil || linger < 0 {
+			return opts, fmt.Errorf("invalid kafka linger %q", v)
+		}
+
+		opts.linger = linger
+	}
+
+	if v := q.Get("batch_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			return opts, fmt.Errorf("invalid kafka batch size %q", v)
+		}
+
+		opts.batchSize = size
+	}
+
+	return opts, nil
+}
+
+type kafkaTopic struct {
+	*kafkaMetadata
+	fetched time.Time
+}
+
+type kafkaBatchKey struct {
+	topic string
+	opts  kafkaOptions
+}
+
+type kafkaPending struct {
+	record *kafkaRecord
+	report func(err error)
+}
+
+type kafkaBatch struct {
+	pending []*kafkaPending
+	timer   *time.Timer
+}
+
+type kafkaProducer struct {
+	bootstrap string
+	dialer    *net.Dialer
+	mutex     sync.Mutex
+	conns     map[string]*kafkaConn
+	topics    map[string]*kafkaTopic
+	batches   map[kafkaBatchKey]*kafkaBatch
+}
+
+// enqueue adds the record to the pending batch for the topic. The batch is
+// sent once it's full or the linger time passes, and the result reported for
+// each of its records.
+func (p *kafkaProducer) enqueue(topic string, opts kafkaOptions, record *kafkaRecord, report func(err error)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := kafkaBatchKey{topic, opts}
+	pending := &kafkaPending{
+		record: record,
+		report: report,
+	}
+
+	b, ok := p.batches[key]
+	if !ok {
+		b = &kafkaBatch{}
+		p.batches[key] = b
+		b.timer = time.AfterFunc(opts.linger, func() {
+			p.mutex.Lock()
+			if p.batches[key] != b {
+				p.mutex.Unlock()
+				return
+			}
+
+			delete(p.batches, key)
+			p.mutex.Unlock()
+			p.send(key, b)
+		})
+	}
+
+	b.pending = append(b.pending, pending)
+	if len(b.pending) >= opts.batchSize {
+		b.timer.Stop()
+		delete(p.batches, key)
+		go p.send(key, b)
+	}
+}
+
+func (p *kafkaProducer) send(key kafkaBatchKey, b *kafkaBatch) {
+	errs := p.produce(key.topic, key.opts, b.pending)
+	retry := make([]*kafkaPending, 0)
+	for i, pending := range b.pending {
+		if errs[i] != nil {
+			retry = append(retry, pending)
+		} else {
+			pending.report(nil)
+		}
+	}
+
+	if len(retry) == 0 {
+		return
+	}
+
+	p.invalidate(key.topic)
+	errs = p.produce(key.topic, key.opts, retry)
+	for i, pending := range retry {
+		pending.report(errs[i])
+	}
+}
+
+// produce sends the records to the partition leaders and returns the result
+// for each record.
+func (p *kafkaProducer) produce(topic string, opts kafkaOptions, pending []*kafkaPending) []error {
+	errs := make([]error, len(pending))
+	md, err := p.metadata(topic)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+
+		return errs
+	}
+
+	byLeader := make(map[int32]map[int32][]int)
+	for i, pend := range pending {
+		partition := kafkaPartition(pend.record.Key, len(md.Partitions))
+		leader, ok := md.Partitions[partition]
+		if !ok || leader < 0 {
+			errs[i] = kafkaError(5)
+			continue
+		}
+
+		if byLeader[leader] == nil {
+			byLeader[leader] = make(map[int32][]int)
+		}
+
+		byLeader[leader][partition] = append(byLeader[leader][partition], i)
+	}
+
+	for leader, partitions := range byLeader {
+		fail := func(err error) {
+			for _, indexes := range partitions {
+				for _, i := range indexes {
+					errs[i] = err
+				}
+			}
+		}
+
+		addr, ok := md.Brokers[leader]
+		if !ok {
+			fail(kafkaError(5))
+			continue
+		}
+
+		batches, err := encodePartitionBatches(pending, partitions, opts.compression)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		conn, err := p.conn(addr)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		results, err := conn.produce(topic, opts.acks, brokerIOTimeout, batches)
+		if err != nil {
+			p.dropConn(addr, conn)
+			fail(err)
+			continue
+		}
+
+		for partition, indexes := range partitions {
+			for _, i := range indexes {
+				errs[i] = results[partition]
+			}
+		}
+	}
+
+	return errs
+}
+
+func encodePartitionBatches(pending []*kafkaPending, partitions map[int32][]int, compression int16) (map[int32][]byte, error) {
+	batches := make(map[int32][]byte, len(partitions))
+	for partition, indexes := range partitions {
+		records := make([]*kafkaRecord, len(indexes))
+		for j, i := range indexes {
+			records[j] = pending[i].record
+		}
+
+		batch, err := encodeRecordBatch(records, compression)
+		if err != nil {
+			return nil, err
+		}
+
+		batches[partition] = batch
+	}
+
+	return batches, nil
+}
+
+func (p *kafkaProducer) metadata(topic string) (*kafkaMetadata, error) {
+	p.mutex.Lock()
+	t, ok := p.topics[topic]
+	p.mutex.Unlock()
+	if ok && time.Since(t.fetched) < kafkaMetadataTTL {
+		return t.kafkaMetadata, nil
+	}
+
+	conn, err := p.conn(p.bootstrap)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := conn.metadata(topic)
+	if err != nil {
+		if _, ok := err.(kafkaError); !ok {
+			p.dropConn(p.bootstrap, conn)
+		}
+
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	p.topics[topic] = &kafkaTopic{md, time.Now()}
+	p.mutex.Unlock()
+	return md, nil
+}
+
+func (p *kafkaProducer) invalidate(topic string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.topics, topic)
+}
+
+// conn returns the connection to a broker, dialing it without holding the
+// mutex so a slow broker doesn't hold up batching for the others.
+func (p *kafkaProducer) conn(addr string) (*kafkaConn, error) {
+	p.mutex.Lock()
+	conn, ok := p.conns[addr]
+	p.mutex.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	conn, err := dialKafka(p.dialer, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if existing, ok := p.conns[addr]; ok {
+		// dialed concurrently
+		conn.Close()
+		return existing, nil
+	}
+
+	p.conns[addr] = conn
+	return conn, nil
+}
+
+func (p *kafkaProducer) dropConn(addr string, conn *kafkaConn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.conns[addr] == conn {
+		delete(p.conns, addr)
+	}
+
+	conn.Close()
+}
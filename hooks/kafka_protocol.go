@@ -0,0 +1,564 @@
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Just enough of the Kafka wire protocol to look up partition leaders and
+// produce record batches (message format v2, needed for record headers).
+
+const (
+	kafkaApiProduce     int16 = 0
+	kafkaApiMetadata    int16 = 3
+	kafkaApiApiVersions int16 = 18
+
+	kafkaProduceVersion int16 = 3
+	// metadata versions up to 4 share a layout, brokers since Kafka 4.0 no
+	// longer accept versions before 4 (KIP-896)
+	kafkaMaxMetadataVersion int16 = 4
+
+	kafkaCompressionNone int16 = 0
+	kafkaCompressionGzip int16 = 1
+
+	kafkaClientID = "csense"
+
+	// maxKafkaResponseSize bounds the buffer allocated for a response, the
+	// size comes from the broker.
+	maxKafkaResponseSize = 64 << 20
+)
+
+var (
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+	errKafkaMalformed = errors.New("malformed kafka response")
+)
+
+type kafkaError int16
+
+func (err kafkaError) Error() string {
+	switch err {
+	case 3:
+		return "unknown topic or partition"
+	case 5:
+		return "leader not available"
+	case 6:
+		return "not leader for partition"
+	case 7:
+		return "request timed out"
+	case 10:
+		return "message too large"
+	case 19:
+		return "not enough replicas"
+	case 29:
+		return "topic authorization failed"
+	}
+
+	return fmt.Sprintf("kafka error code %d", int16(err))
+}
+
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.WriteByte(byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.Write(b[:])
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.WriteString(s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.Write(b)
+}
+
+func (e *kafkaEncoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.Write(b[:n])
+}
+
+func (e *kafkaEncoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+
+	e.varint(int64(len(b)))
+	e.Write(b)
+}
+
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	} else if n < 0 || len(d.b) < n {
+		d.err = errKafkaMalformed
+		return nil
+	}
+
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) arrayLen() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	} else if n > len(d.b) {
+		d.err = errKafkaMalformed
+		return 0
+	}
+
+	return n
+}
+
+type kafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+type kafkaRecord struct {
+	Key       []byte
+	Value     []byte
+	Headers   []kafkaHeader
+	Timestamp time.Time
+}
+
+func kafkaMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// encodeRecordBatch encodes the records as a v2 record batch.
+func encodeRecordBatch(records []*kafkaRecord, compression int16) ([]byte, error) {
+	first := kafkaMillis(records[0].Timestamp)
+	max := first
+	body := &kafkaEncoder{}
+	for i, rec := range records {
+		ts := kafkaMillis(rec.Timestamp)
+		if ts > max {
+			max = ts
+		}
+
+		r := &kafkaEncoder{}
+		r.int8(0)
+		r.varint(ts - first)
+		r.varint(int64(i))
+		r.varbytes(rec.Key)
+		r.varbytes(rec.Value)
+		r.varint(int64(len(rec.Headers)))
+		for _, h := range rec.Headers {
+			r.varbytes([]byte(h.Key))
+			r.varbytes(h.Value)
+		}
+
+		body.varint(int64(r.Len()))
+		body.Write(r.Bytes())
+	}
+
+	recordData := body.Bytes()
+	switch compression {
+	case kafkaCompressionNone:
+	case kafkaCompressionGzip:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(recordData); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		recordData = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d", compression)
+	}
+
+	crcd := &kafkaEncoder{}
+	crcd.int16(compression)
+	crcd.int32(int32(len(records) - 1))
+	crcd.int64(first)
+	crcd.int64(max)
+	crcd.int64(-1)
+	crcd.int16(-1)
+	crcd.int32(-1)
+	crcd.int32(int32(len(records)))
+	crcd.Write(recordData)
+
+	batch := &kafkaEncoder{}
+	batch.int64(0)
+	batch.int32(int32(4 + 1 + 4 + crcd.Len()))
+	batch.int32(-1)
+	batch.int8(2)
+	batch.int32(int32(crc32.Checksum(crcd.Bytes(), crc32c)))
+	batch.Write(crcd.Bytes())
+	return batch.Bytes(), nil
+}
+
+// murmur2 is the hash used by the reference Java client's default
+// partitioner so keyed records land on the same partitions.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func kafkaPartition(key []byte, partitions int) int32 {
+	return int32(int(murmur2(key)&0x7fffffff) % partitions)
+}
+
+type kafkaConn struct {
+	net.Conn
+	mutex           sync.Mutex
+	reader          *bufio.Reader
+	correlationID   int32
+	metadataVersion int16
+}
+
+// dialKafka connects to a broker and negotiates the request versions with it.
+func dialKafka(d *net.Dialer, addr string) (*kafkaConn, error) {
+	c, err := d.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &kafkaConn{
+		Conn:   c,
+		reader: bufio.NewReader(c),
+	}
+
+	if err := conn.negotiate(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("error negotiating api versions with %s: %v", addr, err)
+	}
+
+	return conn, nil
+}
+
+// negotiate picks the highest metadata version both sides support and checks
+// that the broker accepts the produce version.
+func (conn *kafkaConn) negotiate() error {
+	resp, err := conn.roundTrip(kafkaApiApiVersions, 0, nil, true)
+	if err != nil {
+		return err
+	}
+
+	d := &kafkaDecoder{b: resp}
+	if code := d.int16(); code != 0 {
+		return kafkaError(code)
+	}
+
+	versions := make(map[int16][2]int16)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		key := d.int16()
+		versions[key] = [2]int16{d.int16(), d.int16()}
+	}
+
+	if d.err != nil {
+		return d.err
+	}
+
+	produce, ok := versions[kafkaApiProduce]
+	if !ok || produce[0] > kafkaProduceVersion || produce[1] < kafkaProduceVersion {
+		return fmt.Errorf("broker doesn't support produce version %d", kafkaProduceVersion)
+	}
+
+	metadata, ok := versions[kafkaApiMetadata]
+	if !ok || metadata[0] > kafkaMaxMetadataVersion {
+		return fmt.Errorf("broker doesn't support metadata versions up to %d", kafkaMaxMetadataVersion)
+	}
+
+	conn.metadataVersion = kafkaMaxMetadataVersion
+	if metadata[1] < conn.metadataVersion {
+		conn.metadataVersion = metadata[1]
+	}
+
+	return nil
+}
+
+// roundTrip sends a request and returns the response body. A nil body is
+// returned without waiting when expectResponse is false.
+func (conn *kafkaConn) roundTrip(apiKey int16, apiVersion int16, body []byte, expectResponse bool) ([]byte, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.correlationID++
+	req := &kafkaEncoder{}
+	req.int32(int32(2 + 2 + 4 + 2 + len(kafkaClientID) + len(body)))
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(conn.correlationID)
+	req.string(kafkaClientID)
+	req.Write(body)
+
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout))
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	} else if !expectResponse {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(conn.reader, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxKafkaResponseSize {
+		return nil, fmt.Errorf("kafka response of %d bytes is too large", n)
+	}
+
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn.reader, resp); err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{b: resp}
+	if id := d.int32(); d.err != nil {
+		return nil, d.err
+	} else if id != conn.correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d", id)
+	}
+
+	return d.b, nil
+}
+
+type kafkaMetadata struct {
+	Brokers    map[int32]string
+	Partitions map[int32]int32
+}
+
+func (conn *kafkaConn) metadata(topic string) (*kafkaMetadata, error) {
+	version := conn.metadataVersion
+	req := &kafkaEncoder{}
+	req.int32(1)
+	req.string(topic)
+	if version >= 4 {
+		// allow_auto_topic_creation, what earlier versions always did
+		req.int8(1)
+	}
+
+	resp, err := conn.roundTrip(kafkaApiMetadata, version, req.Bytes(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &kafkaMetadata{
+		Brokers:    make(map[int32]string),
+		Partitions: make(map[int32]int32),
+	}
+
+	d := &kafkaDecoder{b: resp}
+	if version >= 3 {
+		// throttle_time_ms
+		d.int32()
+	}
+
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		if version >= 1 {
+			// rack
+			d.string()
+		}
+
+		md.Brokers[id] = net.JoinHostPort(host, fmt.Sprint(port))
+	}
+
+	if version >= 2 {
+		// cluster_id
+		d.string()
+	}
+
+	if version >= 1 {
+		// controller_id
+		d.int32()
+	}
+
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topicErr := d.int16()
+		name := d.string()
+		if version >= 1 {
+			// is_internal
+			d.int8()
+		}
+
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16()
+			partition := d.int32()
+			leader := d.int32()
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32()
+			}
+
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32()
+			}
+
+			if name == topic {
+				md.Partitions[partition] = leader
+			}
+		}
+
+		if name == topic && topicErr != 0 {
+			return nil, kafkaError(topicErr)
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	} else if len(md.Partitions) == 0 {
+		return nil, kafkaError(3)
+	}
+
+	return md, nil
+}
+
+// produce sends record batches for partitions of a topic and returns the
+// error reported for each partition.
+func (conn *kafkaConn) produce(topic string, acks int16, timeout time.Duration, batches map[int32][]byte) (map[int32]error, error) {
+	req := &kafkaEncoder{}
+	req.int16(-1)
+	req.int16(acks)
+	req.int32(int32(timeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(int32(len(batches)))
+	for partition, batch := range batches {
+		req.int32(partition)
+		req.bytes(batch)
+	}
+
+	results := make(map[int32]error, len(batches))
+	resp, err := conn.roundTrip(kafkaApiProduce, kafkaProduceVersion, req.Bytes(), acks != 0)
+	if err != nil {
+		return nil, err
+	} else if acks == 0 {
+		return results, nil
+	}
+
+	d := &kafkaDecoder{b: resp}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string()
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			partition := d.int32()
+			code := d.int16()
+			d.int64()
+			d.int64()
+			if code != 0 {
+				results[partition] = kafkaError(code)
+			}
+		}
+	}
+
+	return results, d.err
+}
@@ -0,0 +1,589 @@
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// kafkaRequestHeader is the header of a request read by the fake broker.
+type kafkaRequestHeader struct {
+	apiKey        int16
+	apiVersion    int16
+	correlationID int32
+}
+
+type producedRecord struct {
+	key     string
+	value   string
+	headers map[string]string
+}
+
+// fakeKafkaBroker is a single broker leading every partition of the topics it
+// is asked about. It speaks just enough of the protocol for the producer,
+// written against the protocol reference rather than the producer's code.
+type fakeKafkaBroker struct {
+	l net.Listener
+	// metadata versions advertised by ApiVersions
+	minMetadata, maxMetadata int16
+	mu                       sync.Mutex
+	metadataVersions         []int16
+	produced                 []producedRecord
+	// raw requests by api key
+	requests map[int16][][]byte
+	// respond to metadata with a size prefix instead of a response
+	responseSize uint32
+}
+
+func newFakeKafkaBroker(t *testing.T, minMetadata int16, maxMetadata int16) *fakeKafkaBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &fakeKafkaBroker{l: l, minMetadata: minMetadata, maxMetadata: maxMetadata}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go b.serve(t, c)
+		}
+	}()
+
+	return b
+}
+
+func (b *fakeKafkaBroker) Close() {
+	b.l.Close()
+}
+
+func (b *fakeKafkaBroker) addr() (string, int32) {
+	addr := b.l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), int32(addr.Port)
+}
+
+func (b *fakeKafkaBroker) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+
+		req := make([]byte, size)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+
+		b.mu.Lock()
+		if b.requests == nil {
+			b.requests = make(map[int16][][]byte)
+		}
+
+		apiKey := int16(binary.BigEndian.Uint16(req))
+		b.requests[apiKey] = append(b.requests[apiKey], req)
+		b.mu.Unlock()
+
+		d := &kafkaDecoder{b: req}
+		h := kafkaRequestHeader{apiKey: d.int16(), apiVersion: d.int16(), correlationID: d.int32()}
+		d.string()
+		var resp []byte
+		switch h.apiKey {
+		case kafkaApiApiVersions:
+			resp = b.apiVersions()
+		case kafkaApiMetadata:
+			b.mu.Lock()
+			b.metadataVersions = append(b.metadataVersions, h.apiVersion)
+			hijack := b.responseSize
+			b.mu.Unlock()
+			if hijack > 0 {
+				binary.Write(c, binary.BigEndian, hijack)
+				c.Write([]byte{1, 2, 3, 4})
+				return
+			}
+
+			resp = b.metadata(h.apiVersion, d)
+		case kafkaApiProduce:
+			resp = b.produce(t, d)
+		default:
+			t.Errorf("unexpected api key %d", h.apiKey)
+			return
+		}
+
+		out := make([]byte, 8, 8+len(resp))
+		binary.BigEndian.PutUint32(out, uint32(4+len(resp)))
+		binary.BigEndian.PutUint32(out[4:], uint32(h.correlationID))
+		if _, err := c.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeKafkaBroker) apiVersions() []byte {
+	e := &kafkaEncoder{}
+	e.int16(0)
+	e.int32(3)
+	for _, v := range [][3]int16{{kafkaApiProduce, 3, 9}, {kafkaApiMetadata, b.minMetadata, b.maxMetadata}, {kafkaApiApiVersions, 0, 3}} {
+		e.int16(v[0])
+		e.int16(v[1])
+		e.int16(v[2])
+	}
+
+	return e.Bytes()
+}
+
+func (b *fakeKafkaBroker) metadata(version int16, d *kafkaDecoder) []byte {
+	topics := make([]string, d.arrayLen())
+	for i := range topics {
+		topics[i] = d.string()
+	}
+
+	host, port := b.addr()
+	e := &kafkaEncoder{}
+	if version >= 3 {
+		e.int32(0)
+	}
+
+	e.int32(1)
+	e.int32(1)
+	e.string(host)
+	e.int32(port)
+	if version >= 1 {
+		e.int16(-1)
+	}
+
+	if version >= 2 {
+		e.string("cluster")
+	}
+
+	if version >= 1 {
+		e.int32(1)
+	}
+
+	e.int32(int32(len(topics)))
+	for _, topic := range topics {
+		e.int16(0)
+		e.string(topic)
+		if version >= 1 {
+			e.int8(0)
+		}
+
+		e.int32(2)
+		for partition := int32(0); partition < 2; partition++ {
+			e.int16(0)
+			e.int32(partition)
+			e.int32(1)
+			e.int32(1)
+			e.int32(1)
+			e.int32(1)
+			e.int32(1)
+		}
+	}
+
+	return e.Bytes()
+}
+
+func readVarint(t *testing.T, r *bytes.Reader) int64 {
+	v, err := binary.ReadVarint(r)
+	if err != nil {
+		t.Fatalf("error reading varint: %v", err)
+	}
+
+	return v
+}
+
+func readVarbytes(t *testing.T, r *bytes.Reader) string {
+	n := readVarint(t, r)
+	if n < 0 {
+		return ""
+	}
+
+	b := make([]byte, n)
+	io.ReadFull(r, b)
+	return string(b)
+}
+
+// decodeBatch reads the records of an uncompressed v2 record batch.
+func decodeBatch(t *testing.T, batch []byte) []producedRecord {
+	// base offset, batch length, leader epoch, magic, crc, attributes,
+	// last offset delta, timestamps, producer id and epoch, base sequence
+	header := 8 + 4 + 4 + 1 + 4 + 2 + 4 + 8 + 8 + 8 + 2 + 4
+	if magic := batch[16]; magic != 2 {
+		t.Fatalf("got magic %d", magic)
+	}
+
+	count := int(binary.BigEndian.Uint32(batch[header:]))
+	r := bytes.NewReader(batch[header+4:])
+	records := make([]producedRecord, count)
+	for i := range records {
+		readVarint(t, r)
+		r.ReadByte()
+		readVarint(t, r)
+		readVarint(t, r)
+		records[i].key = readVarbytes(t, r)
+		records[i].value = readVarbytes(t, r)
+		records[i].headers = make(map[string]string)
+		for h := readVarint(t, r); h > 0; h-- {
+			k := readVarbytes(t, r)
+			records[i].headers[k] = readVarbytes(t, r)
+		}
+	}
+
+	return records
+}
+
+func (b *fakeKafkaBroker) produce(t *testing.T, d *kafkaDecoder) []byte {
+	d.string()
+	acks := d.int16()
+	d.int32()
+	e := &kafkaEncoder{}
+	topics := d.arrayLen()
+	e.int32(int32(topics))
+	for i := 0; i < topics; i++ {
+		topic := d.string()
+		partitions := d.arrayLen()
+		e.string(topic)
+		e.int32(int32(partitions))
+		for j := 0; j < partitions; j++ {
+			partition := d.int32()
+			batch := d.next(int(d.int32()))
+			// compressed batches are only checked from the raw requests
+			if binary.BigEndian.Uint16(batch[21:])&7 == 0 {
+				b.mu.Lock()
+				b.produced = append(b.produced, decodeBatch(t, batch)...)
+				b.mu.Unlock()
+			}
+
+			e.int32(partition)
+			e.int16(0)
+			e.int64(0)
+			e.int64(-1)
+		}
+	}
+
+	e.int32(0)
+	if acks == 0 {
+		t.Error("got a response expected for acks=0")
+	}
+
+	return e.Bytes()
+}
+
+func kafkaReaction(url string, container string) *v1.Reaction {
+	return &v1.Reaction{
+		ID:        "r1",
+		Sequence:  7,
+		Hook:      &v1.Hook{ID: "h1", Url: url, Format: v1.FormatJSON},
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: container, State: v1.StateRunning},
+	}
+}
+
+func TestKafkaShooterProduces(t *testing.T) {
+	for _, versions := range [][2]int16{{0, 12}, {4, 12}, {0, 1}} {
+		broker := newFakeKafkaBroker(t, versions[0], versions[1])
+		host, port := broker.addr()
+		url := "kafka://" + net.JoinHostPort(host, strconv.Itoa(int(port))) + "/events?linger=1ms"
+		s := &KafkaShooter{Formatter: &Formatter{}}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		for _, name := range []string{"web", "db"} {
+			if err := s.Fire(ctx, kafkaReaction(url, name)); err != nil {
+				t.Fatalf("metadata versions %v: %v", versions, err)
+			}
+		}
+
+		cancel()
+		broker.Close()
+		broker.mu.Lock()
+		want := versions[1]
+		if want > kafkaMaxMetadataVersion {
+			want = kafkaMaxMetadataVersion
+		}
+
+		if len(broker.metadataVersions) == 0 || broker.metadataVersions[0] != want {
+			t.Errorf("metadata versions %v: got requests for %v, want %d", versions, broker.metadataVersions, want)
+		}
+
+		if len(broker.produced) != 2 {
+			t.Fatalf("metadata versions %v: got %d records", versions, len(broker.produced))
+		}
+
+		for _, rec := range broker.produced {
+			if rec.key != "web" && rec.key != "db" {
+				t.Errorf("got key %q", rec.key)
+			} else if rec.headers[kafkaHookIDHeader] != "h1" || rec.headers[kafkaSequenceHeader] != "7" {
+				t.Errorf("got headers %v", rec.headers)
+			} else if !bytes.Contains([]byte(rec.value), []byte(`"name":"`+rec.key+`"`)) {
+				t.Errorf("got value %s", rec.value)
+			}
+		}
+
+		broker.mu.Unlock()
+	}
+}
+
+func TestKafkaRejectsOldBrokers(t *testing.T) {
+	// Kafka 4.0 and later only accept metadata from version 4
+	broker := newFakeKafkaBroker(t, 5, 12)
+	defer broker.Close()
+
+	host, port := broker.addr()
+	if _, err := dialKafka(&net.Dialer{}, net.JoinHostPort(host, strconv.Itoa(int(port)))); err == nil {
+		t.Error("got no error from a broker without a usable metadata version")
+	}
+}
+
+func TestKafkaBoundsResponseSize(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 0, 4)
+	defer broker.Close()
+
+	broker.mu.Lock()
+	broker.responseSize = 0xfffffff0
+	broker.mu.Unlock()
+	host, port := broker.addr()
+	conn, err := dialKafka(&net.Dialer{}, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	if _, err := conn.metadata("events"); err == nil {
+		t.Error("got no error for an oversized response")
+	}
+}
+
+// wireReader reads big-endian protocol fields, independently of the
+// producer's own decoder.
+type wireReader struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (w *wireReader) read(v interface{}) {
+	if err := binary.Read(w.r, binary.BigEndian, v); err != nil {
+		w.t.Fatalf("error reading %T: %v", v, err)
+	}
+}
+
+func (w *wireReader) int8() (v int8)   { w.read(&v); return }
+func (w *wireReader) int16() (v int16) { w.read(&v); return }
+func (w *wireReader) int32() (v int32) { w.read(&v); return }
+func (w *wireReader) int64() (v int64) { w.read(&v); return }
+
+func (w *wireReader) bytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(w.r, b); err != nil {
+		w.t.Fatalf("error reading %d bytes: %v", n, err)
+	}
+
+	return b
+}
+
+func (w *wireReader) string() string {
+	n := w.int16()
+	if n < 0 {
+		return ""
+	}
+
+	return string(w.bytes(int(n)))
+}
+
+// header checks the request header and returns the api version.
+func (w *wireReader) header(apiKey int16) int16 {
+	if key := w.int16(); key != apiKey {
+		w.t.Fatalf("got api key %d, want %d", key, apiKey)
+	}
+
+	version := w.int16()
+	w.int32()
+	if client := w.string(); client == "" {
+		w.t.Error("got no client id")
+	}
+
+	return version
+}
+
+func TestKafkaWireEncoding(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 0, 12)
+	defer broker.Close()
+
+	host, port := broker.addr()
+	url := "kafka://" + net.JoinHostPort(host, strconv.Itoa(int(port))) + "/events?acks=1&compression=gzip&linger=50ms"
+	s := &KafkaShooter{Formatter: &Formatter{}}
+	reports := make(chan error, 2)
+	ctx := WithReport(context.Background(), func(err error) {
+		reports <- err
+	})
+
+	// both records are queued for the same batch
+	for _, name := range []string{"web", "db"} {
+		if err := s.Fire(ctx, kafkaReaction(url, name)); err != ErrQueued {
+			t.Fatalf("got %v queueing %s", err, name)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-reports:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("records never reported")
+		}
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	metadata := broker.requests[kafkaApiMetadata]
+	if len(metadata) == 0 {
+		t.Fatal("got no metadata request")
+	}
+
+	w := &wireReader{t, bytes.NewReader(metadata[0])}
+	if version := w.header(kafkaApiMetadata); version != 4 {
+		t.Errorf("got metadata version %d", version)
+	}
+
+	if n := w.int32(); n != 1 {
+		t.Fatalf("got %d topics", n)
+	}
+
+	if topic := w.string(); topic != "events" {
+		t.Errorf("got topic %q", topic)
+	}
+
+	if auto := w.int8(); auto != 1 {
+		t.Errorf("got allow_auto_topic_creation %d", auto)
+	}
+
+	if w.r.Len() != 0 {
+		t.Errorf("got %d bytes after the metadata request", w.r.Len())
+	}
+
+	keys := make([]string, 0)
+	for _, req := range broker.requests[kafkaApiProduce] {
+		w := &wireReader{t, bytes.NewReader(req)}
+		if version := w.header(kafkaApiProduce); version != 3 {
+			t.Errorf("got produce version %d", version)
+		}
+
+		if id := w.int16(); id != -1 {
+			t.Errorf("got transactional id length %d", id)
+		}
+
+		if acks := w.int16(); acks != 1 {
+			t.Errorf("got acks %d", acks)
+		}
+
+		if timeout := w.int32(); timeout <= 0 {
+			t.Errorf("got timeout %d", timeout)
+		}
+
+		for topics := w.int32(); topics > 0; topics-- {
+			w.string()
+			for partitions := w.int32(); partitions > 0; partitions-- {
+				w.int32()
+				batch := w.bytes(int(w.int32()))
+				keys = append(keys, readWireBatch(t, batch)...)
+			}
+		}
+	}
+
+	if len(keys) != 2 {
+		t.Errorf("got records for %v", keys)
+	}
+}
+
+// readWireBatch checks a gzip compressed v2 record batch and returns the
+// keys of its records.
+func readWireBatch(t *testing.T, batch []byte) []string {
+	w := &wireReader{t, bytes.NewReader(batch)}
+	w.int64()
+	if length := w.int32(); int(length) != len(batch)-12 {
+		t.Errorf("got batch length %d for %d bytes", length, len(batch)-12)
+	}
+
+	w.int32()
+	if magic := w.int8(); magic != 2 {
+		t.Fatalf("got magic %d", magic)
+	}
+
+	crc := uint32(w.int32())
+	if sum := crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)); sum != crc {
+		t.Errorf("got crc %x, want %x", crc, sum)
+	}
+
+	if attributes := w.int16(); attributes&7 != 1 {
+		t.Errorf("got attributes %d", attributes)
+	}
+
+	lastOffsetDelta := w.int32()
+	w.int64()
+	w.int64()
+	if producer := w.int64(); producer != -1 {
+		t.Errorf("got producer id %d", producer)
+	}
+
+	w.int16()
+	w.int32()
+	count := w.int32()
+	if count != lastOffsetDelta+1 {
+		t.Errorf("got %d records with last offset delta %d", count, lastOffsetDelta)
+	}
+
+	zr, err := gzip.NewReader(w.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(records)
+	keys := make([]string, count)
+	for i := range keys {
+		length := readVarint(t, r)
+		start := r.Len()
+		r.ReadByte()
+		readVarint(t, r)
+		if delta := readVarint(t, r); delta != int64(i) {
+			t.Errorf("got offset delta %d for record %d", delta, i)
+		}
+
+		keys[i] = readVarbytes(t, r)
+		readVarbytes(t, r)
+		for h := readVarint(t, r); h > 0; h-- {
+			readVarbytes(t, r)
+			readVarbytes(t, r)
+		}
+
+		if read := int64(start - r.Len()); read != length {
+			t.Errorf("got record length %d, read %d", length, read)
+		}
+	}
+
+	if r.Len() != 0 {
+		t.Errorf("got %d bytes after the records", r.Len())
+	}
+
+	return keys
+}
@@ -0,0 +1,313 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/gobag/util/uuid"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	defaultMQTTPort  = "1883"
+	defaultMQTTTopic = "csense/{host}/{event}"
+
+	mqttConnect = 0x10
+	mqttConnack = 0x20
+	mqttPublish = 0x30
+	mqttPuback  = 0x40
+	mqttPubrec  = 0x50
+	mqttPubrel  = 0x62
+	mqttPubcomp = 0x70
+)
+
+var mqttTopicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// MQTTShooter publishes reactions to an MQTT 3.1.1 broker. The destination is
+// given as `mqtt://[user:pass@]host[:port]/<topic template>?qos=1&retain=true`,
+// connections are shared by every hook that targets the same broker.
+type MQTTShooter struct {
+	Formatter *Formatter
+	Policy    *DestinationPolicy
+	mutex     sync.Mutex
+	conns     map[string]*mqttConn
+}
+
+func (s *MQTTShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	qos := 0
+	if v := u.Query().Get("qos"); v != "" {
+		if qos, err = strconv.Atoi(v); err != nil || qos < 0 || qos > 2 {
+			return fmt.Errorf("invalid mqtt qos %q", v)
+		}
+	}
+
+	retain := false
+	if v := u.Query().Get("retain"); v != "" {
+		if retain, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid mqtt retain flag %q", v)
+		}
+	}
+
+	body, _, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	template := strings.TrimPrefix(u.Path, "/")
+	if template == "" {
+		template = defaultMQTTTopic
+	}
+
+	topic := ExpandTopic(template, r, mqttTopicEscaper.Replace)
+	conn, err := s.conn(u)
+	if err != nil {
+		return fmt.Errorf("error connecting to mqtt broker: %v", err)
+	}
+
+	if err = conn.publish(topic, body, byte(qos), retain); err != nil {
+		s.drop(u, conn)
+		if conn, err = s.conn(u); err == nil {
+			err = conn.publish(topic, body, byte(qos), retain)
+		}
+	}
+
+	if err != nil {
+		s.drop(u, conn)
+		return fmt.Errorf("error publishing to mqtt topic %q: %v", topic, err)
+	}
+
+	return nil
+}
+
+func mqttPoolKey(u *url.URL) string {
+	return u.User.String() + "@" + u.Host
+}
+
+func (s *MQTTShooter) conn(u *url.URL) (*mqttConn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := mqttPoolKey(u)
+	if conn, ok := s.conns[key]; ok {
+		return conn, nil
+	}
+
+	conn, err := dialMQTT(s.Policy.Dialer(brokerDialTimeout), u)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.conns == nil {
+		s.conns = make(map[string]*mqttConn)
+	}
+
+	s.conns[key] = conn
+	return conn, nil
+}
+
+func (s *MQTTShooter) drop(u *url.URL, conn *mqttConn) {
+	if conn == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := mqttPoolKey(u)
+	if s.conns[key] == conn {
+		delete(s.conns, key)
+	}
+
+	conn.Close()
+}
+
+type mqttConn struct {
+	net.Conn
+	mutex    sync.Mutex
+	reader   *bufio.Reader
+	packetID uint16
+}
+
+func dialMQTT(d *net.Dialer, u *url.URL) (*mqttConn, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultMQTTPort)
+	}
+
+	c, err := d.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &mqttConn{
+		Conn:   c,
+		reader: bufio.NewReader(c),
+	}
+
+	// clean session with keep alive disabled so idle pooled connections aren't
+	// dropped by the broker
+	var flags byte = 0x02
+	payload := mqttString(nil, "csense-"+strings.Replace(uuid.Generate(), "-", "", -1)[:16])
+	if u.User != nil {
+		flags |= 0x80
+		payload = mqttString(payload, u.User.Username())
+		if pass, ok := u.User.Password(); ok {
+			flags |= 0x40
+			payload = mqttString(payload, pass)
+		}
+	}
+
+	packet := mqttString(nil, "MQTT")
+	packet = append(packet, 0x04, flags, 0x00, 0x00)
+	packet = append(packet, payload...)
+
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout))
+	if err = conn.writePacket(mqttConnect, packet); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	kind, body, err := conn.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	} else if kind != mqttConnack || len(body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected packet type %#x", kind)
+	} else if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused with code %d", body[1])
+	}
+
+	return conn, nil
+}
+
+func (conn *mqttConn) publish(topic string, body []byte, qos byte, retain bool) error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	header := byte(mqttPublish) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+
+	packet := mqttString(nil, topic)
+	var id uint16
+	if qos > 0 {
+		conn.packetID++
+		if conn.packetID == 0 {
+			conn.packetID = 1
+		}
+
+		id = conn.packetID
+		packet = append(packet, byte(id>>8), byte(id))
+	}
+
+	packet = append(packet, body...)
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout))
+	if err := conn.writePacket(header, packet); err != nil {
+		return err
+	}
+
+	switch qos {
+	case 1:
+		return conn.waitForAck(mqttPuback, id)
+	case 2:
+		if err := conn.waitForAck(mqttPubrec, id); err != nil {
+			return err
+		}
+
+		if err := conn.writePacket(mqttPubrel, []byte{byte(id >> 8), byte(id)}); err != nil {
+			return err
+		}
+
+		return conn.waitForAck(mqttPubcomp, id)
+	}
+
+	return nil
+}
+
+func (conn *mqttConn) waitForAck(kind byte, id uint16) error {
+	for {
+		k, body, err := conn.readPacket()
+		if err != nil {
+			return err
+		}
+
+		if k&0xF0 == kind&0xF0 && len(body) >= 2 && binary.BigEndian.Uint16(body) == id {
+			return nil
+		}
+	}
+}
+
+func (conn *mqttConn) writePacket(header byte, body []byte) error {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+
+	_, err := conn.Write(append(packet, body...))
+	return err
+}
+
+func (conn *mqttConn) readPacket() (byte, []byte, error) {
+	header, err := conn.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	for multiplier := 1; ; multiplier *= 128 {
+		if multiplier > 128*128*128 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+
+		b, err := conn.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(conn.reader, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header & 0xF0, body, nil
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
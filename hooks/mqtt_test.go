@@ -0,0 +1,283 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mqttConnectPacket struct {
+	protocol string
+	level    byte
+	flags    byte
+	clientID string
+	username string
+	password string
+}
+
+type mqttPublishPacket struct {
+	header  byte
+	topic   string
+	id      uint16
+	payload string
+}
+
+// fakeMQTTBroker speaks just enough MQTT 3.1.1 for the shooter, written
+// against the specification.
+type fakeMQTTBroker struct {
+	l net.Listener
+	// CONNACK return code
+	refuse byte
+	// close the connection instead of acknowledging QoS 1 messages
+	dropAcks bool
+	mu       sync.Mutex
+	connects []mqttConnectPacket
+	publish  []mqttPublishPacket
+}
+
+func newFakeMQTTBroker(t *testing.T) *fakeMQTTBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &fakeMQTTBroker{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go b.serve(t, c)
+		}
+	}()
+
+	return b
+}
+
+func (b *fakeMQTTBroker) Close() {
+	b.l.Close()
+}
+
+func (b *fakeMQTTBroker) url(userinfo string, path string) string {
+	if userinfo != "" {
+		userinfo += "@"
+	}
+
+	return "mqtt://" + userinfo + b.l.Addr().String() + path
+}
+
+// readMQTTPacket reads a fixed header and the packet's remaining bytes.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+// readString reads a length prefixed string and returns the rest.
+func readMQTTString(t *testing.T, b []byte) (string, []byte) {
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		t.Fatalf("truncated string in %x", b)
+	}
+
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func (b *fakeMQTTBroker) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	header, body, err := readMQTTPacket(r)
+	if err != nil {
+		return
+	} else if header != 0x10 {
+		t.Errorf("got packet %#x before CONNECT", header)
+		return
+	}
+
+	p := mqttConnectPacket{}
+	p.protocol, body = readMQTTString(t, body)
+	p.level, p.flags = body[0], body[1]
+	if keepAlive := binary.BigEndian.Uint16(body[2:]); keepAlive != 0 {
+		t.Errorf("got keep alive %d", keepAlive)
+	}
+
+	p.clientID, body = readMQTTString(t, body[4:])
+	if p.flags&0x80 != 0 {
+		p.username, body = readMQTTString(t, body)
+	}
+
+	if p.flags&0x40 != 0 {
+		p.password, body = readMQTTString(t, body)
+	}
+
+	if len(body) != 0 {
+		t.Errorf("got %d bytes after the CONNECT payload", len(body))
+	}
+
+	b.mu.Lock()
+	b.connects = append(b.connects, p)
+	b.mu.Unlock()
+	c.Write([]byte{0x20, 2, 0, b.refuse})
+	if b.refuse != 0 {
+		return
+	}
+
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		} else if header&0xf0 != 0x30 {
+			t.Errorf("got packet %#x", header)
+			return
+		}
+
+		p := mqttPublishPacket{header: header}
+		p.topic, body = readMQTTString(t, body)
+		qos := header >> 1 & 3
+		if qos > 0 {
+			p.id, body = binary.BigEndian.Uint16(body), body[2:]
+		}
+
+		p.payload = string(body)
+		b.mu.Lock()
+		b.publish = append(b.publish, p)
+		b.mu.Unlock()
+		if qos == 1 {
+			if b.dropAcks {
+				return
+			}
+
+			// an acknowledgement of another packet comes first
+			other := p.id + 1
+			c.Write([]byte{0x40, 2, byte(other >> 8), byte(other)})
+			c.Write([]byte{0x40, 2, byte(p.id >> 8), byte(p.id)})
+		}
+	}
+}
+
+// waitPublished returns the messages once n were read, a QoS 0 message is
+// read after it's sent.
+func (b *fakeMQTTBroker) waitPublished(t *testing.T, n int) []mqttPublishPacket {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		b.mu.Lock()
+		published := append([]mqttPublishPacket{}, b.publish...)
+		b.mu.Unlock()
+		if len(published) >= n {
+			return published
+		} else if time.Now().After(deadline) {
+			t.Fatalf("got %d of %d messages", len(published), n)
+		}
+	}
+}
+
+func TestMQTTShooterPublishes(t *testing.T) {
+	broker := newFakeMQTTBroker(t)
+	defer broker.Close()
+
+	s := &MQTTShooter{Formatter: &Formatter{}}
+	url := broker.url("csense:secret", "/events/{host}/{container}?qos=1&retain=true")
+	names := []string{"web", "db/primary"}
+	for _, name := range names {
+		if err := s.Fire(context.Background(), brokerReaction(url, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.connects) != 1 {
+		t.Fatalf("got %d connections, they're shared", len(broker.connects))
+	}
+
+	c := broker.connects[0]
+	if c.protocol != "MQTT" || c.level != 4 || c.flags != 0xc2 {
+		t.Errorf("got protocol %q level %d with flags %#x", c.protocol, c.level, c.flags)
+	}
+
+	if c.username != "csense" || c.password != "secret" || !strings.HasPrefix(c.clientID, "csense-") {
+		t.Errorf("got client %q as %q:%q", c.clientID, c.username, c.password)
+	}
+
+	topics := []string{"events/node1/web", "events/node1/db_primary"}
+	if len(broker.publish) != len(topics) {
+		t.Fatalf("got %d messages", len(broker.publish))
+	}
+
+	for i, p := range broker.publish {
+		// QoS 1 and retained
+		if p.header != 0x33 || p.topic != topics[i] || p.id != uint16(i+1) {
+			t.Errorf("got header %#x, topic %q and packet id %d", p.header, p.topic, p.id)
+		}
+
+		if !strings.Contains(p.payload, `"name":"`+names[i]+`"`) {
+			t.Errorf("got payload %q", p.payload)
+		}
+	}
+}
+
+func TestMQTTShooterWaitsForAcks(t *testing.T) {
+	broker := newFakeMQTTBroker(t)
+	defer broker.Close()
+
+	broker.dropAcks = true
+	s := &MQTTShooter{Formatter: &Formatter{}}
+	if err := s.Fire(context.Background(), brokerReaction(broker.url("", "/events?qos=1"), "web")); err == nil {
+		t.Error("QoS 1 message never acknowledged was delivered")
+	}
+
+	// QoS 0 isn't acknowledged
+	if err := s.Fire(context.Background(), brokerReaction(broker.url("", "/events"), "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := broker.waitPublished(t, 3)[2]
+	broker.mu.Lock()
+	connect := broker.connects[0]
+	broker.mu.Unlock()
+	if p.header != 0x30 || p.id != 0 {
+		t.Errorf("got header %#x and packet id %d", p.header, p.id)
+	}
+
+	if connect.flags != 0x02 {
+		t.Errorf("got flags %#x without credentials", connect.flags)
+	}
+}
+
+func TestMQTTShooterRefused(t *testing.T) {
+	broker := newFakeMQTTBroker(t)
+	defer broker.Close()
+
+	broker.refuse = 5
+	s := &MQTTShooter{Formatter: &Formatter{}}
+	err := s.Fire(context.Background(), brokerReaction(broker.url("csense:wrong", "/events"), "web"))
+	if err == nil || !strings.Contains(err.Error(), "code 5") {
+		t.Errorf("got %v from a broker refusing the connection", err)
+	}
+}
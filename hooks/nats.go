@@ -0,0 +1,239 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	defaultNATSPort    = "4222"
+	defaultNATSSubject = "csense.{host}.{event}"
+	brokerDialTimeout  = 10 * time.Second
+	brokerIOTimeout    = 10 * time.Second
+)
+
+var natsSubjectEscaper = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_", "\t", "_")
+
+// NATSShooter publishes reactions to a NATS server. The destination is given
+// as `nats://[user:pass@]host[:port]/<subject template>`, connections are
+// shared by every hook that targets the same server.
+type NATSShooter struct {
+	Formatter *Formatter
+	Policy    *DestinationPolicy
+	mutex     sync.Mutex
+	conns     map[string]*natsConn
+}
+
+func (s *NATSShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	body, _, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	template := strings.TrimPrefix(u.Path, "/")
+	if template == "" {
+		template = defaultNATSSubject
+	}
+
+	subject := ExpandTopic(template, r, natsSubjectEscaper.Replace)
+	conn, err := s.conn(u)
+	if err != nil {
+		return fmt.Errorf("error connecting to nats server: %v", err)
+	}
+
+	if err = conn.publish(subject, body); err != nil {
+		s.drop(u, conn)
+		if conn, err = s.conn(u); err == nil {
+			err = conn.publish(subject, body)
+		}
+	}
+
+	if err != nil {
+		s.drop(u, conn)
+		return fmt.Errorf("error publishing to nats subject %q: %v", subject, err)
+	}
+
+	return nil
+}
+
+func natsPoolKey(u *url.URL) string {
+	return u.User.String() + "@" + u.Host
+}
+
+func (s *NATSShooter) conn(u *url.URL) (*natsConn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := natsPoolKey(u)
+	if conn, ok := s.conns[key]; ok {
+		return conn, nil
+	}
+
+	conn, err := dialNATS(s.Policy.Dialer(brokerDialTimeout), u)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.conns == nil {
+		s.conns = make(map[string]*natsConn)
+	}
+
+	s.conns[key] = conn
+	return conn, nil
+}
+
+func (s *NATSShooter) drop(u *url.URL, conn *natsConn) {
+	if conn == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := natsPoolKey(u)
+	if s.conns[key] == conn {
+		delete(s.conns, key)
+	}
+
+	conn.Close()
+}
+
+type natsConn struct {
+	net.Conn
+	mutex  sync.Mutex
+	reader *bufio.Reader
+}
+
+type natsConnectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+func dialNATS(d *net.Dialer, u *url.URL) (*natsConn, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultNATSPort)
+	}
+
+	c, err := d.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &natsConn{
+		Conn:   c,
+		reader: bufio.NewReader(c),
+	}
+
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout))
+	line, err := conn.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	} else if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected server greeting %q", line)
+	}
+
+	opts := &natsConnectOptions{
+		Name:     "csense",
+		Lang:     "go",
+		Version:  "1.0.0",
+		Protocol: 1,
+	}
+
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts.User = u.User.Username()
+			opts.Pass = pass
+		} else {
+			opts.AuthToken = u.User.Username()
+		}
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", data); err == nil {
+		err = conn.waitForPong()
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (conn *natsConn) readLine() (string, error) {
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (conn *natsConn) waitForPong() error {
+	for {
+		line, err := conn.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// publish sends the message followed by a PING so that the PONG confirms the
+// server processed it.
+func (conn *natsConn) publish(subject string, body []byte) error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout))
+	msg := make([]byte, 0, len(subject)+len(body)+32)
+	msg = append(msg, fmt.Sprintf("PUB %s %d\r\n", subject, len(body))...)
+	msg = append(msg, body...)
+	msg = append(msg, "\r\nPING\r\n"...)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+
+	return conn.waitForPong()
+}
@@ -0,0 +1,213 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func brokerReaction(url string, container string) *v1.Reaction {
+	return &v1.Reaction{
+		Hook:      &v1.Hook{ID: "h1", Url: url, Format: v1.FormatJSON},
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: container, State: v1.StateRunning},
+	}
+}
+
+type natsMessage struct {
+	subject string
+	body    string
+}
+
+// fakeNATSServer speaks the client side of the NATS text protocol, written
+// against the protocol reference.
+type fakeNATSServer struct {
+	l net.Listener
+	// error sent instead of the PONG to a CONNECT
+	authError string
+	mu        sync.Mutex
+	connects  []*natsConnectOptions
+	messages  []natsMessage
+}
+
+func newFakeNATSServer(t *testing.T) *fakeNATSServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeNATSServer{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(t, c)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeNATSServer) Close() {
+	s.l.Close()
+}
+
+func (s *fakeNATSServer) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	fmt.Fprint(c, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"max_payload\":1048576}\r\n")
+	r := bufio.NewReader(c)
+	connected := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		if !strings.HasSuffix(line, "\r\n") {
+			t.Errorf("line not ended by CRLF: %q", line)
+			return
+		}
+
+		line = strings.TrimSuffix(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			opts := &natsConnectOptions{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), opts); err != nil {
+				t.Errorf("bad CONNECT %q: %v", line, err)
+				return
+			}
+
+			s.mu.Lock()
+			s.connects = append(s.connects, opts)
+			s.mu.Unlock()
+			connected = true
+		case line == "PING":
+			if !connected {
+				t.Error("PING before CONNECT")
+				return
+			} else if s.authError != "" {
+				fmt.Fprintf(c, "-ERR '%s'\r\n", s.authError)
+				return
+			}
+
+			fmt.Fprint(c, "PONG\r\n")
+		case strings.HasPrefix(line, "PUB "):
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				t.Errorf("bad PUB %q", line)
+				return
+			}
+
+			n, err := strconv.Atoi(fields[2])
+			if err != nil {
+				t.Errorf("bad PUB size %q", line)
+				return
+			}
+
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			} else if string(payload[n:]) != "\r\n" {
+				t.Errorf("payload of %d bytes not ended by CRLF: %q", n, payload)
+				return
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, natsMessage{fields[1], string(payload[:n])})
+			s.mu.Unlock()
+		default:
+			t.Errorf("unexpected line %q", line)
+			return
+		}
+	}
+}
+
+func (s *fakeNATSServer) url(userinfo string, path string) string {
+	if userinfo != "" {
+		userinfo += "@"
+	}
+
+	return "nats://" + userinfo + s.l.Addr().String() + path
+}
+
+func TestNATSShooterPublishes(t *testing.T) {
+	server := newFakeNATSServer(t)
+	defer server.Close()
+
+	s := &NATSShooter{Formatter: &Formatter{}}
+	url := server.url("csense:secret", "/events.{host}.{container}")
+	for _, name := range []string{"web", "db.primary"} {
+		if err := s.Fire(context.Background(), brokerReaction(url, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.connects) != 1 {
+		t.Fatalf("got %d connections, they're shared", len(server.connects))
+	}
+
+	c := server.connects[0]
+	if c.User != "csense" || c.Pass != "secret" || c.AuthToken != "" || c.Verbose {
+		t.Errorf("got connect options %+v", c)
+	}
+
+	subjects := []string{"events.node1.web", "events.node1.db_primary"}
+	if len(server.messages) != len(subjects) {
+		t.Fatalf("got %d messages", len(server.messages))
+	}
+
+	for i, m := range server.messages {
+		if m.subject != subjects[i] {
+			t.Errorf("got subject %q, want %q", m.subject, subjects[i])
+		}
+
+		reaction := &v1.Reaction{}
+		if err := json.Unmarshal([]byte(m.body), reaction); err != nil {
+			t.Errorf("got body %q: %v", m.body, err)
+		}
+	}
+}
+
+func TestNATSShooterAuth(t *testing.T) {
+	server := newFakeNATSServer(t)
+	defer server.Close()
+
+	s := &NATSShooter{Formatter: &Formatter{}}
+	if err := s.Fire(context.Background(), brokerReaction(server.url("t0ken", ""), "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	c := server.connects[0]
+	subject := server.messages[0].subject
+	server.mu.Unlock()
+	if c.AuthToken != "t0ken" || c.User != "" || c.Pass != "" {
+		t.Errorf("got connect options %+v", c)
+	}
+
+	if subject != "csense.node1.create" {
+		t.Errorf("got default subject %q", subject)
+	}
+
+	denied := newFakeNATSServer(t)
+	defer denied.Close()
+	denied.authError = "Authorization Violation"
+	err := s.Fire(context.Background(), brokerReaction(denied.url("csense:wrong", ""), "web"))
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("got %v from a server denying the connection", err)
+	}
+}
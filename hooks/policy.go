@@ -0,0 +1,291 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/danielkrainas/csense/configuration"
+)
+
+// DestinationError is returned when a hook destination is rejected by the
+// DestinationPolicy.
+type DestinationError struct {
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+}
+
+func (err *DestinationError) Error() string {
+	return fmt.Sprintf("destination %q denied: %s", err.Destination, err.Reason)
+}
+
+// DestinationPolicy decides which schemes, hosts and addresses hooks may
+// deliver to. Urls are checked when hooks are stored and before delivery,
+// resolved addresses are checked again at dial time to defeat DNS rebinding.
+type DestinationPolicy struct {
+	AllowedSchemes []string
+	DeniedSchemes  []string
+	AllowedHosts   []string
+	DeniedHosts    []string
+	AllowedCIDRs   []*net.IPNet
+	DeniedCIDRs    []*net.IPNet
+	AllowPrivate   bool
+}
+
+func PolicyFromConfig(config configuration.DestinationsConfig) (*DestinationPolicy, error) {
+	policy := &DestinationPolicy{
+		AllowedSchemes: config.AllowedSchemes,
+		DeniedSchemes:  config.DeniedSchemes,
+		AllowedHosts:   config.AllowedHosts,
+		DeniedHosts:    config.DeniedHosts,
+		AllowPrivate:   config.AllowPrivate,
+	}
+
+	var err error
+	if policy.AllowedCIDRs, err = parseCIDRs(config.AllowedCIDRs); err != nil {
+		return nil, err
+	}
+
+	if policy.DeniedCIDRs, err = parseCIDRs(config.DeniedCIDRs); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %v", v, err)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// CheckURL validates the scheme and host of a destination url along with its
+// address when the host is an IP literal.
+func (policy *DestinationPolicy) CheckURL(raw string) error {
+	deny := func(reason string, args ...interface{}) error {
+		return &DestinationError{
+			Destination: raw,
+			Reason:      fmt.Sprintf(reason, args...),
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return deny("invalid url: %v", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" {
+		return deny("missing scheme")
+	} else if containsFold(policy.DeniedSchemes, scheme) {
+		return deny("scheme %q is denied", scheme)
+	} else if len(policy.AllowedSchemes) > 0 && !containsFold(policy.AllowedSchemes, scheme) {
+		return deny("scheme %q is not allowed", scheme)
+	}
+
+	return policy.checkHost(raw, u.Hostname())
+}
+
+// CheckProxy validates a proxy url set on a hook. Its host has to pass the
+// same checks as a destination, proxies from the configuration are trusted.
+func (policy *DestinationPolicy) CheckProxy(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return &DestinationError{Destination: raw, Reason: fmt.Sprintf("invalid proxy url: %v", err)}
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5":
+	default:
+		return &DestinationError{Destination: raw, Reason: fmt.Sprintf("unsupported proxy scheme %q", u.Scheme)}
+	}
+
+	if u.Hostname() == "" {
+		return &DestinationError{Destination: raw, Reason: "missing proxy host"}
+	}
+
+	return policy.checkHost(raw, u.Hostname())
+}
+
+func (policy *DestinationPolicy) checkHost(raw string, host string) error {
+	deny := func(reason string, args ...interface{}) error {
+		return &DestinationError{
+			Destination: raw,
+			Reason:      fmt.Sprintf(reason, args...),
+		}
+	}
+
+	host = strings.ToLower(host)
+	if host == "" {
+		return nil
+	}
+
+	if matchesHost(policy.DeniedHosts, host) {
+		return deny("host %q is denied", host)
+	} else if len(policy.AllowedHosts) > 0 && !matchesHost(policy.AllowedHosts, host) {
+		return deny("host %q is not allowed", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if reason := policy.checkIP(ip); reason != "" {
+			return deny("%s", reason)
+		}
+	} else if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		if reason := policy.checkIP(net.IPv4(127, 0, 0, 1)); reason != "" {
+			return deny("%s", reason)
+		}
+	}
+
+	return nil
+}
+
+func (policy *DestinationPolicy) checkIP(ip net.IP) string {
+	for _, n := range policy.DeniedCIDRs {
+		if n.Contains(ip) {
+			return fmt.Sprintf("address %s is denied", ip)
+		}
+	}
+
+	for _, n := range policy.AllowedCIDRs {
+		if n.Contains(ip) {
+			return ""
+		}
+	}
+
+	if len(policy.AllowedCIDRs) > 0 {
+		return fmt.Sprintf("address %s is not allowed", ip)
+	}
+
+	if !policy.AllowPrivate && isPrivateIP(ip) {
+		return fmt.Sprintf("private address %s is not allowed", ip)
+	}
+
+	return ""
+}
+
+// CheckAddresses resolves host and checks every address it resolves to. It is
+// used for requests sent through a proxy, the proxy resolves the destination
+// so the dial time check only ever sees the proxy's address.
+func (policy *DestinationPolicy) CheckAddresses(ctx context.Context, host string) error {
+	if policy == nil {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if reason := policy.checkIP(ip); reason != "" {
+			return &DestinationError{Destination: host, Reason: reason}
+		}
+
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if reason := policy.checkIP(addr.IP); reason != "" {
+			return &DestinationError{Destination: host, Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+// Control is used as the net.Dialer control function so that every resolved
+// address is checked right before the connection is made.
+func (policy *DestinationPolicy) Control(network string, address string, c syscall.RawConn) error {
+	if strings.HasPrefix(network, "unix") {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &DestinationError{Destination: address, Reason: "unresolved address"}
+	}
+
+	if reason := policy.checkIP(ip); reason != "" {
+		return &DestinationError{Destination: address, Reason: reason}
+	}
+
+	return nil
+}
+
+// Dialer returns a dialer that enforces the policy on resolved addresses.
+func (policy *DestinationPolicy) Dialer(timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if policy != nil {
+		d.Control = policy.Control
+	}
+
+	return d
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+var _, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+
+func containsFold(values []string, v string) bool {
+	for _, x := range values {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesHost reports if host matches one of the patterns, a pattern is either
+// an exact hostname or a `*.` prefixed domain suffix.
+func matchesHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if strings.HasPrefix(p, "*.") {
+			if strings.HasSuffix(host, p[1:]) {
+				return true
+			}
+		} else if p == host {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package hooks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const defaultRetryBackoff = time.Second
+
+// Bounds of retry policies, the doubled delay stops growing at
+// MaxRetryBackoff.
+const (
+	MaxRetryAttempts = 10
+	MaxRetryBackoff  = 5 * time.Minute
+)
+
+// ParseRetry returns the number of attempts and the initial delay between
+// them for a retry policy. Without a policy a delivery is attempted once.
+func ParseRetry(policy *v1.RetryPolicy) (int, time.Duration, error) {
+	if policy == nil {
+		return 1, 0, nil
+	}
+
+	if policy.Attempts < 0 {
+		return 0, 0, fmt.Errorf("retry attempts must not be negative")
+	} else if policy.Attempts > MaxRetryAttempts {
+		return 0, 0, fmt.Errorf("retry attempts must not exceed %d", MaxRetryAttempts)
+	}
+
+	attempts := policy.Attempts
+	if attempts == 0 {
+		attempts = 1
+	}
+
+	backoff := defaultRetryBackoff
+	if policy.Backoff != "" {
+		var err error
+		if backoff, err = time.ParseDuration(policy.Backoff); err != nil {
+			return 0, 0, fmt.Errorf("invalid retry backoff %q: %v", policy.Backoff, err)
+		} else if backoff < 0 {
+			return 0, 0, fmt.Errorf("retry backoff must not be negative")
+		} else if backoff > MaxRetryBackoff {
+			return 0, 0, fmt.Errorf("retry backoff must not exceed %v", MaxRetryBackoff)
+		}
+	}
+
+	return attempts, backoff, nil
+}
+
+// NextBackoff doubles the delay between attempts up to MaxRetryBackoff.
+func NextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > MaxRetryBackoff {
+		return MaxRetryBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,36 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// Router dispatches reactions to the shooter registered for the scheme of the
+// hook's url.
+type Router struct {
+	Shooters map[string]Shooter
+	Policy   *DestinationPolicy
+}
+
+func (router *Router) Fire(ctx context.Context, r *v1.Reaction) error {
+	if router.Policy != nil {
+		if err := router.Policy.CheckURL(r.Hook.Url); err != nil {
+			return err
+		}
+	}
+
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	s, ok := router.Shooters[u.Scheme]
+	if !ok {
+		return fmt.Errorf("hook url scheme %q unsupported", u.Scheme)
+	}
+
+	return s.Fire(ctx, r)
+}
@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+type schemeShooter struct {
+	fired []string
+}
+
+func (s *schemeShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	s.fired = append(s.fired, r.Hook.Url)
+	return nil
+}
+
+func TestRouterSchemes(t *testing.T) {
+	shooter := &schemeShooter{}
+	router := &Router{Shooters: map[string]Shooter{"nats": shooter}}
+	if err := router.Fire(context.Background(), brokerReaction("nats://nats.example.com/events", "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{"amqp://broker.example.com/events", "nats.example.com/events", "://"} {
+		err := router.Fire(context.Background(), brokerReaction(url, "web"))
+		if err == nil {
+			t.Errorf("%s: routed", url)
+		}
+	}
+
+	err := router.Fire(context.Background(), brokerReaction("amqp://broker.example.com/events", "web"))
+	if err == nil || !strings.Contains(err.Error(), `"amqp" unsupported`) {
+		t.Errorf("got %v for an unknown scheme", err)
+	}
+
+	if len(shooter.fired) != 1 {
+		t.Errorf("got %d reactions fired", len(shooter.fired))
+	}
+}
+
+func TestRouterChecksPolicy(t *testing.T) {
+	shooter := &schemeShooter{}
+	router := &Router{Shooters: map[string]Shooter{"nats": shooter}, Policy: &DestinationPolicy{}}
+	if err := router.Fire(context.Background(), brokerReaction("nats://127.0.0.1:4222/events", "web")); err == nil {
+		t.Error("routed to a private destination")
+	}
+
+	if len(shooter.fired) != 0 {
+		t.Errorf("got %d reactions fired", len(shooter.fired))
+	}
+}
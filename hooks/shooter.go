@@ -11,6 +11,26 @@ import (
 	"github.com/danielkrainas/gobag/context"
 )
 
+// Headers sent with http deliveries so receivers can deduplicate retries and
+// detect gaps in the sequence.
+const (
+	DeliveryHeader = "X-Csense-Delivery"
+	SequenceHeader = "X-Csense-Sequence"
+	EventHeader    = "X-Csense-Event"
+)
+
+// SetAuth adds the credentials to an http request, basic auth when a username
+// is set and a bearer token otherwise.
+func SetAuth(req *http.Request, auth *v1.AuthConfig) {
+	if auth == nil {
+		return
+	} else if auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	} else if auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	}
+}
+
 type Shooter interface {
 	Fire(ctx context.Context, r *v1.Reaction) error
 }
@@ -23,35 +43,38 @@ func (s *MockShooter) Fire(ctx context.Context, r *v1.Reaction) error {
 }
 
 type LiveShooter struct {
-	HttpClient *http.Client
+	Clients   *ClientCache
+	Formatter *Formatter
 }
 
 func (s *LiveShooter) Fire(ctx context.Context, r *v1.Reaction) error {
-	var body []byte
-	var err error
-	var bodyType string
-
-	switch r.Hook.Format {
-	case v1.FormatJSON:
-		body, bodyType, err = formatting.JSON(r)
-	case v1.FormatSlackJSON:
-		body, bodyType, err = formatting.Slack(r)
-	default:
-		return fmt.Errorf("body format %q unsupported", r.Hook.Format)
-	}
-
+	body, bodyType, err := s.Formatter.Format(r)
 	if err != nil {
 		return fmt.Errorf("error formatting body: %v", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, r.Hook.Url, bytes.NewReader(body))
+	destUrl := r.Hook.Url
+	if r.Hook.Format == v1.FormatAlertmanager {
+		destUrl = formatting.AlertmanagerURL(destUrl)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, destUrl, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", bodyType)
 	req.Header.Set("Content-Length", fmt.Sprint(len(body)))
-	resp, err := s.HttpClient.Do(req)
+	req.Header.Set(DeliveryHeader, r.ID)
+	req.Header.Set(SequenceHeader, fmt.Sprint(r.Sequence))
+	req.Header.Set(EventHeader, string(r.Event()))
+	SetAuth(req, r.Hook.Auth)
+	client, err := s.Clients.Get(r.Hook)
+	if err != nil {
+		return fmt.Errorf("error configuring http client: %v", err)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("couldn't execute request: %v", err)
 	}
@@ -61,5 +84,6 @@ func (s *LiveShooter) Fire(ctx context.Context, r *v1.Reaction) error {
 		return fmt.Errorf("unexpected response status for hook shot: %d", resp.StatusCode)
 	}
 
+	s.Formatter.Delivered(r)
 	return nil
 }
@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// ValidateSilence checks that a silence can be matched against reactions.
+func ValidateSilence(s *v1.Silence) error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("at least one matcher is required")
+	} else if s.EndsAt <= s.StartsAt {
+		return fmt.Errorf("silence must end after it starts")
+	}
+
+	for _, m := range s.Matchers {
+		if !knownMatcherField(m.Field) {
+			return fmt.Errorf("unknown matcher field %q", m.Field)
+		}
+
+		switch m.Op {
+		case v1.OperandEqual, v1.OperandEqualShort, v1.OperandNotEqual, v1.OperandNotEqualShort:
+		case v1.OperandMatch:
+			if _, err := regexp.Compile(m.Value); err != nil {
+				return fmt.Errorf("invalid matcher pattern %q: %v", m.Value, err)
+			}
+
+		default:
+			return fmt.Errorf("unknown matcher operand %q", m.Op)
+		}
+	}
+
+	return nil
+}
+
+// Silenced returns the first silence active at t that matches the reaction.
+func Silenced(silences []*v1.Silence, r *v1.Reaction, t time.Time) *v1.Silence {
+	for _, s := range silences {
+		if s.StatusAt(t) == v1.SilenceActive && matchesSilence(s, r) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func matchesSilence(s *v1.Silence, r *v1.Reaction) bool {
+	for _, m := range s.Matchers {
+		cond := &v1.Condition{Op: m.Op, Value: m.Value}
+		if !isValidAny(cond, matcherValues(m.Field, r)) {
+			return false
+		}
+	}
+
+	return len(s.Matchers) > 0
+}
+
+func knownMatcherField(field string) bool {
+	switch field {
+	case v1.MatcherFieldHost, v1.MatcherFieldHookID:
+		return true
+	}
+
+	for _, prefix := range []string{v1.MatcherLabelPrefix, v1.MatcherAnnotationPrefix} {
+		if strings.HasPrefix(field, prefix) {
+			return len(field) > len(prefix)
+		}
+	}
+
+	return containerFields[v1.ContainerField(field)]
+}
+
+// matcherValues returns the reaction's values for a matcher field, the
+// container's ones are those criteria match.
+func matcherValues(field string, r *v1.Reaction) []string {
+	switch field {
+	case v1.MatcherFieldHost:
+		return []string{r.Host.Hostname}
+	case v1.MatcherFieldHookID:
+		return []string{r.Hook.ID}
+	}
+
+	if strings.HasPrefix(field, v1.MatcherLabelPrefix) {
+		return []string{r.Container.Labels[strings.TrimPrefix(field, v1.MatcherLabelPrefix)]}
+	} else if strings.HasPrefix(field, v1.MatcherAnnotationPrefix) {
+		return []string{r.Container.Annotations[strings.TrimPrefix(field, v1.MatcherAnnotationPrefix)]}
+	}
+
+	return FieldValues(r.Container, v1.ContainerField(field))
+}
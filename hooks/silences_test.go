@@ -0,0 +1,67 @@
+package hooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func TestSilencedMatchesCriteriaFields(t *testing.T) {
+	code := 137
+	r := &v1.Reaction{
+		Hook: &v1.Hook{ID: "h1"},
+		Host: &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{
+			Name:            "web",
+			Aliases:         []string{"k8s_web_default"},
+			ImageName:       "nginx",
+			ImageRegistry:   "docker.io",
+			ImageRepository: "library/nginx",
+			ExitCode:        &code,
+			Labels:          map[string]string{"team": "web"},
+			Annotations:     map[string]string{"io.kubernetes.pod.namespace": "prod"},
+			Ports:           []*v1.PortMapping{{ContainerPort: 80, Protocol: "tcp", HostPort: 8080, HostIP: "0.0.0.0"}},
+		},
+	}
+
+	now := time.Now()
+	silence := func(field string, op v1.Operand, value string) *v1.Silence {
+		return &v1.Silence{
+			ID:       field,
+			Matchers: []*v1.Matcher{{Field: field, Op: op, Value: value}},
+			StartsAt: now.Add(-time.Minute).Unix(),
+			EndsAt:   now.Add(time.Minute).Unix(),
+		}
+	}
+
+	for _, c := range []struct {
+		silence *v1.Silence
+		matches bool
+	}{
+		{silence("name", v1.OperandEqual, "k8s_web_default"), true},
+		{silence("image_name", v1.OperandEqual, "docker.io/library/nginx"), true},
+		{silence("exit_code", v1.OperandEqual, "137"), true},
+		{silence("exit_code", v1.OperandEqual, "0"), false},
+		{silence("port", v1.OperandEqual, "80/tcp"), true},
+		{silence("label.team", v1.OperandEqual, "web"), true},
+		{silence("annotation.io.kubernetes.pod.namespace", v1.OperandEqual, "prod"), true},
+		{silence("annotation.io.kubernetes.pod.namespace", v1.OperandNotEqual, "prod"), false},
+		{silence("host", v1.OperandMatch, "^node"), true},
+		{silence("hook_id", v1.OperandEqual, "h2"), false},
+	} {
+		if err := ValidateSilence(c.silence); err != nil {
+			t.Errorf("%s: %v", c.silence.ID, err)
+		}
+
+		if matched := Silenced([]*v1.Silence{c.silence}, r, now) != nil; matched != c.matches {
+			t.Errorf("%s %s %q: got matched %v", c.silence.ID, c.silence.Matchers[0].Op, c.silence.Matchers[0].Value, matched)
+		}
+	}
+
+	for _, field := range []string{"colour", "label.", "annotation."} {
+		if err := ValidateSilence(silence(field, v1.OperandEqual, "x")); err == nil {
+			t.Errorf("%q: unknown field accepted", field)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/gobag/util/uuid"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/hooks/formatting"
+)
+
+const defaultEmailSubject = "Container {container} {state} on {host}"
+
+var headerEscaper = strings.NewReplacer("\r", " ", "\n", " ")
+
+// SMTPShooter emails reactions through an SMTP relay. The destination is given
+// as `mailto:ops@example.com,dev@example.com?subject=...&digest=5m`. The
+// subject accepts the same placeholders as broker topics and a digest window
+// combines the reactions of a hook within the window into a single message.
+type SMTPShooter struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+	StartTLS bool
+	mutex    sync.Mutex
+	digests  map[string]*emailDigest
+}
+
+type emailDigest struct {
+	reactions []*v1.Reaction
+	reports   []func(err error)
+}
+
+func (s *SMTPShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	if s.Addr == "" {
+		return fmt.Errorf("smtp relay not configured")
+	}
+
+	to, err := emailRecipients(u)
+	if err != nil {
+		return err
+	}
+
+	subject := u.Query().Get("subject")
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+
+	window := time.Duration(0)
+	if v := u.Query().Get("digest"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window < 0 {
+			return fmt.Errorf("invalid digest window %q", v)
+		}
+	}
+
+	if window == 0 {
+		return s.send(to, subject, []*v1.Reaction{r})
+	}
+
+	key := r.Hook.ID + "/" + strings.Join(to, ",")
+	if report := GetReport(ctx); report != nil {
+		s.enqueue(key, window, to, subject, r, report)
+		return ErrQueued
+	}
+
+	done := make(chan error, 1)
+	s.enqueue(key, window, to, subject, r, func(err error) {
+		done <- err
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func emailRecipients(u *url.URL) ([]string, error) {
+	raw := []string{u.Opaque}
+	raw = append(raw, u.Query()["to"]...)
+
+	to := make([]string, 0)
+	for _, list := range raw {
+		list, err := url.PathUnescape(list)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient list %q", list)
+		}
+
+		for _, addr := range strings.Split(list, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+	}
+
+	if len(to) == 0 {
+		return nil, fmt.Errorf("no email recipients specified")
+	}
+
+	return to, nil
+}
+
+// enqueue adds the reaction to the digest for key, the digest is sent when
+// the window of its first reaction ends and the result reported for each of
+// its reactions.
+func (s *SMTPShooter) enqueue(key string, window time.Duration, to []string, subject string, r *v1.Reaction, report func(err error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.digests == nil {
+		s.digests = make(map[string]*emailDigest)
+	}
+
+	d, ok := s.digests[key]
+	if !ok {
+		d = &emailDigest{}
+		s.digests[key] = d
+		time.AfterFunc(window, func() {
+			s.mutex.Lock()
+			delete(s.digests, key)
+			s.mutex.Unlock()
+
+			err := s.send(to, subject, d.reactions)
+			for _, report := range d.reports {
+				report(err)
+			}
+		})
+	}
+
+	d.reactions = append(d.reactions, r)
+	d.reports = append(d.reports, report)
+}
+
+func (s *SMTPShooter) send(to []string, subject string, reactions []*v1.Reaction) error {
+	msg, err := s.message(to, subject, reactions)
+	if err != nil {
+		return fmt.Errorf("error creating message: %v", err)
+	}
+
+	c, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("error connecting to smtp relay: %v", err)
+	}
+
+	defer c.Close()
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (s *SMTPShooter) dial() (*smtp.Client, error) {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", s.Addr, brokerDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(brokerIOTimeout * 3))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.StartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (s *SMTPShooter) message(to []string, subject string, reactions []*v1.Reaction) ([]byte, error) {
+	text, html, err := formatting.Email(reactions)
+	if err != nil {
+		return nil, err
+	}
+
+	subject = headerEscaper.Replace(ExpandTopic(subject, reactions[0], headerEscaper.Replace))
+	if len(reactions) > 1 {
+		subject = fmt.Sprintf("%s (+%d more)", subject, len(reactions)-1)
+	}
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "From: %s\r\n", headerEscaper.Replace(s.From))
+	fmt.Fprintf(buf, "To: %s\r\n", headerEscaper.Replace(strings.Join(to, ", ")))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@csense>\r\n", uuid.Generate())
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	}
+
+	for _, part := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write(part.body); err != nil {
+			return nil, err
+		}
+
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
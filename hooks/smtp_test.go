@@ -0,0 +1,190 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/hooks/smtptest"
+)
+
+func newRelay(t *testing.T) *smtptest.Relay {
+	relay, err := smtptest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return relay
+}
+
+func emailReaction(url string, container string) *v1.Reaction {
+	return &v1.Reaction{
+		Hook:      &v1.Hook{ID: "h1", Url: url},
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: container, ImageName: "nginx", State: v1.StateRunning},
+		Timestamp: 1700000000,
+	}
+}
+
+// parseEmail returns the decoded subject and the content types of the parts.
+func parseEmail(t *testing.T, data string) (*mail.Message, string, []string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := make([]string, 0)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		body, _ := ioutil.ReadAll(part)
+		if !strings.Contains(string(body), "web") {
+			t.Errorf("%s part doesn't mention the container: %s", part.Header.Get("Content-Type"), body)
+		}
+
+		types = append(types, part.Header.Get("Content-Type"))
+	}
+
+	return msg, subject, types
+}
+
+func TestSMTPShooterSends(t *testing.T) {
+	relay := newRelay(t)
+	defer relay.Close()
+
+	s := &SMTPShooter{Addr: relay.Addr(), From: "csense@example.com"}
+	url := "mailto:ops@example.com,dev@example.com?to=oncall@example.com&subject=" + "%7Bcontainer%7D+is+%7Bstate%7D"
+	if err := s.Fire(context.Background(), emailReaction(url, "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := relay.Emails()
+	if len(emails) != 1 {
+		t.Fatalf("got %d emails", len(emails))
+	}
+
+	email := emails[0]
+	if email.From != "csense@example.com" || strings.Join(email.To, ",") != "ops@example.com,dev@example.com,oncall@example.com" {
+		t.Errorf("got envelope from %q to %v", email.From, email.To)
+	}
+
+	msg, subject, types := parseEmail(t, email.Data)
+	if subject != "web is running" {
+		t.Errorf("got subject %q", subject)
+	}
+
+	if msg.Header.Get("To") != "ops@example.com, dev@example.com, oncall@example.com" {
+		t.Errorf("got To header %q", msg.Header.Get("To"))
+	}
+
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Errorf("got parts %v", types)
+	}
+}
+
+func TestSMTPShooterDigests(t *testing.T) {
+	relay := newRelay(t)
+	defer relay.Close()
+
+	s := &SMTPShooter{Addr: relay.Addr(), From: "csense@example.com"}
+	url := "mailto:ops@example.com?digest=50ms"
+	errs := make(chan error, 2)
+	for _, name := range []string{"web", "web-2"} {
+		go func(name string) {
+			errs <- s.Fire(context.Background(), emailReaction(url, name))
+		}(name)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("digest never sent")
+		}
+	}
+
+	emails := relay.Emails()
+	if len(emails) != 1 {
+		t.Fatalf("got %d emails", len(emails))
+	}
+
+	if _, subject, _ := parseEmail(t, emails[0].Data); !strings.HasSuffix(subject, "(+1 more)") {
+		t.Errorf("got subject %q", subject)
+	}
+}
+
+func TestSMTPShooterEscapesHeaders(t *testing.T) {
+	relay := newRelay(t)
+	defer relay.Close()
+
+	s := &SMTPShooter{Addr: relay.Addr(), From: "csense@example.com"}
+	r := emailReaction("mailto:ops@example.com?subject=%7Bcontainer%7D", "web\r\nBcc: victim@example.com")
+	if err := s.Fire(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := relay.Emails()
+	if len(emails) != 1 {
+		t.Fatalf("got %d emails", len(emails))
+	}
+
+	msg, _, _ := parseEmail(t, emails[0].Data)
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("got injected Bcc header %q", bcc)
+	}
+}
+
+func TestSMTPShooterReportsQueuedDigests(t *testing.T) {
+	relay := newRelay(t)
+	defer relay.Close()
+
+	s := &SMTPShooter{Addr: relay.Addr(), From: "csense@example.com"}
+	reports := make(chan error, 2)
+	ctx := WithReport(context.Background(), func(err error) {
+		reports <- err
+	})
+
+	for _, name := range []string{"web", "web-2"} {
+		if err := s.Fire(ctx, emailReaction("mailto:ops@example.com?digest=50ms", name)); err != ErrQueued {
+			t.Fatalf("got %v queueing %s", err, name)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-reports:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("digest never reported")
+		}
+	}
+
+	if emails := relay.Emails(); len(emails) != 1 {
+		t.Errorf("got %d emails", len(emails))
+	}
+}
@@ -0,0 +1,117 @@
+// Package smtptest is an SMTP relay to test email destinations against. It
+// accepts every message and speaks just enough SMTP for net/smtp, without
+// extensions.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Email is a message received by the relay.
+type Email struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Relay listens on a local port until it's closed.
+type Relay struct {
+	l        net.Listener
+	mu       sync.Mutex
+	received []Email
+}
+
+func New() (*Relay, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	relay := &Relay{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go relay.serve(c)
+		}
+	}()
+
+	return relay, nil
+}
+
+// Addr returns the host and port the relay listens on.
+func (relay *Relay) Addr() string {
+	return relay.l.Addr().String()
+}
+
+func (relay *Relay) Close() error {
+	return relay.l.Close()
+}
+
+// Emails returns the messages received so far.
+func (relay *Relay) Emails() []Email {
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	return append([]Email{}, relay.received...)
+}
+
+func (relay *Relay) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(line string) {
+		c.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 relay ready")
+	var email Email
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 relay")
+		case "MAIL":
+			email = Email{From: strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")}
+			reply("250 OK")
+		case "RCPT":
+			email.To = append(email.To, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 end with .")
+			data := &strings.Builder{}
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+
+			email.Data = data.String()
+			relay.mu.Lock()
+			relay.received = append(relay.received, email)
+			relay.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	defaultSyslogPort     = "514"
+	defaultSyslogFacility = 3
+	defaultSyslogSeverity = 6
+	syslogAppName         = "csense"
+	syslogSDID            = "container@32473"
+)
+
+var (
+	syslogFacilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+		"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+
+	syslogSeverities = map[string]int{
+		"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+		"warning": 4, "notice": 5, "info": 6, "debug": 7,
+	}
+
+	syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+)
+
+// SyslogShooter sends reactions to a syslog server as RFC 5424 messages with
+// the container fields as structured data. The destination is given as
+// `syslog://host[:port]?transport=udp|tcp` for network servers or
+// `syslog:///dev/log` for a local unix socket. The `facility` and `severity`
+// query values select the message priority. Only the unix sockets in Sockets
+// may be used, or /dev/log when it's empty.
+type SyslogShooter struct {
+	Formatter *Formatter
+	Policy    *DestinationPolicy
+	Sockets   []string
+	mutex     sync.Mutex
+	conns     map[string]*syslogConn
+}
+
+func (s *SyslogShooter) Fire(ctx context.Context, r *v1.Reaction) error {
+	u, err := url.Parse(r.Hook.Url)
+	if err != nil {
+		return fmt.Errorf("error parsing hook url: %v", err)
+	}
+
+	facility := defaultSyslogFacility
+	if v := u.Query().Get("facility"); v != "" {
+		var ok bool
+		if facility, ok = syslogFacilities[v]; !ok {
+			return fmt.Errorf("unknown syslog facility %q", v)
+		}
+	}
+
+	severity := defaultSyslogSeverity
+	if v := u.Query().Get("severity"); v != "" {
+		var ok bool
+		if severity, ok = syslogSeverities[v]; !ok {
+			return fmt.Errorf("unknown syslog severity %q", v)
+		}
+	}
+
+	network, addr, err := syslogAddr(u)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(network, "unix") && !s.isAllowed(addr) {
+		return fmt.Errorf("syslog socket %q is not allowed", addr)
+	}
+
+	body, _, err := s.Formatter.Format(r)
+	if err != nil {
+		return fmt.Errorf("error formatting body: %v", err)
+	}
+
+	msg := formatSyslogMessage(facility*8+severity, r, body)
+	conn, err := s.conn(network, addr)
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog: %v", err)
+	}
+
+	if err = conn.send(msg); err != nil {
+		s.drop(conn)
+		if conn, err = s.conn(network, addr); err == nil {
+			err = conn.send(msg)
+		}
+	}
+
+	if err != nil {
+		s.drop(conn)
+		return fmt.Errorf("error sending syslog message: %v", err)
+	}
+
+	return nil
+}
+
+// defaultSyslogSockets are the unix sockets allowed when none are configured.
+var defaultSyslogSockets = []string{"/dev/log"}
+
+// isAllowed reports if the unix socket is one of the allowed ones, other
+// local sockets such as a container runtime's must not be reachable.
+func (s *SyslogShooter) isAllowed(path string) bool {
+	sockets := s.Sockets
+	if len(sockets) == 0 {
+		sockets = defaultSyslogSockets
+	}
+
+	for _, socket := range sockets {
+		if filepath.Clean(socket) == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func syslogAddr(u *url.URL) (string, string, error) {
+	transport := u.Query().Get("transport")
+	if u.Host == "" {
+		if u.Path == "" {
+			return "", "", fmt.Errorf("syslog address not specified")
+		}
+
+		switch transport {
+		case "":
+			transport = "unixgram"
+		case "unix", "unixgram":
+		default:
+			return "", "", fmt.Errorf("unsupported syslog socket transport %q", transport)
+		}
+
+		return transport, filepath.Clean(u.Path), nil
+	}
+
+	switch transport {
+	case "":
+		transport = "udp"
+	case "udp", "tcp":
+	default:
+		return "", "", fmt.Errorf("unsupported syslog transport %q", transport)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultSyslogPort)
+	}
+
+	return transport, addr, nil
+}
+
+func formatSyslogMessage(priority int, r *v1.Reaction, body []byte) []byte {
+	hostname := r.Host.Hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	msgID := string(r.Event())
+	if msgID == "" {
+		msgID = "-"
+	}
+
+	sd := fmt.Sprintf(`[%s name="%s" image="%s" tag="%s" state="%s" hook="%s" hook_id="%s"]`,
+		syslogSDID,
+		syslogParamEscaper.Replace(r.Container.Name),
+		syslogParamEscaper.Replace(r.Container.ImageName),
+		syslogParamEscaper.Replace(r.Container.ImageTag),
+		syslogParamEscaper.Replace(string(r.Container.State)),
+		syslogParamEscaper.Replace(r.Hook.Name),
+		syslogParamEscaper.Replace(r.Hook.ID))
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s %s ",
+		priority,
+		time.Unix(r.Timestamp, 0).UTC().Format(time.RFC3339),
+		hostname,
+		syslogAppName,
+		os.Getpid(),
+		msgID,
+		sd)
+
+	return append([]byte(header), body...)
+}
+
+func (s *SyslogShooter) conn(network string, addr string) (*syslogConn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := network + "://" + addr
+	if conn, ok := s.conns[key]; ok {
+		return conn, nil
+	}
+
+	d := s.Policy.Dialer(brokerDialTimeout)
+	c, err := d.Dial(network, addr)
+	if err != nil && network == "unixgram" {
+		network = "unix"
+		c, err = d.Dial(network, addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.conns == nil {
+		s.conns = make(map[string]*syslogConn)
+	}
+
+	conn := &syslogConn{
+		Conn:   c,
+		key:    key,
+		framed: network == "tcp" || network == "tcp4" || network == "tcp6",
+	}
+
+	s.conns[key] = conn
+	return conn, nil
+}
+
+func (s *SyslogShooter) drop(conn *syslogConn) {
+	if conn == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conns[conn.key] == conn {
+		delete(s.conns, conn.key)
+	}
+
+	conn.Close()
+}
+
+type syslogConn struct {
+	net.Conn
+	mutex  sync.Mutex
+	key    string
+	framed bool
+}
+
+// send writes a message, using octet counting framing (RFC 6587) on stream
+// transports.
+func (conn *syslogConn) send(msg []byte) error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.framed {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(brokerIOTimeout))
+	_, err := conn.Write(msg)
+	return err
+}
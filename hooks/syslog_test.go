@@ -0,0 +1,69 @@
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+func syslogReaction(url string) *v1.Reaction {
+	return &v1.Reaction{
+		Hook:      &v1.Hook{ID: "h1", Url: url, Format: v1.FormatJSON},
+		Host:      &v1.HostInfo{Hostname: "node1"},
+		Container: &v1.ContainerInfo{Name: "web", State: v1.StateRunning},
+	}
+}
+
+func TestSyslogShooterAllowedSockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csense-syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log")
+	conn, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	s := &SyslogShooter{Formatter: &Formatter{}, Policy: &DestinationPolicy{}, Sockets: []string{socket}}
+	if err := s.Fire(context.Background(), syslogReaction("syslog://"+socket)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := string(buf[:n]); !strings.Contains(msg, "csense") || !strings.Contains(msg, `"name":"web"`) {
+		t.Errorf("got message %q", msg)
+	}
+
+	for _, url := range []string{
+		"syslog://" + filepath.Join(dir, "other"),
+		"syslog://" + filepath.Join(dir, "sub", "..", "..", "docker.sock") + "?transport=unix",
+		"syslog://" + socket + "?transport=tcp",
+		"syslog://logs.example.com?transport=unix",
+	} {
+		if err := s.Fire(context.Background(), syslogReaction(url)); err == nil {
+			t.Errorf("%s: got no error", url)
+		}
+	}
+
+	// only /dev/log is allowed by default
+	if err := (&SyslogShooter{Formatter: &Formatter{}}).Fire(context.Background(), syslogReaction("syslog://"+socket)); err == nil {
+		t.Error("got no error for a socket that isn't allowed by default")
+	}
+}
@@ -0,0 +1,23 @@
+package hooks
+
+import (
+	"strings"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+// ExpandTopic replaces the `{host}`, `{event}`, `{state}`, `{hook}`,
+// `{hook_id}`, `{container}` and `{image}` placeholders of a subject or topic
+// template with values from the reaction. Values are passed through escape so
+// that they can't introduce extra tokens into the result.
+func ExpandTopic(template string, r *v1.Reaction, escape func(string) string) string {
+	return strings.NewReplacer(
+		"{host}", escape(r.Host.Hostname),
+		"{event}", escape(string(r.Event())),
+		"{state}", escape(string(r.Container.State)),
+		"{hook}", escape(r.Hook.Name),
+		"{hook_id}", escape(r.Hook.ID),
+		"{container}", escape(strings.TrimPrefix(r.Container.Name, "/")),
+		"{image}", escape(r.Container.ImageName),
+	).Replace(template)
+}
@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danielkrainas/csense/api/v1"
+)
+
+const (
+	ChallengeHeader = "X-Csense-Challenge"
+
+	// VerifyTimeout bounds a verification of all of a hook's destinations.
+	VerifyTimeout = 10 * time.Second
+
+	maxChallengeResponse = 4096
+)
+
+type verificationRequest struct {
+	Type      string `json:"type"`
+	HookID    string `json:"hook_id"`
+	Challenge string `json:"challenge"`
+}
+
+type verificationResponse struct {
+	Challenge string `json:"challenge"`
+}
+
+// Verifier checks that a hook's receiver is reachable and expecting events.
+// A random challenge token is posted to the destination, both in the body and
+// the `X-Csense-Challenge` header, and must be echoed back either as the raw
+// response body or as `{"challenge": "<token>"}`.
+type Verifier struct {
+	Clients *ClientCache
+}
+
+// Verify checks every http(s) destination of the hook, other destinations
+// can't echo a challenge and are skipped.
+func (v *Verifier) Verify(ctx context.Context, hook *v1.Hook) error {
+	ctx, cancel := context.WithTimeout(ctx, VerifyTimeout)
+	defer cancel()
+	verified := 0
+	for _, d := range hook.Targets() {
+		u, err := url.Parse(d.Url)
+		if err != nil {
+			return fmt.Errorf("error parsing destination url: %v", err)
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+
+		if err := v.verify(ctx, hook.ForDestination(d)); err != nil {
+			return fmt.Errorf("%s: %v", d.Url, err)
+		}
+
+		verified++
+	}
+
+	if verified == 0 {
+		return fmt.Errorf("verification needs at least one http(s) destination")
+	}
+
+	return nil
+}
+
+func (v *Verifier) verify(ctx context.Context, hook *v1.Hook) error {
+	token, err := newChallenge()
+	if err != nil {
+		return fmt.Errorf("error generating challenge: %v", err)
+	}
+
+	body, err := json.Marshal(&verificationRequest{
+		Type:      "verification",
+		HookID:    hook.ID,
+		Challenge: token,
+	})
+
+	if err != nil {
+		return fmt.Errorf("error encoding challenge: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ChallengeHeader, token)
+	SetAuth(req, hook.Auth)
+	client, err := v.Clients.Get(hook)
+	if err != nil {
+		return fmt.Errorf("error configuring http client: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't execute request: %v", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 || resp.StatusCode < 200 {
+		return fmt.Errorf("unexpected response status for verification: %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxChallengeResponse))
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	if strings.TrimSpace(string(data)) == token {
+		return nil
+	}
+
+	echo := &verificationResponse{}
+	if err := json.Unmarshal(data, echo); err == nil && echo.Challenge == token {
+		return nil
+	}
+
+	return fmt.Errorf("challenge wasn't echoed by the receiver")
+}
+
+func newChallenge() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
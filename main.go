@@ -13,7 +13,12 @@ import (
 	_ "github.com/danielkrainas/csense/cmd/agent"
 	"github.com/danielkrainas/csense/cmd/root"
 	_ "github.com/danielkrainas/csense/cmd/version"
+	_ "github.com/danielkrainas/csense/containers/driver/containerd"
+	_ "github.com/danielkrainas/csense/containers/driver/cri"
+	_ "github.com/danielkrainas/csense/containers/driver/docker"
 	_ "github.com/danielkrainas/csense/containers/driver/embedded"
+	_ "github.com/danielkrainas/csense/containers/driver/kubernetes"
+	_ "github.com/danielkrainas/csense/containers/driver/replay"
 	_ "github.com/danielkrainas/csense/storage/driver/consul"
 	_ "github.com/danielkrainas/csense/storage/driver/etcd"
 	_ "github.com/danielkrainas/csense/storage/driver/inmemory"
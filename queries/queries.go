@@ -12,12 +12,53 @@ type FindHook struct {
 // SearchHooks searches all hooks and returns any matches
 type SearchHooks struct{}
 
-// GetContainerEvents queries for a container events channel
+// FindReceiver queries for a single receiver by ID
+type FindReceiver struct {
+	ID string
+}
+
+// SearchReceivers searches all receivers and returns any matches
+type SearchReceivers struct{}
+
+// FindSilence queries for a single silence by ID
+type FindSilence struct {
+	ID string
+}
+
+// SearchSilences searches all silences and returns any matches
+type SearchSilences struct{}
+
+// ResolveHook returns a copy of the hook with receiver references replaced
+// by the receivers' current settings
+type ResolveHook struct {
+	Hook *v1.Hook
+}
+
+// SearchDeliveries searches the delivery history, optionally for a single hook
+type SearchDeliveries struct {
+	HookID string
+}
+
+// GetContainerEvents queries for a container events channel. Index holds the
+// tracked containers by name, it's filled with the current containers when
+// nil. Reusing it after reconnecting, or loading it from a snapshot, resyncs
+// it with the current containers through synthesized events.
+// Existed adds a containerExisted event for every current container that
+// isn't reported as created.
 type GetContainerEvents struct {
-	Types []v1.ContainerEventType
+	Types   []v1.ContainerEventType
+	Index   map[string]*v1.ContainerInfo
+	Existed bool
 }
 
 // GetContainer queries for a single container by name
 type GetContainer struct {
 	Name string
 }
+
+// GetHealth queries for the agent's health
+type GetHealth struct{}
+
+// GetClientCache queries for the http clients shared by hook deliveries and
+// verifications
+type GetClientCache struct{}
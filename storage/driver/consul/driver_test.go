@@ -0,0 +1,107 @@
+package inmemory
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+	"github.com/danielkrainas/csense/storage/driver/kvtest"
+)
+
+func newTestDriver() *driver {
+	kv := kvtest.New()
+	return &driver{
+		kv:         kv,
+		keyRoot:    "csense",
+		hooks:      &hookStore{"csense", kv},
+		deliveries: &deliveryStore{"csense", kv},
+		receivers:  &receiverStore{"csense", kv},
+		silences:   &silenceStore{"csense", kv},
+	}
+}
+
+func TestFindManyWhenEmpty(t *testing.T) {
+	d := newTestDriver()
+	if hooks, err := d.Hooks().FindMany(&storage.HookFilters{}); err != nil || len(hooks) != 0 {
+		t.Errorf("hooks: got %v, %v", hooks, err)
+	}
+
+	if receivers, err := d.Receivers().FindMany(&storage.ReceiverFilters{}); err != nil || len(receivers) != 0 {
+		t.Errorf("receivers: got %v, %v", receivers, err)
+	}
+
+	if silences, err := d.Silences().FindMany(&storage.SilenceFilters{}); err != nil || len(silences) != 0 {
+		t.Errorf("silences: got %v, %v", silences, err)
+	}
+
+	if deliveries, err := d.Deliveries().FindMany(&storage.DeliveryFilters{HookID: "h1"}); err != nil || len(deliveries) != 0 {
+		t.Errorf("deliveries: got %v, %v", deliveries, err)
+	}
+}
+
+func TestFindMany(t *testing.T) {
+	d := newTestDriver()
+	if err := d.Receivers().Store(&v1.Receiver{ID: "r1", Url: "https://example.com"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	receivers, err := d.Receivers().FindMany(&storage.ReceiverFilters{})
+	if err != nil || len(receivers) != 1 || receivers[0].ID != "r1" {
+		t.Errorf("got %v, %v", receivers, err)
+	}
+}
+
+func TestDeliveryHistoryIsCapped(t *testing.T) {
+	d := newTestDriver()
+	for i := 1; i <= maxDeliveriesPerHook+5; i++ {
+		delivery := &v1.Delivery{ID: fmt.Sprintf("d%03d", i), HookID: "h1", Sequence: uint64(i), Timestamp: 1700000000}
+		if err := d.Deliveries().Store(delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Deliveries().Store(&v1.Delivery{ID: "other", HookID: "h10", Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := d.Deliveries().FindMany(&storage.DeliveryFilters{HookID: "h1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != maxDeliveriesPerHook {
+		t.Fatalf("got %d deliveries, want %d", len(history), maxDeliveriesPerHook)
+	}
+
+	for _, delivery := range history {
+		if delivery.Sequence <= 5 {
+			t.Errorf("kept old delivery %d", delivery.Sequence)
+		}
+	}
+
+	if other, err := d.Deliveries().FindMany(&storage.DeliveryFilters{HookID: "h10"}); err != nil || len(other) != 1 {
+		t.Errorf("other hook: got %v, %v", other, err)
+	}
+}
+
+func TestDeleteSequence(t *testing.T) {
+	d := newTestDriver()
+	for i := 0; i < 3; i++ {
+		if _, err := d.Deliveries().NextSequence("h1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Deliveries().DeleteSequence("h1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Deliveries().DeleteSequence("h2"); err != nil {
+		t.Errorf("missing sequence: got %v", err)
+	}
+
+	if seq, err := d.Deliveries().NextSequence("h1"); err != nil || seq != 1 {
+		t.Errorf("got %d, %v after deleting", seq, err)
+	}
+}
@@ -39,7 +39,7 @@ func (store *hookStore) Find(id string) (*v1.Hook, error) {
 }
 
 func (store *hookStore) FindMany(filters *storage.HookFilters) ([]*v1.Hook, error) {
-	pairs, err := store.kv.List(store.getHooksKey())
+	pairs, err := listPairs(store.kv, store.getHooksKey())
 	if err != nil {
 		return nil, err
 	}
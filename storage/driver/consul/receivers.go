@@ -0,0 +1,79 @@
+package inmemory
+
+import (
+	"encoding/json"
+
+	"github.com/docker/libkv/store"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+type receiverStore struct {
+	root string
+	kv   store.Store
+}
+
+var _ storage.ReceiverStore = (*receiverStore)(nil)
+
+func (store *receiverStore) getReceiversKey() string {
+	return store.root + ".receivers"
+}
+
+func (store *receiverStore) getReceiverKey(id string) string {
+	return store.getReceiversKey() + "." + id
+}
+
+func (store *receiverStore) Find(id string) (*v1.Receiver, error) {
+	pair, err := store.kv.Get(store.getReceiverKey(id))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	receiver := &v1.Receiver{}
+	if err := json.Unmarshal(pair.Value, receiver); err != nil {
+		return nil, err
+	}
+
+	return receiver, nil
+}
+
+func (store *receiverStore) FindMany(filters *storage.ReceiverFilters) ([]*v1.Receiver, error) {
+	pairs, err := listPairs(store.kv, store.getReceiversKey())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*v1.Receiver, len(pairs))
+	for i, pair := range pairs {
+		receiver := &v1.Receiver{}
+		if err := json.Unmarshal(pair.Value, receiver); err != nil {
+			return nil, err
+		}
+
+		results[i] = receiver
+	}
+
+	return results, nil
+}
+
+func (store *receiverStore) Store(receiver *v1.Receiver, isNew bool) error {
+	data, err := json.Marshal(receiver)
+	if err != nil {
+		return err
+	}
+
+	return store.kv.Put(store.getReceiverKey(receiver.ID), data, nil)
+}
+
+func (store *receiverStore) Delete(id string) error {
+	key := store.getReceiverKey(id)
+	exists, err := store.kv.Exists(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return storage.ErrNotFound
+	}
+
+	return store.kv.Delete(key)
+}
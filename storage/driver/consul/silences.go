@@ -0,0 +1,79 @@
+package inmemory
+
+import (
+	"encoding/json"
+
+	"github.com/docker/libkv/store"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+type silenceStore struct {
+	root string
+	kv   store.Store
+}
+
+var _ storage.SilenceStore = (*silenceStore)(nil)
+
+func (store *silenceStore) getSilencesKey() string {
+	return store.root + ".silences"
+}
+
+func (store *silenceStore) getSilenceKey(id string) string {
+	return store.getSilencesKey() + "." + id
+}
+
+func (store *silenceStore) Find(id string) (*v1.Silence, error) {
+	pair, err := store.kv.Get(store.getSilenceKey(id))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	silence := &v1.Silence{}
+	if err := json.Unmarshal(pair.Value, silence); err != nil {
+		return nil, err
+	}
+
+	return silence, nil
+}
+
+func (store *silenceStore) FindMany(filters *storage.SilenceFilters) ([]*v1.Silence, error) {
+	pairs, err := listPairs(store.kv, store.getSilencesKey())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*v1.Silence, len(pairs))
+	for i, pair := range pairs {
+		silence := &v1.Silence{}
+		if err := json.Unmarshal(pair.Value, silence); err != nil {
+			return nil, err
+		}
+
+		results[i] = silence
+	}
+
+	return results, nil
+}
+
+func (store *silenceStore) Store(silence *v1.Silence, isNew bool) error {
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return err
+	}
+
+	return store.kv.Put(store.getSilenceKey(silence.ID), data, nil)
+}
+
+func (store *silenceStore) Delete(id string) error {
+	key := store.getSilenceKey(id)
+	exists, err := store.kv.Exists(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return storage.ErrNotFound
+	}
+
+	return store.kv.Delete(key)
+}
@@ -0,0 +1,158 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/docker/libkv/store"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+const maxDeliveriesPerHook = 100
+
+type deliveryStore struct {
+	root string
+	kv   store.Store
+}
+
+var _ storage.DeliveryStore = (*deliveryStore)(nil)
+
+func (store *deliveryStore) getDeliveriesKey() string {
+	return store.root + ".deliveries"
+}
+
+func (store *deliveryStore) getHookDeliveriesKey(hookID string) string {
+	return store.getDeliveriesKey() + "." + hookID
+}
+
+func (store *deliveryStore) getDeliveryKey(hookID string, id string) string {
+	return store.getHookDeliveriesKey(hookID) + "." + id
+}
+
+func (store *deliveryStore) getSequenceKey(hookID string) string {
+	return store.root + ".sequences." + hookID
+}
+
+func (store *deliveryStore) NextSequence(hookID string) (uint64, error) {
+	return nextSequence(store.kv, store.getSequenceKey(hookID))
+}
+
+func (store *deliveryStore) DeleteSequence(hookID string) error {
+	return deleteIfExists(store.kv, store.getSequenceKey(hookID))
+}
+
+// deleteIfExists deletes the key, it's not an error when it doesn't exist.
+func deleteIfExists(kv store.Store, key string) error {
+	if err := kv.Delete(key); err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// nextSequence increments the counter at key with compare-and-swap so that
+// concurrent agents sharing the store never hand out the same number.
+func nextSequence(kv store.Store, key string) (uint64, error) {
+	for {
+		var next uint64 = 1
+		previous, err := kv.Get(key)
+		if err == store.ErrKeyNotFound {
+			previous = nil
+		} else if err != nil {
+			return 0, err
+		} else {
+			current, err := strconv.ParseUint(string(previous.Value), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+
+			next = current + 1
+		}
+
+		_, _, err = kv.AtomicPut(key, []byte(strconv.FormatUint(next, 10)), previous, nil)
+		if err == store.ErrKeyModified || err == store.ErrKeyExists {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		return next, nil
+	}
+}
+
+func (store *deliveryStore) Store(d *v1.Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if err := store.kv.Put(store.getDeliveryKey(d.HookID, d.ID), data, nil); err != nil {
+		return err
+	}
+
+	return pruneDeliveries(store.kv, store.getHookDeliveriesKey(d.HookID)+".")
+}
+
+// pruneDeliveries keeps the latest maxDeliveriesPerHook deliveries under key.
+func pruneDeliveries(kv store.Store, key string) error {
+	pairs, err := listPairs(kv, key)
+	if err != nil || len(pairs) <= maxDeliveriesPerHook {
+		return err
+	}
+
+	history := make([]*v1.Delivery, 0, len(pairs))
+	keys := make(map[*v1.Delivery]string, len(pairs))
+	for _, pair := range pairs {
+		d := &v1.Delivery{}
+		if err := json.Unmarshal(pair.Value, d); err != nil {
+			return err
+		}
+
+		history = append(history, d)
+		keys[d] = pair.Key
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].Timestamp != history[j].Timestamp {
+			return history[i].Timestamp < history[j].Timestamp
+		}
+
+		return history[i].Sequence < history[j].Sequence
+	})
+
+	for _, d := range history[:len(history)-maxDeliveriesPerHook] {
+		// another agent sharing the store may have pruned it already
+		if err := kv.Delete(keys[d]); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *deliveryStore) FindMany(filters *storage.DeliveryFilters) ([]*v1.Delivery, error) {
+	key := store.getDeliveriesKey()
+	if filters.HookID != "" {
+		key = store.getHookDeliveriesKey(filters.HookID) + "."
+	}
+
+	pairs, err := listPairs(store.kv, key)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*v1.Delivery, len(pairs))
+	for i, pair := range pairs {
+		d := &v1.Delivery{}
+		if err := json.Unmarshal(pair.Value, d); err != nil {
+			return nil, err
+		}
+
+		results[i] = d
+	}
+
+	return results, nil
+}
@@ -42,9 +42,12 @@ func (f *driverFactory) Create(parameters map[string]interface{}) (drivers.Drive
 	}
 
 	return &driver{
-		kv:      kv,
-		keyRoot: keyRoot,
-		hooks:   &hookStore{keyRoot, kv},
+		kv:         kv,
+		keyRoot:    keyRoot,
+		hooks:      &hookStore{keyRoot, kv},
+		deliveries: &deliveryStore{keyRoot, kv},
+		receivers:  &receiverStore{keyRoot, kv},
+		silences:   &silenceStore{keyRoot, kv},
 	}, nil
 }
 
@@ -54,13 +57,27 @@ func init() {
 }
 
 type driver struct {
-	kv      store.Store
-	keyRoot string
-	hooks   *hookStore
+	kv         store.Store
+	keyRoot    string
+	hooks      *hookStore
+	deliveries *deliveryStore
+	receivers  *receiverStore
+	silences   *silenceStore
 }
 
 var _ storage.Driver = &driver{}
 
+// listPairs lists the pairs under key, there are none when nothing was ever
+// stored under it.
+func listPairs(kv store.Store, key string) ([]*store.KVPair, error) {
+	pairs, err := kv.List(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+
+	return pairs, err
+}
+
 func (d *driver) Setup(ctx context.Context) error {
 	return storage.ErrNotSupported
 }
@@ -72,3 +89,15 @@ func (d *driver) Teardown(ctx context.Context) error {
 func (d *driver) Hooks() storage.HookStore {
 	return d.hooks
 }
+
+func (d *driver) Deliveries() storage.DeliveryStore {
+	return d.deliveries
+}
+
+func (d *driver) Receivers() storage.ReceiverStore {
+	return d.receivers
+}
+
+func (d *driver) Silences() storage.SilenceStore {
+	return d.silences
+}
@@ -0,0 +1,72 @@
+package inmemory
+
+import (
+	"sync"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+const maxDeliveriesPerHook = 100
+
+type deliveryStore struct {
+	mutex      sync.Mutex
+	hookLookup map[string][]*v1.Delivery
+	sequences  map[string]uint64
+}
+
+var _ storage.DeliveryStore = (*deliveryStore)(nil)
+
+func (store *deliveryStore) Store(d *v1.Delivery) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.hookLookup == nil {
+		store.hookLookup = map[string][]*v1.Delivery{}
+	}
+
+	dupe := *d
+	history := append(store.hookLookup[d.HookID], &dupe)
+	if len(history) > maxDeliveriesPerHook {
+		history = history[len(history)-maxDeliveriesPerHook:]
+	}
+
+	store.hookLookup[d.HookID] = history
+	return nil
+}
+
+func (store *deliveryStore) NextSequence(hookID string) (uint64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.sequences == nil {
+		store.sequences = map[string]uint64{}
+	}
+
+	store.sequences[hookID]++
+	return store.sequences[hookID], nil
+}
+
+func (store *deliveryStore) DeleteSequence(hookID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.sequences, hookID)
+	return nil
+}
+
+func (store *deliveryStore) FindMany(filters *storage.DeliveryFilters) ([]*v1.Delivery, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	results := make([]*v1.Delivery, 0)
+	for hookID, history := range store.hookLookup {
+		if filters.HookID != "" && filters.HookID != hookID {
+			continue
+		}
+
+		results = append(results, history...)
+	}
+
+	return results, nil
+}
@@ -13,7 +13,10 @@ type driverFactory struct{}
 
 func (f *driverFactory) Create(parameters map[string]interface{}) (drivers.DriverBase, error) {
 	return &driver{
-		hooks: &hookStore{},
+		hooks:      &hookStore{},
+		deliveries: &deliveryStore{},
+		receivers:  &receiverStore{},
+		silences:   &silenceStore{},
 	}, nil
 }
 
@@ -22,7 +25,10 @@ func init() {
 }
 
 type driver struct {
-	hooks *hookStore
+	hooks      *hookStore
+	deliveries *deliveryStore
+	receivers  *receiverStore
+	silences   *silenceStore
 }
 
 var _ storage.Driver = &driver{}
@@ -38,3 +44,15 @@ func (d *driver) Teardown(ctx context.Context) error {
 func (d *driver) Hooks() storage.HookStore {
 	return d.hooks
 }
+
+func (d *driver) Deliveries() storage.DeliveryStore {
+	return d.deliveries
+}
+
+func (d *driver) Receivers() storage.ReceiverStore {
+	return d.receivers
+}
+
+func (d *driver) Silences() storage.SilenceStore {
+	return d.silences
+}
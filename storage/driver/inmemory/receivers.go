@@ -0,0 +1,67 @@
+package inmemory
+
+import (
+	"sync"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+type receiverStore struct {
+	mutex    sync.Mutex
+	idLookup map[string]*v1.Receiver
+}
+
+var _ storage.ReceiverStore = (*receiverStore)(nil)
+
+func (store *receiverStore) Find(id string) (*v1.Receiver, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	receiver, ok := store.idLookup[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return receiver, nil
+}
+
+func (store *receiverStore) FindMany(filters *storage.ReceiverFilters) ([]*v1.Receiver, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	results := make([]*v1.Receiver, len(store.idLookup))
+	i := 0
+	for _, receiver := range store.idLookup {
+		results[i] = receiver
+		i++
+	}
+
+	return results, nil
+}
+
+func (store *receiverStore) Store(receiver *v1.Receiver, isNew bool) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.idLookup == nil {
+		store.idLookup = map[string]*v1.Receiver{}
+	}
+
+	dupe := *receiver
+	store.idLookup[receiver.ID] = &dupe
+	return nil
+}
+
+func (store *receiverStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, ok := store.idLookup[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	delete(store.idLookup, id)
+	return nil
+}
@@ -0,0 +1,67 @@
+package inmemory
+
+import (
+	"sync"
+
+	"github.com/danielkrainas/csense/api/v1"
+	"github.com/danielkrainas/csense/storage"
+)
+
+type silenceStore struct {
+	mutex    sync.Mutex
+	idLookup map[string]*v1.Silence
+}
+
+var _ storage.SilenceStore = (*silenceStore)(nil)
+
+func (store *silenceStore) Find(id string) (*v1.Silence, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	silence, ok := store.idLookup[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return silence, nil
+}
+
+func (store *silenceStore) FindMany(filters *storage.SilenceFilters) ([]*v1.Silence, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	results := make([]*v1.Silence, len(store.idLookup))
+	i := 0
+	for _, silence := range store.idLookup {
+		results[i] = silence
+		i++
+	}
+
+	return results, nil
+}
+
+func (store *silenceStore) Store(silence *v1.Silence, isNew bool) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.idLookup == nil {
+		store.idLookup = map[string]*v1.Silence{}
+	}
+
+	dupe := *silence
+	store.idLookup[silence.ID] = &dupe
+	return nil
+}
+
+func (store *silenceStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, ok := store.idLookup[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	delete(store.idLookup, id)
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package kvtest is an in-memory libkv store to test the consul and etcd
+// storage drivers against. Listing behaves like those backends and fails
+// with store.ErrKeyNotFound when nothing is under the prefix.
+package kvtest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/libkv/store"
+)
+
+// Store keeps pairs in memory. Watches and locks aren't supported.
+type Store struct {
+	store.Store
+	mu    sync.Mutex
+	index uint64
+	pairs map[string]*store.KVPair
+}
+
+func New() *Store {
+	return &Store{pairs: make(map[string]*store.KVPair)}
+}
+
+func (s *Store) Put(key string, value []byte, options *store.WriteOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(key, value)
+	return nil
+}
+
+func (s *Store) put(key string, value []byte) *store.KVPair {
+	s.index++
+	pair := &store.KVPair{Key: key, Value: append([]byte(nil), value...), LastIndex: s.index}
+	s.pairs[key] = pair
+	return pair
+}
+
+func (s *Store) Get(key string) (*store.KVPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pair, ok := s.pairs[key]
+	if !ok {
+		return nil, store.ErrKeyNotFound
+	}
+
+	return pair, nil
+}
+
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pairs[key]; !ok {
+		return store.ErrKeyNotFound
+	}
+
+	delete(s.pairs, key)
+	return nil
+}
+
+func (s *Store) Exists(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pairs[key]
+	return ok, nil
+}
+
+// List returns the pairs under the prefix ordered by key.
+func (s *Store) List(directory string) ([]*store.KVPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pairs := make([]*store.KVPair, 0)
+	for k, pair := range s.pairs {
+		if strings.HasPrefix(k, directory) {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if len(pairs) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	return pairs, nil
+}
+
+func (s *Store) DeleteTree(directory string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.pairs {
+		if strings.HasPrefix(k, directory) {
+			delete(s.pairs, k)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.pairs[key]
+	if previous == nil && ok {
+		return false, nil, store.ErrKeyExists
+	} else if previous != nil && (!ok || current.LastIndex != previous.LastIndex) {
+		return false, nil, store.ErrKeyModified
+	}
+
+	return true, s.put(key, value), nil
+}
+
+func (s *Store) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.pairs[key]
+	if !ok {
+		return false, store.ErrKeyNotFound
+	} else if previous == nil || current.LastIndex != previous.LastIndex {
+		return false, store.ErrKeyModified
+	}
+
+	delete(s.pairs, key)
+	return true, nil
+}
+
+func (s *Store) Close() {}
@@ -17,6 +17,9 @@ type Driver interface {
 	Teardown(ctx context.Context) error
 
 	Hooks() HookStore
+	Deliveries() DeliveryStore
+	Receivers() ReceiverStore
+	Silences() SilenceStore
 }
 
 type HookStore interface {
@@ -27,3 +30,32 @@ type HookStore interface {
 }
 
 type HookFilters struct{}
+
+type ReceiverStore interface {
+	Find(id string) (*v1.Receiver, error)
+	Delete(id string) error
+	Store(receiver *v1.Receiver, isNew bool) error
+	FindMany(filters *ReceiverFilters) ([]*v1.Receiver, error)
+}
+
+type ReceiverFilters struct{}
+
+type SilenceStore interface {
+	Find(id string) (*v1.Silence, error)
+	Delete(id string) error
+	Store(silence *v1.Silence, isNew bool) error
+	FindMany(filters *SilenceFilters) ([]*v1.Silence, error)
+}
+
+type SilenceFilters struct{}
+
+type DeliveryStore interface {
+	Store(d *v1.Delivery) error
+	FindMany(filters *DeliveryFilters) ([]*v1.Delivery, error)
+	NextSequence(hookID string) (uint64, error)
+	DeleteSequence(hookID string) error
+}
+
+type DeliveryFilters struct {
+	HookID string
+}